@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// BatchRequest is a single call within a JSON-RPC batch
+type BatchRequest struct {
+	Method string
+	Params interface{}
+
+	// Notification sends the call without an ID, so no response is expected
+	Notification bool
+}
+
+// BatchResponse is the server's reply to a BatchRequest
+type BatchResponse struct {
+	Method string
+	Result json.RawMessage
+	Error  *protocol.JSONRPCError
+}
+
+// Unmarshal decodes the result into v, returning the RPC error if the call failed
+func (r *BatchResponse) Unmarshal(v interface{}) error {
+	if r.Error != nil {
+		return rpcError(r.Error)
+	}
+	if v == nil || r.Result == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, v)
+}
+
+// Batch sends requests as a single JSON-RPC batch and waits for all responses.
+// Responses are returned in request order, notifications are omitted.
+func (cs *ClientSession) Batch(ctx context.Context, reqs []BatchRequest) ([]BatchResponse, error) {
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("empty batch")
+	}
+
+	msgs := make([]*protocol.JSONRPCMessage, 0, len(reqs))
+	ids := make([]string, 0, len(reqs))
+	pendings := make([]*pendingRequest, 0, len(reqs))
+	methods := make([]string, 0, len(reqs))
+
+	for _, req := range reqs {
+		msg := &protocol.JSONRPCMessage{
+			JSONRPC: "2.0",
+			Method:  req.Method,
+		}
+		if req.Params != nil {
+			paramsJSON, err := json.Marshal(req.Params)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal params for %s: %w", req.Method, err)
+			}
+			msg.Params = paramsJSON
+		}
+
+		if !req.Notification {
+			cs.mu.Lock()
+			cs.nextID++
+			id := strconv.FormatInt(cs.nextID, 10)
+			cs.mu.Unlock()
+
+			msg.ID, _ = json.Marshal(id)
+			ids = append(ids, id)
+			methods = append(methods, req.Method)
+			pendings = append(pendings, &pendingRequest{
+				method:   req.Method,
+				response: make(chan *protocol.JSONRPCMessage, 1),
+				err:      make(chan error, 1),
+				raw:      true,
+			})
+		}
+		msgs = append(msgs, msg)
+	}
+
+	cs.mu.Lock()
+	for i, id := range ids {
+		cs.pending[id] = pendings[i]
+	}
+	cs.mu.Unlock()
+
+	cleanup := func() {
+		cs.mu.Lock()
+		for _, id := range ids {
+			delete(cs.pending, id)
+		}
+		cs.mu.Unlock()
+	}
+
+	if err := cs.write(ctx, protocol.NewBatchMessage(msgs...)); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to write batch: %w", err)
+	}
+
+	responses := make([]BatchResponse, len(pendings))
+	for i, pending := range pendings {
+		select {
+		case <-ctx.Done():
+			cleanup()
+			return nil, ctx.Err()
+		case err := <-pending.err:
+			cleanup()
+			return nil, err
+		case resp := <-pending.response:
+			responses[i] = BatchResponse{
+				Method: methods[i],
+				Result: resp.Result,
+				Error:  resp.Error,
+			}
+		}
+	}
+
+	return responses, nil
+}
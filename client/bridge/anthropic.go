@@ -0,0 +1,153 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/voocel/mcp-sdk-go/client"
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// AnthropicMessage is a message of the Anthropic Messages API. Content blocks are kept in
+// their JSON form, e.g. {"type": "tool_result", "tool_use_id": ..., "content": [...]},
+// which is also how the Anthropic Go SDK encodes ToolResultBlockParam.
+type AnthropicMessage struct {
+	Role    string                   `json:"role"`
+	Content []map[string]interface{} `json:"content"`
+}
+
+// MessagesRequest is a Messages API request
+type MessagesRequest struct {
+	Model     string                   `json:"model"`
+	MaxTokens int                      `json:"max_tokens"`
+	System    string                   `json:"system,omitempty"`
+	Messages  []AnthropicMessage       `json:"messages"`
+	Tools     []map[string]interface{} `json:"tools,omitempty"`
+}
+
+// MessagesResponse is a Messages API response
+type MessagesResponse struct {
+	ID         string                   `json:"id"`
+	Type       string                   `json:"type"`
+	Role       string                   `json:"role"`
+	Model      string                   `json:"model"`
+	Content    []map[string]interface{} `json:"content"`
+	StopReason string                   `json:"stop_reason"`
+}
+
+// MessagesFunc sends a request to an Anthropic model, e.g. through an HTTP client for
+// /v1/messages
+type MessagesFunc func(ctx context.Context, req *MessagesRequest) (*MessagesResponse, error)
+
+// AnthropicBridgeClient offers the tools of an MCP session to an Anthropic model
+type AnthropicBridgeClient struct {
+	session *client.ClientSession
+	create  MessagesFunc
+
+	// MaxToolRounds limits how many times CreateMessage calls the model.
+	// Zero uses 10.
+	MaxToolRounds int
+}
+
+// NewAnthropicBridgeClient creates a bridge between session and the model behind create
+func NewAnthropicBridgeClient(session *client.ClientSession, create MessagesFunc) *AnthropicBridgeClient {
+	return &AnthropicBridgeClient{session: session, create: create}
+}
+
+// Tools lists the tools of the server as Anthropic tool definitions
+func (b *AnthropicBridgeClient) Tools(ctx context.Context) ([]map[string]interface{}, error) {
+	return listTools(ctx, b.session, protocol.ToolToAnthropicTool)
+}
+
+// CallToolUses runs the tool_use blocks among content on the server and returns one
+// tool_result block per call, in order. Failed calls are reported to the model as error
+// results rather than as an error, so that it can recover.
+func (b *AnthropicBridgeClient) CallToolUses(ctx context.Context, content []map[string]interface{}) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	for _, block := range content {
+		if block["type"] != "tool_use" {
+			continue
+		}
+		id, _ := block["id"].(string)
+		result, err := protocol.ContentToAnthropicBlock(b.callToolUse(ctx, id, block))
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+func (b *AnthropicBridgeClient) callToolUse(ctx context.Context, id string, block map[string]interface{}) protocol.ToolResultContent {
+	failed := func(err error) protocol.ToolResultContent {
+		return protocol.NewToolResultContentWithError(id, []protocol.ContentBlock{protocol.NewTextContentBlock(err.Error())}, true)
+	}
+
+	params, err := protocol.AnthropicToolUseToCallToolParams(block)
+	if err != nil {
+		return failed(err)
+	}
+	result, err := b.session.CallTool(ctx, params)
+	if err != nil {
+		return failed(err)
+	}
+
+	var blocks []protocol.ContentBlock
+	for _, c := range result.Content {
+		switch c := c.(type) {
+		case protocol.TextContent:
+			blocks = append(blocks, protocol.NewTextContentBlock(c.Text))
+		case protocol.ImageContent:
+			blocks = append(blocks, protocol.NewImageContentBlock(c.Data, c.MimeType))
+		default:
+			if data, err := json.Marshal(c); err == nil {
+				blocks = append(blocks, protocol.NewTextContentBlock(string(data)))
+			}
+		}
+	}
+	if len(blocks) == 0 && result.StructuredContent != nil {
+		if data, err := json.Marshal(result.StructuredContent); err == nil {
+			blocks = append(blocks, protocol.NewTextContentBlock(string(data)))
+		}
+	}
+	return protocol.NewToolResultContentWithError(id, blocks, result.IsError)
+}
+
+// CreateMessage sends req to the model with the server's tools, unless req already lists
+// tools, runs the tools the model uses and sends their results back, until the model
+// stops for another reason than tool use. The returned response is that final answer;
+// req.Messages is not modified.
+func (b *AnthropicBridgeClient) CreateMessage(ctx context.Context, req MessagesRequest) (*MessagesResponse, error) {
+	if req.Tools == nil {
+		tools, err := b.Tools(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list tools: %w", err)
+		}
+		req.Tools = tools
+	}
+	req.Messages = append([]AnthropicMessage(nil), req.Messages...)
+
+	rounds := maxRounds(b.MaxToolRounds)
+	for range rounds {
+		resp, err := b.create(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StopReason != "tool_use" {
+			return resp, nil
+		}
+
+		results, err := b.CallToolUses(ctx, resp.Content)
+		if err != nil {
+			return nil, err
+		}
+		req.Messages = append(req.Messages,
+			AnthropicMessage{Role: "assistant", Content: resp.Content},
+			AnthropicMessage{Role: "user", Content: results})
+	}
+	return nil, fmt.Errorf("%w: %d", ErrTooManyToolRounds, rounds)
+}
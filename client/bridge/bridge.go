@@ -0,0 +1,44 @@
+// Package bridge exposes the tools of an MCP server to applications written against
+// other tool-calling APIs.
+package bridge
+
+import (
+	"context"
+	"errors"
+
+	"github.com/voocel/mcp-sdk-go/client"
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// defaultMaxToolRounds bounds the model round trips of a bridged request
+const defaultMaxToolRounds = 10
+
+// ErrTooManyToolRounds is returned when the model keeps requesting tool calls
+var ErrTooManyToolRounds = errors.New("too many tool call rounds")
+
+// listTools lists all the tools of the server, converted by convert
+func listTools(ctx context.Context, session *client.ClientSession, convert func(protocol.Tool) map[string]interface{}) ([]map[string]interface{}, error) {
+	var tools []map[string]interface{}
+	params := &protocol.ListToolsParams{}
+	for {
+		result, err := session.ListTools(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, tool := range result.Tools {
+			tools = append(tools, convert(tool))
+		}
+		if result.NextCursor == nil || *result.NextCursor == "" {
+			return tools, nil
+		}
+		params.Cursor = *result.NextCursor
+	}
+}
+
+// maxRounds returns the model round trip limit for a MaxToolRounds setting
+func maxRounds(n int) int {
+	if n <= 0 {
+		return defaultMaxToolRounds
+	}
+	return n
+}
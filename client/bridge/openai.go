@@ -0,0 +1,170 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/voocel/mcp-sdk-go/client"
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// ChatMessage is a message of the OpenAI chat completions API
+type ChatMessage struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	Name       string     `json:"name,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall names the function to call; Arguments is a JSON-encoded object
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ChatCompletionRequest is a chat completions request
+type ChatCompletionRequest struct {
+	Model    string                   `json:"model"`
+	Messages []ChatMessage            `json:"messages"`
+	Tools    []map[string]interface{} `json:"tools,omitempty"`
+}
+
+// ChatCompletionResponse is a chat completions response
+type ChatCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []ChatCompletionChoice `json:"choices"`
+}
+
+type ChatCompletionChoice struct {
+	Index        int         `json:"index"`
+	Message      ChatMessage `json:"message"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+// CompletionFunc sends a request to an OpenAI-compatible model, e.g. through an HTTP
+// client for /v1/chat/completions
+type CompletionFunc func(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error)
+
+// OpenAIBridgeClient offers the tools of an MCP session to an OpenAI-compatible model
+type OpenAIBridgeClient struct {
+	session  *client.ClientSession
+	complete CompletionFunc
+
+	// MaxToolRounds limits how many times CreateChatCompletion calls the model.
+	// Zero uses 10.
+	MaxToolRounds int
+}
+
+// NewOpenAIBridgeClient creates a bridge between session and the model behind complete
+func NewOpenAIBridgeClient(session *client.ClientSession, complete CompletionFunc) *OpenAIBridgeClient {
+	return &OpenAIBridgeClient{session: session, complete: complete}
+}
+
+// Tools lists the tools of the server as OpenAI function definitions
+func (b *OpenAIBridgeClient) Tools(ctx context.Context) ([]map[string]interface{}, error) {
+	return listTools(ctx, b.session, protocol.ToolToOpenAIFunction)
+}
+
+// CallTools runs the tool calls of a model message on the server and returns one "tool"
+// message per call, in order. Failed calls are reported to the model in the message
+// content rather than as an error, so that it can recover.
+func (b *OpenAIBridgeClient) CallTools(ctx context.Context, calls []ToolCall) ([]ChatMessage, error) {
+	messages := make([]ChatMessage, 0, len(calls))
+	for _, call := range calls {
+		messages = append(messages, ChatMessage{
+			Role:       "tool",
+			ToolCallID: call.ID,
+			Content:    b.callTool(ctx, call),
+		})
+		if err := ctx.Err(); err != nil {
+			return messages, err
+		}
+	}
+	return messages, nil
+}
+
+func (b *OpenAIBridgeClient) callTool(ctx context.Context, call ToolCall) string {
+	params, err := protocol.OpenAIFunctionCallToCallToolParams(map[string]interface{}{
+		"name":      call.Function.Name,
+		"arguments": call.Function.Arguments,
+	})
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	result, err := b.session.CallTool(ctx, params)
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	text := resultText(result)
+	if result.IsError {
+		return "Error: " + text
+	}
+	return text
+}
+
+// resultText flattens a tool result for the model: text contents are joined, other
+// contents and structured content without text are JSON-encoded
+func resultText(result *protocol.CallToolResult) string {
+	var parts []string
+	for _, c := range result.Content {
+		if text, ok := c.(protocol.TextContent); ok {
+			parts = append(parts, text.Text)
+		} else if data, err := json.Marshal(c); err == nil {
+			parts = append(parts, string(data))
+		}
+	}
+	if len(parts) == 0 && result.StructuredContent != nil {
+		if data, err := json.Marshal(result.StructuredContent); err == nil {
+			parts = append(parts, string(data))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// CreateChatCompletion sends req to the model with the server's tools, unless req already
+// lists tools, runs the tool calls the model asks for and sends their results back, until
+// the model answers without tool calls. The returned response is that final answer;
+// req.Messages is not modified.
+func (b *OpenAIBridgeClient) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	if req.Tools == nil {
+		tools, err := b.Tools(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list tools: %w", err)
+		}
+		req.Tools = tools
+	}
+	req.Messages = append([]ChatMessage(nil), req.Messages...)
+
+	rounds := maxRounds(b.MaxToolRounds)
+	for range rounds {
+		resp, err := b.complete(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
+			return resp, nil
+		}
+
+		message := resp.Choices[0].Message
+		results, err := b.CallTools(ctx, message.ToolCalls)
+		if err != nil {
+			return nil, err
+		}
+		req.Messages = append(req.Messages, message)
+		req.Messages = append(req.Messages, results...)
+	}
+	return nil, fmt.Errorf("%w: %d", ErrTooManyToolRounds, rounds)
+}
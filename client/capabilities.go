@@ -0,0 +1,53 @@
+package client
+
+import "github.com/voocel/mcp-sdk-go/protocol"
+
+// The accessors below report what the server declared during initialization.
+// They must only be called after Connect has returned successfully; before that
+// the server has declared nothing and they report no support.
+
+// ProtocolVersion returns the protocol version negotiated with the server
+func (cs *ClientSession) ProtocolVersion() string {
+	result := cs.InitializeResult()
+	if result == nil {
+		return ""
+	}
+	return result.ProtocolVersion
+}
+
+// ServerCapabilities returns the capabilities the server declared during initialization
+func (cs *ClientSession) ServerCapabilities() protocol.ServerCapabilities {
+	result := cs.InitializeResult()
+	if result == nil {
+		return protocol.ServerCapabilities{}
+	}
+	return result.Capabilities
+}
+
+// ServerSupportsToolListChanged reports whether the server sends notifications/tools/list_changed
+func (cs *ClientSession) ServerSupportsToolListChanged() bool {
+	caps := cs.ServerCapabilities()
+	return caps.Tools != nil && caps.Tools.ListChanged
+}
+
+// ServerSupportsResourceSubscription reports whether the server accepts resources/subscribe
+func (cs *ClientSession) ServerSupportsResourceSubscription() bool {
+	caps := cs.ServerCapabilities()
+	return caps.Resources != nil && caps.Resources.Subscribe
+}
+
+// ServerSupportsPromptListChanged reports whether the server sends notifications/prompts/list_changed
+func (cs *ClientSession) ServerSupportsPromptListChanged() bool {
+	caps := cs.ServerCapabilities()
+	return caps.Prompts != nil && caps.Prompts.ListChanged
+}
+
+// ServerSupportsLogging reports whether the server accepts logging/setLevel and sends log messages
+func (cs *ClientSession) ServerSupportsLogging() bool {
+	return cs.ServerCapabilities().Logging != nil
+}
+
+// ServerSupportsCompletion reports whether the server accepts completion/complete
+func (cs *ClientSession) ServerSupportsCompletion() bool {
+	return cs.ServerCapabilities().Completion != nil
+}
@@ -2,13 +2,16 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/voocel/mcp-sdk-go/protocol"
 	"github.com/voocel/mcp-sdk-go/transport"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 type ClientInfo struct {
@@ -28,7 +31,8 @@ type ClientOptions struct {
 	// Setting this to a non-nil value causes the client to declare elicitation capability
 	ElicitationHandler func(context.Context, *protocol.ElicitationCreateParams) (*protocol.ElicitationResult, error)
 
-	// Notification handlers from server
+	// Notification handlers from server.
+	// ResourceUpdatedHandler receives updates of resources subscribed with ClientSession.SubscribeResource.
 	ToolListChangedHandler      func(context.Context, *protocol.ToolsListChangedNotification)
 	PromptListChangedHandler    func(context.Context, *protocol.PromptListChangedParams)
 	ResourceListChangedHandler  func(context.Context, *protocol.ResourceListChangedParams)
@@ -36,9 +40,29 @@ type ClientOptions struct {
 	LoggingMessageHandler       func(context.Context, *protocol.LoggingMessageParams)
 	ProgressNotificationHandler func(context.Context, *protocol.ProgressNotificationParams)
 
+	// ResourceChunkHandler receives the chunks of streamed resources (see server.AddStreamingResource),
+	// in order and before the corresponding ReadResource call returns
+	ResourceChunkHandler func(context.Context, *protocol.ResourceChunkNotificationParams)
+
 	// TaskStatusHandler handles notifications/tasks/status from the server (MCP 2025-11-25)
 	TaskStatusHandler func(context.Context, *protocol.TaskStatusNotificationParams)
 
+	// OnTokenRotated is called with each session token the server rotates in, after the
+	// connection started using it (see server.ServerOptions.TokenRotationInterval)
+	OnTokenRotated func(newToken string)
+
+	// DefaultLogLevel is sent with logging/setLevel after each initialization if the server
+	// supports logging. Empty leaves server log notifications off until SetLogLevel is called.
+	DefaultLogLevel protocol.LoggingLevel
+
+	// ProtocolVersion is the protocol version requested during initialization.
+	// Defaults to protocol.MCPVersion; the server may answer with an older version it supports.
+	ProtocolVersion string
+
+	// DisableDowngrade turns off retrying initialize with the next older supported
+	// protocol version when a server rejects the requested one with InvalidRequest.
+	DisableDowngrade bool
+
 	// KeepAlive defines the interval for periodic "ping" requests
 	// If the peer fails to respond to a keepalive-initiated ping, the session will automatically close
 	KeepAlive time.Duration
@@ -48,6 +72,20 @@ type ClientOptions struct {
 
 	// SamplingToolsEnabled enables tool use in sampling requests (MCP 2025-11-25)
 	SamplingToolsEnabled bool
+
+	// OnStateChange is called on every connection state transition of a session, usually
+	// from the goroutine making it; err is the cause of the transition if any. Calls are
+	// serialized and in transition order. It may call ClientSession.Close, whose transition
+	// is reported once it returns.
+	OnStateChange func(old, new ConnectionState, err error)
+
+	// AutoReconnect re-establishes the transport and repeats the initialize handshake
+	// when the connection drops unexpectedly. Nil disables reconnection.
+	AutoReconnect *ReconnectPolicy
+
+	// TracePropagator injects the trace context of each request's ctx into params._meta
+	// (e.g. propagation.TraceContext{} for W3C traceparent). Nil disables propagation.
+	TracePropagator propagation.TextMapPropagator
 }
 
 type Client struct {
@@ -127,10 +165,12 @@ func (c *Client) Connect(ctx context.Context, t transport.Transport, _ *ClientSe
 
 	cs := &ClientSession{
 		conn:             conn,
+		transport:        t,
 		client:           c,
 		waitErr:          make(chan error, 1),
 		pending:          make(map[string]*pendingRequest),
 		incomingRequests: make(map[string]context.CancelFunc),
+		subscriptions:    make(map[string]bool),
 	}
 
 	c.mu.Lock()
@@ -138,14 +178,34 @@ func (c *Client) Connect(ctx context.Context, t transport.Transport, _ *ClientSe
 	c.mu.Unlock()
 
 	go func() {
-		err := cs.handleMessages(ctx)
+		err := cs.run(ctx)
 		cs.waitErr <- err
 		close(cs.waitErr)
 	}()
 
-	// Perform initialization handshake
+	if err := cs.initialize(ctx); err != nil {
+		cs.setState(StateDisconnected, err)
+		_ = cs.Close()
+		return nil, err
+	}
+	cs.setState(StateConnected, nil)
+
+	if c.opts.KeepAlive > 0 {
+		cs.startKeepalive(c.opts.KeepAlive)
+	}
+
+	return cs, nil
+}
+
+// initialize performs the initialization handshake on the current connection
+func (cs *ClientSession) initialize(ctx context.Context) error {
+	c := cs.client
+	requested := c.opts.ProtocolVersion
+	if requested == "" {
+		requested = protocol.MCPVersion
+	}
 	initParams := &protocol.InitializeParams{
-		ProtocolVersion: protocol.MCPVersion,
+		ProtocolVersion: requested,
 		ClientInfo: protocol.ClientInfo{
 			Name:    c.info.Name,
 			Version: c.info.Version,
@@ -154,35 +214,62 @@ func (c *Client) Connect(ctx context.Context, t transport.Transport, _ *ClientSe
 	}
 
 	var initResult protocol.InitializeResult
-	if err := cs.sendRequest(ctx, protocol.MethodInitialize, initParams, &initResult); err != nil {
-		_ = cs.Close()
-		return nil, fmt.Errorf("initialize failed: %w", err)
+	for {
+		err := cs.sendRequest(ctx, protocol.MethodInitialize, initParams, &initResult)
+		if err == nil {
+			break
+		}
+		// Servers that predate the requested version may reject it instead of negotiating
+		older := olderProtocolVersion(requested)
+		var mcpErr *protocol.MCPError
+		if c.opts.DisableDowngrade || older == "" || !errors.As(err, &mcpErr) || mcpErr.Code != protocol.InvalidRequest {
+			return fmt.Errorf("initialize failed: %w", err)
+		}
+		slog.Warn("server rejected protocol version, retrying initialize with an older one",
+			"rejected", requested, "version", older, "error", err)
+		requested = older
+		initParams.ProtocolVersion = older
 	}
 
-	if !protocol.IsVersionSupported(initResult.ProtocolVersion) {
-		_ = cs.Close()
-		return nil, fmt.Errorf("unsupported protocol version: %s (supported: %v)",
+	// The server answers with the version to use, which must be one we support
+	// and no later than the one we asked for
+	if !protocol.IsVersionSupported(initResult.ProtocolVersion) ||
+		!protocol.ProtocolVersionAtLeast(requested, initResult.ProtocolVersion) {
+		return fmt.Errorf("unsupported protocol version: %s (supported: %v)",
 			initResult.ProtocolVersion, protocol.GetSupportedVersions())
 	}
 
+	cs.mu.Lock()
 	cs.state.InitializeResult = &initResult
+	cs.mu.Unlock()
 
-	if updater, ok := conn.(interface {
+	if updater, ok := cs.connection().(interface {
 		SessionUpdated(*protocol.InitializeResult)
 	}); ok {
 		updater.SessionUpdated(&initResult)
 	}
 
 	if err := cs.sendNotification(ctx, protocol.NotificationInitialized, &protocol.InitializedParams{}); err != nil {
-		_ = cs.Close()
-		return nil, fmt.Errorf("send initialized notification failed: %w", err)
+		return fmt.Errorf("send initialized notification failed: %w", err)
 	}
 
-	if c.opts.KeepAlive > 0 {
-		cs.startKeepalive(c.opts.KeepAlive)
+	if level := c.opts.DefaultLogLevel; level != "" && cs.ServerSupportsLogging() {
+		if err := cs.SetLogLevel(ctx, level); err != nil {
+			return fmt.Errorf("set default log level failed: %w", err)
+		}
 	}
 
-	return cs, nil
+	return nil
+}
+
+// olderProtocolVersion returns the newest supported version older than version, or ""
+func olderProtocolVersion(version string) string {
+	for _, v := range protocol.GetSupportedVersions() {
+		if v != version && protocol.ProtocolVersionAtLeast(version, v) {
+			return v
+		}
+	}
+	return ""
 }
 
 // AddRoot adds a root directory and notifies all sessions
@@ -241,9 +328,21 @@ type ClientSession struct {
 	calledOnClose atomic.Bool
 	onClose       func()
 
-	conn    transport.Connection
-	client  *Client
-	waitErr chan error
+	conn      transport.Connection
+	transport transport.Transport
+	client    *Client
+	waitErr   chan error
+	counters  transport.MessageCounter // messages of all connections of the session
+
+	// reconnect state
+	closed       atomic.Bool
+	reconnecting atomic.Bool
+
+	// connection state, see State; stateMu guards transitions and their OnStateChange queue
+	stateMu        sync.Mutex
+	connState      atomic.Int32
+	stateChanges   []stateChange // transitions not yet passed to OnStateChange
+	notifyingState bool          // a goroutine is delivering stateChanges
 
 	// keepalive
 	keepaliveCancel context.CancelFunc
@@ -251,10 +350,14 @@ type ClientSession struct {
 	// Session state
 	state clientSessionState
 
+	// Notification handlers set after Connect
+	handlers sessionHandlers
+
 	// Pending requests
 	mu               sync.Mutex
 	pending          map[string]*pendingRequest    // Requests sent by client
 	incomingRequests map[string]context.CancelFunc // Requests sent by server (for cancellation)
+	subscriptions    map[string]bool               // Subscribed resource URIs, renewed on reconnect
 	nextID           int64
 }
 
@@ -266,18 +369,52 @@ type pendingRequest struct {
 	method   string
 	response chan *protocol.JSONRPCMessage
 	err      chan error
+	raw      bool // deliver error responses as messages (used by Batch)
 }
 
 // InitializeResult returns the initialization result
 func (cs *ClientSession) InitializeResult() *protocol.InitializeResult {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 	return cs.state.InitializeResult
 }
 
 func (cs *ClientSession) ID() string {
-	return cs.conn.SessionID()
+	return cs.connection().SessionID()
+}
+
+// connection returns the current transport connection, which may change after a reconnect
+func (cs *ClientSession) connection() transport.Connection {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.conn
+}
+
+// write writes msg to the current connection, counting it
+func (cs *ClientSession) write(ctx context.Context, msg *protocol.JSONRPCMessage) error {
+	if err := cs.connection().Write(ctx, msg); err != nil {
+		return err
+	}
+	cs.counters.CountWritten(msg)
+	return nil
 }
 
+// BytesRead returns the size of the messages received from the server, encoded as JSON
+func (cs *ClientSession) BytesRead() int64 { return cs.counters.BytesRead() }
+
+// BytesWritten returns the size of the messages sent to the server, encoded as JSON
+func (cs *ClientSession) BytesWritten() int64 { return cs.counters.BytesWritten() }
+
+// MessagesRead returns the number of messages received from the server
+func (cs *ClientSession) MessagesRead() int64 { return cs.counters.MessagesRead() }
+
+// MessagesWritten returns the number of messages sent to the server
+func (cs *ClientSession) MessagesWritten() int64 { return cs.counters.MessagesWritten() }
+
 func (cs *ClientSession) Close() error {
+	cs.closed.Store(true)
+	cs.setState(StateDisconnected, nil)
+
 	if cs.keepaliveCancel != nil {
 		cs.keepaliveCancel()
 	}
@@ -303,7 +440,7 @@ func (cs *ClientSession) Close() error {
 		cancel()
 	}
 
-	err := cs.conn.Close()
+	err := cs.connection().Close()
 
 	if cs.onClose != nil && cs.calledOnClose.CompareAndSwap(false, true) {
 		cs.onClose()
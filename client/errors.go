@@ -0,0 +1,33 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// errorCodes holds the names of application-defined error codes, see RegisterErrorCode
+var errorCodes sync.Map
+
+// RegisterErrorCode names an application-defined JSON-RPC error code used by the servers
+// this process talks to. Errors returned for requests failing with a registered code
+// mention the name, and unwrap to a *protocol.MCPError as for any other code.
+// code must satisfy protocol.CheckCustomErrorCode.
+func RegisterErrorCode(code int, name string) error {
+	if err := protocol.CheckCustomErrorCode(code); err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("error code %d: empty name", code)
+	}
+	errorCodes.Store(code, name)
+	return nil
+}
+
+// ErrorCodeName returns the name registered for code, or "" if it is not registered
+func ErrorCodeName(code int) string {
+	name, _ := errorCodes.Load(code)
+	n, _ := name.(string)
+	return n
+}
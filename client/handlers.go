@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// sessionHandlers are notification handlers set on a ClientSession after Connect.
+// A non-nil handler takes precedence over the corresponding ClientOptions field.
+type sessionHandlers struct {
+	mu                  sync.RWMutex
+	toolListChanged     func(context.Context, *protocol.ToolsListChangedNotification)
+	promptListChanged   func(context.Context, *protocol.PromptListChangedParams)
+	resourceListChanged func(context.Context, *protocol.ResourceListChangedParams)
+	resourceUpdated     func(context.Context, *protocol.ResourceUpdatedNotificationParams)
+	loggingMessage      func(context.Context, *protocol.LoggingMessageParams)
+	progress            func(context.Context, *protocol.ProgressNotificationParams)
+}
+
+// The setters below replace, for this session only, the handler configured in ClientOptions.
+// They may be called at any time, concurrently with incoming notifications; passing nil
+// restores the ClientOptions handler.
+
+// SetToolListChangedHandler sets the handler for notifications/tools/list_changed
+func (cs *ClientSession) SetToolListChangedHandler(fn func(context.Context, *protocol.ToolsListChangedNotification)) {
+	cs.handlers.mu.Lock()
+	cs.handlers.toolListChanged = fn
+	cs.handlers.mu.Unlock()
+}
+
+// SetPromptListChangedHandler sets the handler for notifications/prompts/list_changed
+func (cs *ClientSession) SetPromptListChangedHandler(fn func(context.Context, *protocol.PromptListChangedParams)) {
+	cs.handlers.mu.Lock()
+	cs.handlers.promptListChanged = fn
+	cs.handlers.mu.Unlock()
+}
+
+// SetResourceListChangedHandler sets the handler for notifications/resources/list_changed
+func (cs *ClientSession) SetResourceListChangedHandler(fn func(context.Context, *protocol.ResourceListChangedParams)) {
+	cs.handlers.mu.Lock()
+	cs.handlers.resourceListChanged = fn
+	cs.handlers.mu.Unlock()
+}
+
+// SetResourceUpdatedHandler sets the handler for notifications/resources/updated
+func (cs *ClientSession) SetResourceUpdatedHandler(fn func(context.Context, *protocol.ResourceUpdatedNotificationParams)) {
+	cs.handlers.mu.Lock()
+	cs.handlers.resourceUpdated = fn
+	cs.handlers.mu.Unlock()
+}
+
+// SetLoggingMessageHandler sets the handler for notifications/message
+func (cs *ClientSession) SetLoggingMessageHandler(fn func(context.Context, *protocol.LoggingMessageParams)) {
+	cs.handlers.mu.Lock()
+	cs.handlers.loggingMessage = fn
+	cs.handlers.mu.Unlock()
+}
+
+// SetProgressNotificationHandler sets the handler for notifications/progress
+func (cs *ClientSession) SetProgressNotificationHandler(fn func(context.Context, *protocol.ProgressNotificationParams)) {
+	cs.handlers.mu.Lock()
+	cs.handlers.progress = fn
+	cs.handlers.mu.Unlock()
+}
+
+func (cs *ClientSession) toolListChangedHandler() func(context.Context, *protocol.ToolsListChangedNotification) {
+	cs.handlers.mu.RLock()
+	defer cs.handlers.mu.RUnlock()
+	if cs.handlers.toolListChanged != nil {
+		return cs.handlers.toolListChanged
+	}
+	return cs.client.opts.ToolListChangedHandler
+}
+
+func (cs *ClientSession) promptListChangedHandler() func(context.Context, *protocol.PromptListChangedParams) {
+	cs.handlers.mu.RLock()
+	defer cs.handlers.mu.RUnlock()
+	if cs.handlers.promptListChanged != nil {
+		return cs.handlers.promptListChanged
+	}
+	return cs.client.opts.PromptListChangedHandler
+}
+
+func (cs *ClientSession) resourceListChangedHandler() func(context.Context, *protocol.ResourceListChangedParams) {
+	cs.handlers.mu.RLock()
+	defer cs.handlers.mu.RUnlock()
+	if cs.handlers.resourceListChanged != nil {
+		return cs.handlers.resourceListChanged
+	}
+	return cs.client.opts.ResourceListChangedHandler
+}
+
+func (cs *ClientSession) resourceUpdatedHandler() func(context.Context, *protocol.ResourceUpdatedNotificationParams) {
+	cs.handlers.mu.RLock()
+	defer cs.handlers.mu.RUnlock()
+	if cs.handlers.resourceUpdated != nil {
+		return cs.handlers.resourceUpdated
+	}
+	return cs.client.opts.ResourceUpdatedHandler
+}
+
+func (cs *ClientSession) loggingMessageHandler() func(context.Context, *protocol.LoggingMessageParams) {
+	cs.handlers.mu.RLock()
+	defer cs.handlers.mu.RUnlock()
+	if cs.handlers.loggingMessage != nil {
+		return cs.handlers.loggingMessage
+	}
+	return cs.client.opts.LoggingMessageHandler
+}
+
+func (cs *ClientSession) progressNotificationHandler() func(context.Context, *protocol.ProgressNotificationParams) {
+	cs.handlers.mu.RLock()
+	defer cs.handlers.mu.RUnlock()
+	if cs.handlers.progress != nil {
+		return cs.handlers.progress
+	}
+	return cs.client.opts.ProgressNotificationHandler
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+)
+
+type ctxKeyRequestMeta struct{}
+
+// ContextWithRequestMeta returns a context whose requests carry meta in their _meta field,
+// e.g. trace or correlation IDs. Keys already set in the params' own _meta take precedence.
+func ContextWithRequestMeta(ctx context.Context, meta map[string]any) context.Context {
+	if existing := requestMetaFromContext(ctx); len(existing) > 0 {
+		merged := maps.Clone(existing)
+		maps.Copy(merged, meta)
+		meta = merged
+	}
+	return context.WithValue(ctx, ctxKeyRequestMeta{}, meta)
+}
+
+func requestMetaFromContext(ctx context.Context) map[string]any {
+	meta, _ := ctx.Value(ctxKeyRequestMeta{}).(map[string]any)
+	return meta
+}
+
+// mergeMeta adds meta to the _meta object of the request params. Existing keys are
+// replaced only if overwrite is set. Params that are not a JSON object are returned as is.
+func mergeMeta(params json.RawMessage, meta map[string]any, overwrite bool) json.RawMessage {
+	if len(meta) == 0 {
+		return params
+	}
+
+	fields := map[string]json.RawMessage{}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &fields); err != nil {
+			// Not an object, there is no _meta to carry the metadata
+			return params
+		}
+	}
+
+	merged := map[string]any{}
+	if raw, ok := fields["_meta"]; ok {
+		_ = json.Unmarshal(raw, &merged)
+	}
+	for k, v := range meta {
+		if _, exists := merged[k]; exists && !overwrite {
+			continue
+		}
+		merged[k] = v
+	}
+
+	metaJSON, err := json.Marshal(merged)
+	if err != nil {
+		return params
+	}
+	fields["_meta"] = metaJSON
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return params
+	}
+	return out
+}
@@ -1,7 +1,9 @@
 package client
 
 import (
+	"cmp"
 	"context"
+	"slices"
 
 	"github.com/voocel/mcp-sdk-go/protocol"
 )
@@ -15,7 +17,10 @@ func (cs *ClientSession) Ping(ctx context.Context, params *protocol.PingParams)
 	return cs.sendRequest(ctx, protocol.MethodPing, params, &result)
 }
 
-// ListTools lists the currently available tools on the server
+// ListTools lists the currently available tools on the server.
+// If the server paginates its tool list, pass the returned NextCursor as
+// params.Cursor to fetch the next page, until NextCursor is nil.
+// Tools of a page are ordered by descending Priority, keeping the server's order otherwise.
 func (cs *ClientSession) ListTools(ctx context.Context, params *protocol.ListToolsParams) (*protocol.ListToolsResult, error) {
 	if params == nil {
 		params = &protocol.ListToolsParams{}
@@ -24,6 +29,9 @@ func (cs *ClientSession) ListTools(ctx context.Context, params *protocol.ListToo
 	if err := cs.sendRequest(ctx, protocol.MethodToolsList, params, &result); err != nil {
 		return nil, err
 	}
+	slices.SortStableFunc(result.Tools, func(a, b protocol.Tool) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
 	return &result, nil
 }
 
@@ -36,7 +44,18 @@ func (cs *ClientSession) CallTool(ctx context.Context, params *protocol.CallTool
 	return &result, nil
 }
 
-// ListResources lists the currently available resources on the server
+// DryRunTool asks the server to validate params against the tool's input schema and
+// parameter validators without running it. Valid arguments yield a non-error result;
+// invalid ones a tool error result listing the violations.
+func (cs *ClientSession) DryRunTool(ctx context.Context, params *protocol.CallToolParams) (*protocol.CallToolResult, error) {
+	dryRun := *params
+	dryRun.DryRun = true
+	return cs.CallTool(ctx, &dryRun)
+}
+
+// ListResources lists the currently available resources on the server.
+// If the server paginates its resource list, pass the returned NextCursor as
+// params.Cursor to fetch the next page, until NextCursor is nil.
 func (cs *ClientSession) ListResources(ctx context.Context, params *protocol.ListResourcesParams) (*protocol.ListResourcesResult, error) {
 	if params == nil {
 		params = &protocol.ListResourcesParams{}
@@ -57,6 +76,27 @@ func (cs *ClientSession) ReadResource(ctx context.Context, params *protocol.Read
 	return &result, nil
 }
 
+// ReadResourceIfModified reads a resource unless it still has the given ETag. It returns
+// (nil, false, nil) if the contents are unchanged, otherwise the result and true; the
+// ETag of the returned contents can be passed to the next call. An empty etag always reads.
+func (cs *ClientSession) ReadResourceIfModified(ctx context.Context, uri, etag string) (*protocol.ReadResourceResult, bool, error) {
+	result, err := cs.ReadResource(ctx, &protocol.ReadResourceParams{URI: uri, IfNoneMatch: etag})
+	if err != nil {
+		return nil, false, err
+	}
+	if result.NotModified {
+		return nil, false, nil
+	}
+	return result, true, nil
+}
+
+// WriteResource replaces the contents of a writable resource (SDK extension, see
+// ServerCapabilities.Resources.Writable)
+func (cs *ClientSession) WriteResource(ctx context.Context, params *protocol.WriteResourceParams) error {
+	var result protocol.WriteResourceResult
+	return cs.sendRequest(ctx, protocol.MethodResourcesWrite, params, &result)
+}
+
 // ListResourceTemplates lists the resource templates on the server
 func (cs *ClientSession) ListResourceTemplates(ctx context.Context, params *protocol.ListResourceTemplatesParams) (*protocol.ListResourceTemplatesResult, error) {
 	if params == nil {
@@ -69,19 +109,50 @@ func (cs *ClientSession) ListResourceTemplates(ctx context.Context, params *prot
 	return &result, nil
 }
 
-// SubscribeResource subscribes to resource updates
+// SubscribeResource subscribes to updates of the resource at params.URI.
+// Each notifications/resources/updated the server sends for it is delivered to
+// ClientOptions.ResourceUpdatedHandler. Subscriptions are renewed automatically
+// when the session reconnects (see ClientOptions.AutoReconnect).
 func (cs *ClientSession) SubscribeResource(ctx context.Context, params *protocol.SubscribeParams) error {
 	var result protocol.EmptyResult
-	return cs.sendRequest(ctx, protocol.MethodResourcesSubscribe, params, &result)
+	if err := cs.sendRequest(ctx, protocol.MethodResourcesSubscribe, params, &result); err != nil {
+		return err
+	}
+
+	cs.mu.Lock()
+	cs.subscriptions[params.URI] = true
+	cs.mu.Unlock()
+	return nil
 }
 
-// UnsubscribeResource unsubscribes from resource updates
+// UnsubscribeResource cancels a subscription made with SubscribeResource
 func (cs *ClientSession) UnsubscribeResource(ctx context.Context, params *protocol.UnsubscribeParams) error {
 	var result protocol.EmptyResult
-	return cs.sendRequest(ctx, protocol.MethodResourcesUnsubscribe, params, &result)
+	if err := cs.sendRequest(ctx, protocol.MethodResourcesUnsubscribe, params, &result); err != nil {
+		return err
+	}
+
+	cs.mu.Lock()
+	delete(cs.subscriptions, params.URI)
+	cs.mu.Unlock()
+	return nil
 }
 
-// ListPrompts lists the currently available prompts on the server
+// Subscriptions returns the URIs of the resources the session is subscribed to
+func (cs *ClientSession) Subscriptions() []string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	uris := make([]string, 0, len(cs.subscriptions))
+	for uri := range cs.subscriptions {
+		uris = append(uris, uri)
+	}
+	return uris
+}
+
+// ListPrompts lists the currently available prompts on the server.
+// If the server paginates its prompt list, pass the returned NextCursor as
+// params.Cursor to fetch the next page, until NextCursor is nil.
 func (cs *ClientSession) ListPrompts(ctx context.Context, params *protocol.ListPromptsParams) (*protocol.ListPromptsResult, error) {
 	if params == nil {
 		params = &protocol.ListPromptsParams{}
@@ -108,6 +179,11 @@ func (cs *ClientSession) SetLoggingLevel(ctx context.Context, params *protocol.S
 	return cs.sendRequest(ctx, protocol.MethodLoggingSetLevel, params, &result)
 }
 
+// SetLogLevel asks the server to send log notifications at level and above
+func (cs *ClientSession) SetLogLevel(ctx context.Context, level protocol.LoggingLevel) error {
+	return cs.SetLoggingLevel(ctx, &protocol.SetLoggingLevelParams{Level: level})
+}
+
 // Complete requests auto-completion
 func (cs *ClientSession) Complete(ctx context.Context, params *protocol.CompleteRequest) (*protocol.CompleteResult, error) {
 	var result protocol.CompleteResult
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/transport"
+)
+
+const (
+	defaultReconnectInitialDelay = 500 * time.Millisecond
+	defaultReconnectMaxDelay     = 30 * time.Second
+	defaultReconnectMultiplier   = 2.0
+)
+
+// ReconnectPolicy configures automatic reconnection with exponential backoff
+type ReconnectPolicy struct {
+	// MaxAttempts is the maximum number of consecutive reconnect attempts, zero means unlimited
+	MaxAttempts int
+
+	// InitialDelay is the delay before the first attempt, defaults to 500ms
+	InitialDelay time.Duration
+
+	// MaxDelay caps the delay between attempts, defaults to 30s
+	MaxDelay time.Duration
+
+	// Multiplier grows the delay after each failed attempt, defaults to 2
+	Multiplier float64
+
+	// OnReconnect is called after every attempt, err is nil when the session was restored
+	OnReconnect func(attempt int, err error)
+}
+
+// Reconnecting reports whether the session is currently re-establishing its connection
+func (cs *ClientSession) Reconnecting() bool {
+	return cs.reconnecting.Load()
+}
+
+// run reads messages until the connection ends, reconnecting according to the policy
+func (cs *ClientSession) run(ctx context.Context) error {
+	readErr := cs.startReading(ctx)
+	for {
+		err := <-readErr
+		if !cs.shouldReconnect(ctx, err) {
+			cs.setState(StateDisconnected, err)
+			return err
+		}
+		cs.setState(StateReconnecting, err)
+		var rerr error
+		if readErr, rerr = cs.reconnect(ctx, err); rerr != nil {
+			cs.setState(StateDisconnected, rerr)
+			return rerr
+		}
+	}
+}
+
+// startReading handles the messages of the current connection in a new goroutine. The
+// returned channel receives the error that ended it.
+func (cs *ClientSession) startReading(ctx context.Context) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cs.handleMessages(ctx)
+	}()
+	return done
+}
+
+func (cs *ClientSession) shouldReconnect(ctx context.Context, err error) bool {
+	if cs.client.opts.AutoReconnect == nil || cs.closed.Load() || ctx.Err() != nil {
+		return false
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// reconnect dials the transport again with exponential backoff and swaps in the new connection.
+// An attempt only succeeds once the initialize handshake completes on the new connection;
+// a failed handshake counts as a failed attempt. It returns the channel that receives the
+// error ending the new connection's reader.
+func (cs *ClientSession) reconnect(ctx context.Context, cause error) (<-chan error, error) {
+	policy := cs.client.opts.AutoReconnect
+	cs.reconnecting.Store(true)
+	cs.failPending(fmt.Errorf("connection lost: %w", cause))
+
+	delay := policy.InitialDelay
+	if delay <= 0 {
+		delay = defaultReconnectInitialDelay
+	}
+	maxDelay := policy.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultReconnectMaxDelay
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultReconnectMultiplier
+	}
+
+	for attempt := 1; policy.MaxAttempts <= 0 || attempt <= policy.MaxAttempts; attempt++ {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			cs.reconnecting.Store(false)
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if cs.closed.Load() {
+			cs.reconnecting.Store(false)
+			return nil, cause
+		}
+
+		conn, err := cs.transport.Connect(ctx)
+		if err == nil {
+			cs.mu.Lock()
+			cs.conn = conn
+			cs.mu.Unlock()
+
+			readErr := cs.startReading(ctx)
+			if err = cs.reinitialize(ctx, conn, readErr); err == nil {
+				// Restored before OnReconnect runs, so the callback observes Reconnecting() == false
+				cs.reconnecting.Store(false)
+				if policy.OnReconnect != nil {
+					policy.OnReconnect(attempt, nil)
+				}
+				cs.setState(StateConnected, nil)
+				go cs.resubscribe(ctx)
+				return readErr, nil
+			}
+		}
+
+		if policy.OnReconnect != nil {
+			policy.OnReconnect(attempt, err)
+		}
+
+		delay = time.Duration(float64(delay) * multiplier)
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+
+	cs.reconnecting.Store(false)
+	return nil, fmt.Errorf("reconnect failed after %d attempts: %w", policy.MaxAttempts, cause)
+}
+
+// reinitialize repeats the initialize handshake on conn, a freshly reconnected connection
+// whose messages are read until readErr receives. On failure conn is closed and its
+// reader has stopped.
+func (cs *ClientSession) reinitialize(ctx context.Context, conn transport.Connection, readErr <-chan error) error {
+	initErr := make(chan error, 1)
+	go func() {
+		initErr <- cs.initialize(ctx)
+	}()
+
+	select {
+	case err := <-initErr:
+		if err != nil {
+			_ = conn.Close()
+			<-readErr
+		}
+		return err
+	case err := <-readErr:
+		// The connection ended before the handshake completed
+		cs.failPending(fmt.Errorf("connection lost: %w", err))
+		<-initErr
+		_ = conn.Close()
+		return fmt.Errorf("connection lost during initialize: %w", err)
+	}
+}
+
+// resubscribe renews the resource subscriptions lost with the previous connection
+func (cs *ClientSession) resubscribe(ctx context.Context) {
+	for _, uri := range cs.Subscriptions() {
+		var result protocol.EmptyResult
+		_ = cs.sendRequest(ctx, protocol.MethodResourcesSubscribe, &protocol.SubscribeParams{URI: uri}, &result)
+	}
+}
+
+// failPending fails all in-flight client requests, since their responses can no longer arrive
+func (cs *ClientSession) failPending(err error) {
+	cs.mu.Lock()
+	pending := cs.pending
+	cs.pending = make(map[string]*pendingRequest)
+	cs.mu.Unlock()
+
+	for _, req := range pending {
+		select {
+		case req.err <- err:
+		default:
+		}
+	}
+}
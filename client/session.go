@@ -9,10 +9,17 @@ import (
 	"time"
 
 	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/transport"
 )
 
-// sendRequest sends a request and waits for a response
+// sendRequest sends a request and waits for a response.
+// Metadata attached with ContextWithRequestMeta is added to the params' _meta.
 func (cs *ClientSession) sendRequest(ctx context.Context, method string, params interface{}, result interface{}) error {
+	return cs.sendRequestWithMeta(ctx, method, params, requestMetaFromContext(ctx), result)
+}
+
+// sendRequestWithMeta sends a request carrying meta in its _meta field and waits for a response
+func (cs *ClientSession) sendRequestWithMeta(ctx context.Context, method string, params interface{}, meta map[string]any, result interface{}) error {
 	cs.mu.Lock()
 	cs.nextID++
 	id := strconv.FormatInt(cs.nextID, 10)
@@ -32,6 +39,11 @@ func (cs *ClientSession) sendRequest(ctx context.Context, method string, params
 		}
 		msg.Params = paramsJSON
 	}
+	msg.Params = mergeMeta(msg.Params, meta, false)
+
+	if propagator := cs.client.opts.TracePropagator; propagator != nil {
+		msg.Params = injectTraceMeta(ctx, propagator, msg.Params)
+	}
 
 	pending := &pendingRequest{
 		method:   method,
@@ -43,7 +55,7 @@ func (cs *ClientSession) sendRequest(ctx context.Context, method string, params
 	cs.pending[id] = pending
 	cs.mu.Unlock()
 
-	if err := cs.conn.Write(ctx, msg); err != nil {
+	if err := cs.write(ctx, msg); err != nil {
 		cs.mu.Lock()
 		delete(cs.pending, id)
 		cs.mu.Unlock()
@@ -60,7 +72,7 @@ func (cs *ClientSession) sendRequest(ctx context.Context, method string, params
 		return err
 	case resp := <-pending.response:
 		if resp.Error != nil {
-			return fmt.Errorf("RPC error %d: %s", resp.Error.Code, resp.Error.Message)
+			return rpcError(resp.Error)
 		}
 
 		if result != nil && resp.Result != nil {
@@ -73,6 +85,15 @@ func (cs *ClientSession) sendRequest(ctx context.Context, method string, params
 	}
 }
 
+// rpcError converts a JSON-RPC error response into an error wrapping *protocol.MCPError,
+// which callers can inspect with errors.As or match against sentinel errors with errors.Is
+func rpcError(e *protocol.JSONRPCError) error {
+	if name := ErrorCodeName(e.Code); name != "" {
+		return fmt.Errorf("RPC error %d (%s): %w", e.Code, name, protocol.NewMCPError(e.Code, e.Message, e.Data))
+	}
+	return fmt.Errorf("RPC error %d: %w", e.Code, protocol.NewMCPError(e.Code, e.Message, e.Data))
+}
+
 // sendNotification sends a notification
 func (cs *ClientSession) sendNotification(ctx context.Context, method string, params interface{}) error {
 	msg := &protocol.JSONRPCMessage{
@@ -88,7 +109,7 @@ func (cs *ClientSession) sendNotification(ctx context.Context, method string, pa
 		msg.Params = paramsJSON
 	}
 
-	if err := cs.conn.Write(ctx, msg); err != nil {
+	if err := cs.write(ctx, msg); err != nil {
 		return fmt.Errorf("failed to write notification: %w", err)
 	}
 
@@ -97,6 +118,7 @@ func (cs *ClientSession) sendNotification(ctx context.Context, method string, pa
 
 // handleMessages handles messages from the server
 func (cs *ClientSession) handleMessages(ctx context.Context) error {
+	conn := cs.connection()
 	for {
 		select {
 		case <-ctx.Done():
@@ -104,20 +126,35 @@ func (cs *ClientSession) handleMessages(ctx context.Context) error {
 		default:
 		}
 
-		msg, err := cs.conn.Read(ctx)
+		msg, err := conn.Read(ctx)
 		if err != nil {
 			return err
 		}
+		cs.counters.CountRead(msg)
 
-		if msg.ID != nil {
-			cs.handleResponse(msg)
-			continue
-		}
+		cs.dispatch(ctx, msg)
+	}
+}
 
-		if msg.Method != "" {
-			cs.handleRequest(ctx, msg)
-			continue
+// dispatch routes a single message, or each member of a batch, to its handler
+func (cs *ClientSession) dispatch(ctx context.Context, msg *protocol.JSONRPCMessage) {
+	if msg.IsBatch() {
+		for _, m := range msg.Batch {
+			if m != nil && !m.IsBatch() {
+				cs.dispatch(ctx, m)
+			}
 		}
+		return
+	}
+
+	// Requests from the server carry an ID too; only messages without a method are responses
+	if msg.ID != nil && msg.Method == "" {
+		cs.handleResponse(msg)
+		return
+	}
+
+	if msg.Method != "" {
+		cs.handleRequest(ctx, msg)
 	}
 }
 
@@ -143,8 +180,8 @@ func (cs *ClientSession) handleResponse(msg *protocol.JSONRPCMessage) {
 		return
 	}
 
-	if msg.Error != nil {
-		pending.err <- fmt.Errorf("RPC error %d: %s", msg.Error.Code, msg.Error.Message)
+	if msg.Error != nil && !pending.raw {
+		pending.err <- rpcError(msg.Error)
 	} else {
 		pending.response <- msg
 	}
@@ -167,6 +204,8 @@ func (cs *ClientSession) handleRequest(ctx context.Context, msg *protocol.JSONRP
 		cs.handleResourceListChanged(ctx, msg)
 	case protocol.NotificationResourcesUpdated:
 		cs.handleResourceUpdated(ctx, msg)
+	case protocol.NotificationResourcesChunk:
+		cs.handleResourceChunk(ctx, msg)
 	case protocol.NotificationLoggingMessage:
 		cs.handleLoggingMessage(ctx, msg)
 	case protocol.NotificationProgress:
@@ -177,6 +216,8 @@ func (cs *ClientSession) handleRequest(ctx context.Context, msg *protocol.JSONRP
 		cs.handleListRoots(ctx, msg)
 	case protocol.NotificationTasksStatus:
 		cs.handleTaskStatus(ctx, msg)
+	case protocol.NotificationAuthTokenRotated:
+		cs.handleTokenRotated(msg)
 	}
 }
 
@@ -275,7 +316,8 @@ func (cs *ClientSession) handleListRoots(ctx context.Context, msg *protocol.JSON
 
 // handleToolListChanged handles tool list change notifications
 func (cs *ClientSession) handleToolListChanged(ctx context.Context, msg *protocol.JSONRPCMessage) {
-	if cs.client.opts.ToolListChangedHandler == nil {
+	handler := cs.toolListChangedHandler()
+	if handler == nil {
 		return
 	}
 
@@ -284,12 +326,13 @@ func (cs *ClientSession) handleToolListChanged(ctx context.Context, msg *protoco
 		return
 	}
 
-	cs.client.opts.ToolListChangedHandler(ctx, &params)
+	handler(ctx, &params)
 }
 
 // handlePromptListChanged handles prompt list change notifications
 func (cs *ClientSession) handlePromptListChanged(ctx context.Context, msg *protocol.JSONRPCMessage) {
-	if cs.client.opts.PromptListChangedHandler == nil {
+	handler := cs.promptListChangedHandler()
+	if handler == nil {
 		return
 	}
 
@@ -298,12 +341,13 @@ func (cs *ClientSession) handlePromptListChanged(ctx context.Context, msg *proto
 		return
 	}
 
-	cs.client.opts.PromptListChangedHandler(ctx, &params)
+	handler(ctx, &params)
 }
 
 // handleResourceListChanged handles resource list change notifications
 func (cs *ClientSession) handleResourceListChanged(ctx context.Context, msg *protocol.JSONRPCMessage) {
-	if cs.client.opts.ResourceListChangedHandler == nil {
+	handler := cs.resourceListChangedHandler()
+	if handler == nil {
 		return
 	}
 
@@ -312,12 +356,13 @@ func (cs *ClientSession) handleResourceListChanged(ctx context.Context, msg *pro
 		return
 	}
 
-	cs.client.opts.ResourceListChangedHandler(ctx, &params)
+	handler(ctx, &params)
 }
 
 // handleResourceUpdated handles resource update notifications
 func (cs *ClientSession) handleResourceUpdated(ctx context.Context, msg *protocol.JSONRPCMessage) {
-	if cs.client.opts.ResourceUpdatedHandler == nil {
+	handler := cs.resourceUpdatedHandler()
+	if handler == nil {
 		return
 	}
 
@@ -326,12 +371,27 @@ func (cs *ClientSession) handleResourceUpdated(ctx context.Context, msg *protoco
 		return
 	}
 
-	cs.client.opts.ResourceUpdatedHandler(ctx, &params)
+	handler(ctx, &params)
+}
+
+// handleResourceChunk handles streamed resource chunk notifications
+func (cs *ClientSession) handleResourceChunk(ctx context.Context, msg *protocol.JSONRPCMessage) {
+	if cs.client.opts.ResourceChunkHandler == nil {
+		return
+	}
+
+	var params protocol.ResourceChunkNotificationParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return
+	}
+
+	cs.client.opts.ResourceChunkHandler(ctx, &params)
 }
 
 // handleLoggingMessage handles logging message notifications
 func (cs *ClientSession) handleLoggingMessage(ctx context.Context, msg *protocol.JSONRPCMessage) {
-	if cs.client.opts.LoggingMessageHandler == nil {
+	handler := cs.loggingMessageHandler()
+	if handler == nil {
 		return
 	}
 
@@ -340,12 +400,13 @@ func (cs *ClientSession) handleLoggingMessage(ctx context.Context, msg *protocol
 		return
 	}
 
-	cs.client.opts.LoggingMessageHandler(ctx, &params)
+	handler(ctx, &params)
 }
 
 // handleProgressNotification handles progress notifications
 func (cs *ClientSession) handleProgressNotification(ctx context.Context, msg *protocol.JSONRPCMessage) {
-	if cs.client.opts.ProgressNotificationHandler == nil {
+	handler := cs.progressNotificationHandler()
+	if handler == nil {
 		return
 	}
 
@@ -354,7 +415,7 @@ func (cs *ClientSession) handleProgressNotification(ctx context.Context, msg *pr
 		return
 	}
 
-	cs.client.opts.ProgressNotificationHandler(ctx, &params)
+	handler(ctx, &params)
 }
 
 // handleCancelled handles cancellation notifications
@@ -399,6 +460,22 @@ func (cs *ClientSession) handleTaskStatus(ctx context.Context, msg *protocol.JSO
 	cs.client.opts.TaskStatusHandler(ctx, &params)
 }
 
+// handleTokenRotated passes a rotated session token to the connection, which uses it
+// for subsequent requests
+func (cs *ClientSession) handleTokenRotated(msg *protocol.JSONRPCMessage) {
+	var params protocol.TokenRotatedNotificationParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil || params.Token == "" {
+		return
+	}
+
+	if setter, ok := cs.connection().(transport.SessionTokenSetter); ok {
+		setter.SetSessionToken(params.Token)
+	}
+	if cs.client.opts.OnTokenRotated != nil {
+		cs.client.opts.OnTokenRotated(params.Token)
+	}
+}
+
 // sendSuccessResponse sends a success response
 func (cs *ClientSession) sendSuccessResponse(ctx context.Context, req *protocol.JSONRPCMessage, result interface{}) {
 	if req.ID == nil {
@@ -417,7 +494,7 @@ func (cs *ClientSession) sendSuccessResponse(ctx context.Context, req *protocol.
 				Message: fmt.Sprintf("Failed to marshal result: %v", err),
 			},
 		}
-		if writeErr := cs.conn.Write(ctx, errResp); writeErr != nil {
+		if writeErr := cs.write(ctx, errResp); writeErr != nil {
 			fmt.Fprintf(os.Stderr, "[ERROR] Failed to write error response: %v\n", writeErr)
 		}
 		return
@@ -429,7 +506,7 @@ func (cs *ClientSession) sendSuccessResponse(ctx context.Context, req *protocol.
 		Result:  resultJSON,
 	}
 
-	if err := cs.conn.Write(ctx, resp); err != nil {
+	if err := cs.write(ctx, resp); err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] Failed to write response: %v\n", err)
 	}
 }
@@ -449,7 +526,7 @@ func (cs *ClientSession) sendErrorResponse(ctx context.Context, req *protocol.JS
 		},
 	}
 
-	if err := cs.conn.Write(ctx, resp); err != nil {
+	if err := cs.write(ctx, resp); err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] Failed to write error response: %v\n", err)
 	}
 }
@@ -473,7 +550,11 @@ func (cs *ClientSession) startKeepalive(interval time.Duration) {
 				cancel()
 
 				if err != nil {
-					// Ping failed, close connection
+					// Ping failed, drop the connection and let auto-reconnect recover it if enabled
+					if cs.client.opts.AutoReconnect != nil {
+						_ = cs.connection().Close()
+						continue
+					}
 					_ = cs.Close()
 					return
 				}
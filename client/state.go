@@ -0,0 +1,80 @@
+package client
+
+// ConnectionState is the connection state of a ClientSession
+type ConnectionState int32
+
+const (
+	// StateConnecting is the state of a session until its first initialize handshake completes
+	StateConnecting ConnectionState = iota
+	// StateConnected means the session is initialized and usable
+	StateConnected
+	// StateReconnecting means the connection was lost and AutoReconnect is re-establishing it
+	StateReconnecting
+	// StateDisconnected is final: the session was closed or could not (re)connect
+	StateDisconnected
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateDisconnected:
+		return "disconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the current connection state of the session
+func (cs *ClientSession) State() ConnectionState {
+	return ConnectionState(cs.connState.Load())
+}
+
+// stateChange is a transition waiting to be passed to ClientOptions.OnStateChange
+type stateChange struct {
+	old, new ConnectionState
+	err      error
+}
+
+// setState moves the session to state and calls ClientOptions.OnStateChange without
+// holding stateMu, so the handler may call Close. err is the cause of the transition,
+// if any. Transitions out of StateDisconnected are ignored, so a late reconnect cannot
+// revive a closed session.
+//
+// Transitions are queued in order under stateMu. The goroutine making one delivers the
+// queue, unless a delivery is already running, e.g. because the handler itself called
+// Close; that goroutine then delivers the new transition once the handler returns.
+func (cs *ClientSession) setState(state ConnectionState, err error) {
+	cs.stateMu.Lock()
+	old := cs.State()
+	if old == state || old == StateDisconnected {
+		cs.stateMu.Unlock()
+		return
+	}
+	cs.connState.Store(int32(state))
+
+	fn := cs.client.opts.OnStateChange
+	if fn == nil {
+		cs.stateMu.Unlock()
+		return
+	}
+	cs.stateChanges = append(cs.stateChanges, stateChange{old: old, new: state, err: err})
+	if cs.notifyingState {
+		cs.stateMu.Unlock()
+		return
+	}
+	cs.notifyingState = true
+	for len(cs.stateChanges) > 0 {
+		change := cs.stateChanges[0]
+		cs.stateChanges = cs.stateChanges[1:]
+		cs.stateMu.Unlock()
+		fn(change.old, change.new, change.err)
+		cs.stateMu.Lock()
+	}
+	cs.notifyingState = false
+	cs.stateMu.Unlock()
+}
@@ -0,0 +1,20 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// injectTraceMeta adds the W3C trace context of ctx to the _meta object of the request params
+func injectTraceMeta(ctx context.Context, propagator propagation.TextMapPropagator, params json.RawMessage) json.RawMessage {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	meta := make(map[string]any, len(carrier))
+	for k, v := range carrier {
+		meta[k] = v
+	}
+	return mergeMeta(params, meta, true)
+}
@@ -0,0 +1,60 @@
+// Command schema-diff compares two versions of a tool input schema and prints the changes.
+//
+//	schema-diff old.json new.json
+//
+// Each file holds a JSON Schema, or a tool definition whose inputSchema is compared.
+// The exit status is 1 if a change is breaking, 2 on usage or read errors.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: schema-diff old.json new.json")
+		os.Exit(2)
+	}
+	oldSchema, err := loadSchema(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	newSchema, err := loadSchema(os.Args[2])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	changes := protocol.CompareSchemas(oldSchema, newSchema)
+	if changes.IsEmpty() {
+		fmt.Println("No changes")
+		return
+	}
+	for _, line := range changes.Descriptions() {
+		fmt.Println(line)
+	}
+	if changes.IsBreaking() {
+		os.Exit(1)
+	}
+}
+
+// loadSchema reads a schema, or the input schema of a tool definition, from path
+func loadSchema(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if input, ok := schema["inputSchema"].(map[string]interface{}); ok {
+		return input, nil
+	}
+	return schema, nil
+}
@@ -281,6 +281,7 @@ func printToolResult(result *protocol.CallToolResult) {
 	for _, content := range result.Content {
 		if textContent, ok := content.(protocol.TextContent); ok {
 			fmt.Printf("Result: %s\n", textContent.Text)
+			printAnnotation(textContent.Annotations)
 		}
 	}
 	if len(result.Meta) > 0 {
@@ -290,3 +291,18 @@ func printToolResult(result *protocol.CallToolResult) {
 		fmt.Printf("Structured Content: %v\n", result.StructuredContent)
 	}
 }
+
+func printAnnotation(ann *protocol.Annotation) {
+	if ann == nil {
+		return
+	}
+	if len(ann.Audience) > 0 {
+		fmt.Printf("  Audience: %v\n", ann.Audience)
+	}
+	if ann.Priority != 0 {
+		fmt.Printf("  Priority: %.1f\n", ann.Priority)
+	}
+	if ann.LastModified != "" {
+		fmt.Printf("  Last modified: %s\n", ann.LastModified)
+	}
+}
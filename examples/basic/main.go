@@ -42,7 +42,8 @@ func main() {
 				return protocol.NewToolResultError("Parameter 'name' must be a string"), nil
 			}
 			greeting := fmt.Sprintf("Hello, %s! Welcome to MCP!", name)
-			return protocol.NewToolResultText(greeting), nil
+			// The greeting is meant to be shown to the user rather than consumed by the model
+			return protocol.NewAnnotatedToolResultText(greeting, protocol.NewAnnotation().WithAudience(protocol.RoleUser)), nil
 		},
 	)
 
@@ -51,24 +52,12 @@ func main() {
 		&protocol.Tool{
 			Name:        "calculate",
 			Description: "Perform mathematical calculations",
-			InputSchema: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"operation": map[string]interface{}{
-						"type":        "string",
-						"description": "Operation type (add, subtract, multiply, divide)",
-					},
-					"a": map[string]interface{}{
-						"type":        "number",
-						"description": "First number",
-					},
-					"b": map[string]interface{}{
-						"type":        "number",
-						"description": "Second number",
-					},
-				},
-				"required": []string{"operation", "a", "b"},
-			},
+			InputSchema: protocol.NewToolInputSchema(
+				protocol.EnumParameter("operation", "Operation type",
+					[]string{"add", "subtract", "multiply", "divide"}, true),
+				protocol.NumberParameter("a", "First number", true),
+				protocol.NumberParameter("b", "Second number", true),
+			),
 		},
 		func(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
 			operation, _ := req.Params.Arguments["operation"].(string)
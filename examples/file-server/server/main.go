@@ -13,6 +13,7 @@ import (
 	"syscall"
 
 	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/protocol/mime"
 	"github.com/voocel/mcp-sdk-go/server"
 	"github.com/voocel/mcp-sdk-go/transport/sse"
 )
@@ -39,6 +40,7 @@ func main() {
 		&protocol.Tool{
 			Name:        "list_directory",
 			Description: "List files in a specified directory",
+			Annotations: protocol.NewToolAnnotation().AsReadOnly().AsIdempotent(),
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -104,6 +106,7 @@ func main() {
 		&protocol.Tool{
 			Name:        "read_file",
 			Description: "Read file content",
+			Annotations: protocol.NewToolAnnotation().AsReadOnly().AsIdempotent(),
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -138,6 +141,14 @@ func main() {
 				return protocol.NewToolResultError(fmt.Sprintf("Cannot read file: %v", err)), nil
 			}
 
+			// Binary files (images, PDFs, ...) are returned as an embedded base64 blob
+			if !isTextFile(path) {
+				mimeType := mime.DetectMimeType(content, path)
+				uri := "file://" + filepath.ToSlash(path)
+				blob := protocol.NewEmbeddedResourceContent(protocol.NewBlobResourceContents(uri, mimeType, content))
+				return protocol.NewToolResult([]protocol.Content{blob}, false), nil
+			}
+
 			return protocol.NewToolResultText(string(content)), nil
 		},
 	)
@@ -147,6 +158,7 @@ func main() {
 		&protocol.Tool{
 			Name:        "search_files",
 			Description: "Search for files containing specific content",
+			Annotations: protocol.NewToolAnnotation().AsReadOnly().AsIdempotent(),
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -238,12 +250,16 @@ func main() {
 	handler := sse.NewHTTPHandler(func(r *http.Request) *server.Server {
 		return mcpServer
 	})
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	mux.Handle("/openapi.json", mcpServer.OpenAPIHandler())
 	httpServer := &http.Server{
 		Addr:    ":8081",
-		Handler: handler,
+		Handler: mux,
 	}
 
 	log.Println("Starting File Server MCP Service (SSE) on port :8081...")
+	log.Println("OpenAPI description: http://localhost:8081/openapi.json")
 
 	go func() {
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/server"
+	"github.com/voocel/mcp-sdk-go/transport/stdio"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signalCh
+		log.Println("Received shutdown signal")
+		cancel()
+	}()
+
+	mcpServer := server.NewServer(&protocol.ServerInfo{
+		Name:    "Modular Demo",
+		Version: "1.0.0",
+	}, nil)
+
+	// Exposes the tools "weather/forecast" and "notes/add", the resource
+	// "weather://cities" and the prompt "notes/review"
+	if err := mcpServer.Mount("weather", newWeatherServer()); err != nil {
+		log.Fatalf("Mount weather: %v", err)
+	}
+	if err := mcpServer.Mount("notes", newNotesServer()); err != nil {
+		log.Fatalf("Mount notes: %v", err)
+	}
+
+	log.Println("Starting Modular MCP Server (STDIO)...")
+
+	if err := mcpServer.Run(ctx, &stdio.StdioTransport{}); err != nil && err != context.Canceled {
+		log.Fatalf("Server error: %v", err)
+	}
+
+	log.Println("Server closed")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/server"
+)
+
+// newNotesServer registers the note taking features, independently of the main server
+func newNotesServer() *server.Server {
+	s := server.NewServer(&protocol.ServerInfo{Name: "notes", Version: "1.0.0"}, nil)
+
+	var (
+		mu    sync.Mutex
+		notes []string
+	)
+	s.AddTool(
+		&protocol.Tool{
+			Name:        "add",
+			Description: "Add a note",
+			InputSchema: protocol.NewToolInputSchema(
+				protocol.StringParameter("text", "Note text", true),
+			),
+		},
+		func(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
+			text, _ := req.Params.Arguments["text"].(string)
+			mu.Lock()
+			notes = append(notes, text)
+			mu.Unlock()
+			return protocol.NewToolResultText("Note added"), nil
+		},
+	)
+
+	s.AddPrompt(
+		&protocol.Prompt{Name: "review", Description: "Review all notes"},
+		func(ctx context.Context, req *server.GetPromptRequest) (*protocol.GetPromptResult, error) {
+			mu.Lock()
+			text := strings.Join(notes, "\n")
+			mu.Unlock()
+			return protocol.NewGetPromptResult("Review notes",
+				protocol.NewPromptMessage(protocol.RoleUser, protocol.NewTextContent("Summarize these notes:\n"+text))), nil
+		},
+	)
+	return s
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/server"
+)
+
+// newWeatherServer registers the weather features, independently of the main server
+func newWeatherServer() *server.Server {
+	s := server.NewServer(&protocol.ServerInfo{Name: "weather", Version: "1.0.0"}, nil)
+
+	s.AddTool(
+		&protocol.Tool{
+			Name:        "forecast",
+			Description: "Get the forecast for a city",
+			InputSchema: protocol.NewToolInputSchema(
+				protocol.StringParameter("city", "City name", true),
+			),
+		},
+		func(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
+			city, _ := req.Params.Arguments["city"].(string)
+			return protocol.NewToolResultText(fmt.Sprintf("%s: sunny, 22°C", city)), nil
+		},
+	)
+
+	s.AddResource(
+		&protocol.Resource{URI: "cities", Name: "cities", MimeType: "text/plain"},
+		func(ctx context.Context, req *server.ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+			return protocol.NewReadResourceResult(protocol.NewTextResourceContents(req.Params.URI, "Paris\nTokyo\nLima")), nil
+		},
+	)
+	return s
+}
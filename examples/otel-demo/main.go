@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/client"
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/server"
+	"github.com/voocel/mcp-sdk-go/transport/streamable"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// This demo runs an MCP server and client in one process and prints the spans of
+// a traced tool call. The client span and the server span share the same trace ID,
+// because the client propagates the W3C trace context in params._meta.
+func main() {
+	exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+	if err != nil {
+		log.Fatalf("Failed to create exporter: %v", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	// ========== Server ==========
+	mcpServer := server.NewServer(&protocol.ServerInfo{
+		Name:    "OTelDemoServer",
+		Version: "1.0.0",
+	}, &server.ServerOptions{
+		Middlewares: []server.Middleware{
+			server.NewTracingMiddleware(tp.Tracer("mcp-server")),
+		},
+	})
+
+	mcpServer.AddTool(
+		&protocol.Tool{
+			Name:        "slow_echo",
+			Description: "Echo the message after a short delay",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"message": map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"message"},
+			},
+		},
+		func(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
+			time.Sleep(50 * time.Millisecond)
+			message, _ := req.Params.Arguments["message"].(string)
+			return protocol.NewToolResultText(message), nil
+		},
+	)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	handler := streamable.NewHTTPHandler(func(*http.Request) *server.Server {
+		return mcpServer
+	})
+	httpServer := &http.Server{Handler: handler}
+	go func() {
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Server error: %v", err)
+		}
+	}()
+	defer httpServer.Close()
+
+	// ========== Client ==========
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t, err := streamable.NewStreamableClientTransport(fmt.Sprintf("http://%s", listener.Addr()))
+	if err != nil {
+		log.Fatalf("Failed to create transport: %v", err)
+	}
+
+	mcpClient := client.NewClient(&client.ClientInfo{
+		Name:    "OTelDemoClient",
+		Version: "1.0.0",
+	}, &client.ClientOptions{
+		TracePropagator: propagation.TraceContext{},
+	})
+
+	session, err := mcpClient.Connect(ctx, t, nil)
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer session.Close()
+
+	ctx, span := tp.Tracer("mcp-client").Start(ctx, "demo request")
+	result, err := session.CallTool(ctx, &protocol.CallToolParams{
+		Name:      "slow_echo",
+		Arguments: map[string]any{"message": "hello, tracing"},
+	})
+	span.End()
+	if err != nil {
+		log.Fatalf("Tool call failed: %v", err)
+	}
+
+	if text, ok := result.Content[0].(protocol.TextContent); ok {
+		fmt.Printf("Tool result: %s\n", text.Text)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/server"
+	"github.com/voocel/mcp-sdk-go/transport/stdio"
+)
+
+// validateInput checks the text argument and passes it on normalized
+func validateInput(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
+	text, ok := req.Params.Arguments["text"].(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return protocol.NewToolResultError("Parameter 'text' must be a non-empty string"), nil
+	}
+	return protocol.NewToolResultText(strings.Join(strings.Fields(text), " ")), nil
+}
+
+// processText works on the output of the validation step
+func processText(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
+	input := server.PipelineInputFromContext(ctx)
+	if input == nil || len(input.Content) == 0 {
+		return protocol.NewToolResultError("No input to process"), nil
+	}
+	text := input.Content[0].(protocol.TextContent).Text
+	if len(text) > 80 {
+		return nil, fmt.Errorf("text too long for the detailed report: %d characters", len(text))
+	}
+	return protocol.NewToolResultText(fmt.Sprintf("%d words, %d characters: %s",
+		len(strings.Fields(text)), len(text), strings.ToUpper(text))), nil
+}
+
+// summarize is a cheaper report used when processing fails
+func summarize(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
+	text, _ := req.Params.Arguments["text"].(string)
+	return protocol.NewToolResultText(fmt.Sprintf("%d words", len(strings.Fields(text)))), nil
+}
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signalCh
+		log.Println("Received shutdown signal")
+		cancel()
+	}()
+
+	mcpServer := server.NewServer(&protocol.ServerInfo{
+		Name:    "Pipeline Demo",
+		Version: "1.0.0",
+	}, nil)
+
+	// Invalid input stops the pipeline; a failed processing step falls back to a summary
+	mcpServer.AddTool(
+		&protocol.Tool{
+			Name:        "analyze_text",
+			Description: "Validate text, then report on it",
+			InputSchema: protocol.NewToolInputSchema(
+				protocol.StringParameter("text", "Text to analyze", true),
+			),
+		},
+		server.Fallback(
+			server.Pipeline(validateInput, processText),
+			server.Pipeline(validateInput, summarize),
+		),
+	)
+
+	log.Println("Starting Pipeline MCP Server (STDIO)...")
+
+	if err := mcpServer.Run(ctx, &stdio.StdioTransport{}); err != nil && err != context.Canceled {
+		log.Fatalf("Server error: %v", err)
+	}
+
+	log.Println("Server closed")
+}
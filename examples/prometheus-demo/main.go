@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/server"
+	"github.com/voocel/mcp-sdk-go/transport/sse"
+)
+
+func main() {
+	registry := prometheus.NewRegistry()
+
+	mcpServer := server.NewServer(&protocol.ServerInfo{
+		Name:    "PrometheusDemoServer",
+		Version: "1.0.0",
+	}, nil)
+
+	// Record tool call and resource read metrics
+	mcpServer.UsePrometheus(registry)
+
+	mcpServer.AddTool(
+		&protocol.Tool{
+			Name:        "greet",
+			Description: "Greet the user",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "User name",
+					},
+				},
+				"required": []string{"name"},
+			},
+		},
+		func(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
+			name, _ := req.Params.Arguments["name"].(string)
+			return protocol.NewToolResultText("Hello, " + name + "!"), nil
+		},
+	)
+
+	mcpServer.AddResource(
+		&protocol.Resource{
+			URI:      "info://server",
+			Name:     "server_info",
+			MimeType: "text/plain",
+		},
+		func(ctx context.Context, req *server.ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+			contents := protocol.NewTextResourceContents("info://server", "Prometheus demo server")
+			return protocol.NewReadResourceResult(contents), nil
+		},
+	)
+
+	handler := sse.NewHTTPHandler(func(r *http.Request) *server.Server {
+		return mcpServer
+	})
+
+	// Serve the MCP endpoint and the metrics side by side
+	mux := http.NewServeMux()
+	mux.Handle("/", sse.NewMetricsHandler(registry)(handler))
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	httpServer := &http.Server{
+		Addr:    ":8080",
+		Handler: mux,
+	}
+
+	go func() {
+		log.Println("MCP endpoint: http://localhost:8080")
+		log.Println("Metrics:      http://localhost:8080/metrics")
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("HTTP server error: %v", err)
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	<-sigChan
+
+	log.Println("Shutting down server...")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	if err := handler.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Handler shutdown error: %v", err)
+	}
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown error: %v", err)
+	}
+}
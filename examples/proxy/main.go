@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/client"
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/server"
+	"github.com/voocel/mcp-sdk-go/transport/streamable"
+)
+
+// This demo federates two domain-specific backends ("fs" and "db") behind a single
+// MCP endpoint on :8080. Clients see tools such as "fs:list_files" and "db:query";
+// calls are routed to the owning backend with the namespace stripped.
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// ========== Backends ==========
+	fsURL := serveBackend(newFileBackend())
+	dbURL := serveBackend(newDatabaseBackend())
+
+	// ========== Proxy ==========
+	proxyServer := server.NewServer(&protocol.ServerInfo{
+		Name:    "ProxyServer",
+		Version: "1.0.0",
+	}, nil)
+
+	var proxy *server.Proxy
+	backendClient := client.NewClient(&client.ClientInfo{
+		Name:    "proxy",
+		Version: "1.0.0",
+	}, &client.ClientOptions{
+		// Keep the federated catalog in sync with the backends
+		ToolListChangedHandler: func(ctx context.Context, _ *protocol.ToolsListChangedNotification) {
+			if proxy != nil {
+				go func() { _ = proxy.Refresh(context.Background()) }()
+			}
+		},
+	})
+
+	connectCtx, connectCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer connectCancel()
+
+	var backends []*server.RemoteServer
+	for namespace, url := range map[string]string{"fs:": fsURL, "db:": dbURL} {
+		t, err := streamable.NewStreamableClientTransport(url)
+		if err != nil {
+			log.Fatalf("Failed to create transport for %s: %v", namespace, err)
+		}
+		session, err := backendClient.Connect(connectCtx, t, nil)
+		if err != nil {
+			log.Fatalf("Failed to connect to backend %s: %v", namespace, err)
+		}
+		defer session.Close()
+		backends = append(backends, &server.RemoteServer{Namespace: namespace, Session: session})
+	}
+
+	proxy = server.NewProxy(proxyServer, backends...)
+	if err := proxy.Refresh(connectCtx); err != nil {
+		log.Printf("Some backends are unavailable: %v", err)
+	}
+
+	handler := streamable.NewHTTPHandler(func(*http.Request) *server.Server {
+		return proxyServer
+	})
+	httpServer := &http.Server{Addr: ":8080", Handler: handler}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	log.Println("MCP proxy (Streamable HTTP) listening on :8080, serving tools:")
+	for _, b := range backends {
+		tools, err := b.Session.ListTools(connectCtx, nil)
+		if err != nil {
+			continue
+		}
+		for _, tool := range tools.Tools {
+			log.Printf("  %s%s - %s", b.Namespace, tool.Name, tool.Description)
+		}
+	}
+
+	<-ctx.Done()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	_ = httpServer.Shutdown(shutdownCtx)
+	log.Println("Proxy shutdown")
+}
+
+// serveBackend serves s over Streamable HTTP on a random local port and returns its URL
+func serveBackend(s *server.Server) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	handler := streamable.NewHTTPHandler(func(*http.Request) *server.Server {
+		return s
+	})
+	go func() {
+		if err := http.Serve(listener, handler); err != nil {
+			log.Printf("Backend error: %v", err)
+		}
+	}()
+	return fmt.Sprintf("http://%s", listener.Addr())
+}
+
+func newFileBackend() *server.Server {
+	s := server.NewServer(&protocol.ServerInfo{Name: "FileBackend", Version: "1.0.0"}, nil)
+
+	s.AddTool(
+		&protocol.Tool{
+			Name:        "list_files",
+			Description: "List files in the working directory",
+			InputSchema: map[string]interface{}{"type": "object"},
+		},
+		func(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
+			entries, err := os.ReadDir(".")
+			if err != nil {
+				return protocol.NewToolResultError(err.Error()), nil
+			}
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			return protocol.NewToolResultText(strings.Join(names, "\n")), nil
+		},
+	)
+
+	s.AddResource(
+		&protocol.Resource{
+			URI:      "file://cwd",
+			Name:     "Working directory",
+			MimeType: "text/plain",
+		},
+		func(ctx context.Context, req *server.ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+			dir, _ := os.Getwd()
+			return protocol.NewReadResourceResult(protocol.NewTextResourceContents("file://cwd", dir)), nil
+		},
+	)
+	return s
+}
+
+func newDatabaseBackend() *server.Server {
+	s := server.NewServer(&protocol.ServerInfo{Name: "DatabaseBackend", Version: "1.0.0"}, nil)
+
+	users := map[string]string{"1": "alice", "2": "bob"}
+	s.AddTool(
+		&protocol.Tool{
+			Name:        "query",
+			Description: "Look up a user by ID",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"id"},
+			},
+		},
+		func(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
+			id, _ := req.Params.Arguments["id"].(string)
+			name, ok := users[id]
+			if !ok {
+				return protocol.NewToolResultError(fmt.Sprintf("user %s not found", id)), nil
+			}
+			return protocol.NewToolResultText(name), nil
+		},
+	)
+
+	s.AddPrompt(
+		&protocol.Prompt{Name: "report", Description: "Summarize the user table"},
+		func(ctx context.Context, req *server.GetPromptRequest) (*protocol.GetPromptResult, error) {
+			return protocol.NewGetPromptResult("User report",
+				protocol.NewPromptMessage(protocol.RoleUser, protocol.NewTextContent(fmt.Sprintf("Summarize %d users", len(users))))), nil
+		},
+	)
+	return s
+}
@@ -0,0 +1,194 @@
+// Package mcptest provides an in-process server and client pair for unit testing MCP
+// integrations without starting a subprocess or an HTTP server.
+//
+//	s := mcptest.NewMockServer(t)
+//	s.MustRegisterTool(t, tool, handler)
+//	cs := mcptest.NewMockClient(t).Connect(s)
+//	cs.CallTool(ctx, &protocol.CallToolParams{Name: tool.Name})
+//	call := s.WaitForCall(tool.Name, time.Second)
+package mcptest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/client"
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/server"
+	"github.com/voocel/mcp-sdk-go/transport"
+)
+
+// notificationBuffer is the capacity of the Notifications channel; further
+// notifications are dropped until the test receives some
+const notificationBuffer = 100
+
+// CallRecord is a completed tools/call handled by a MockServer
+type CallRecord struct {
+	Name      string
+	Arguments map[string]any
+	Result    *protocol.CallToolResult
+	Err       error
+	Time      time.Time
+}
+
+// MockServer is a server for tests. Every tool call it handles is recorded, see WaitForCall.
+type MockServer struct {
+	*server.Server
+
+	// Notifications receives every notification the server sends to its clients
+	Notifications chan *protocol.JSONRPCMessage
+
+	mu      sync.Mutex
+	calls   []*CallRecord
+	waited  int           // calls already returned by WaitForCall
+	changed chan struct{} // closed when a call is recorded
+}
+
+// NewMockServer creates a MockServer with default options
+func NewMockServer(t testing.TB) *MockServer {
+	t.Helper()
+	s := &MockServer{
+		Server: server.NewServer(&protocol.ServerInfo{
+			Name:    "mock-server",
+			Version: "1.0.0",
+		}, nil),
+		Notifications: make(chan *protocol.JSONRPCMessage, notificationBuffer),
+		changed:       make(chan struct{}),
+	}
+	s.Use(s.record)
+	return s
+}
+
+// MustRegisterTool adds a tool, failing the test if it cannot be added
+func (s *MockServer) MustRegisterTool(t testing.TB, tool *protocol.Tool, handler server.ToolHandler) {
+	t.Helper()
+	if err := s.AddTool(tool, handler); err != nil {
+		t.Fatalf("register tool %q: %v", tool.Name, err)
+	}
+}
+
+// Calls returns the tool calls recorded so far, in completion order
+func (s *MockServer) Calls() []*CallRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*CallRecord(nil), s.calls...)
+}
+
+// WaitForCall returns the first call to toolName completed after the calls returned by
+// earlier WaitForCall invocations, waiting up to timeout for it. It returns nil on timeout.
+func (s *MockServer) WaitForCall(toolName string, timeout time.Duration) *CallRecord {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		for i := s.waited; i < len(s.calls); i++ {
+			if s.calls[i].Name == toolName {
+				s.waited = i + 1
+				s.mu.Unlock()
+				return s.calls[i]
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+func (s *MockServer) record(next server.ToolHandler) server.ToolHandler {
+	return func(ctx context.Context, req *server.CallToolRequest) (*protocol.CallToolResult, error) {
+		result, err := next(ctx, req)
+
+		s.mu.Lock()
+		s.calls = append(s.calls, &CallRecord{
+			Name:      req.Params.Name,
+			Arguments: req.Params.Arguments,
+			Result:    result,
+			Err:       err,
+			Time:      time.Now(),
+		})
+		close(s.changed)
+		s.changed = make(chan struct{})
+		s.mu.Unlock()
+
+		return result, err
+	}
+}
+
+// MockClient is a client for tests that connects to a MockServer in process
+type MockClient struct {
+	*client.Client
+
+	t testing.TB
+}
+
+// NewMockClient creates a MockClient with default options
+func NewMockClient(t testing.TB) *MockClient {
+	return &MockClient{
+		Client: client.NewClient(&client.ClientInfo{Name: "mock-client", Version: "1.0.0"}, nil),
+		t:      t,
+	}
+}
+
+// Connect connects a new session to s through transport.Pipe, failing the test on error.
+// Both sessions are closed when the test ends.
+func (c *MockClient) Connect(s *MockServer) *client.ClientSession {
+	c.t.Helper()
+	ctx := context.Background()
+	clientT, serverT := transport.Pipe()
+
+	ss, err := s.Server.Connect(ctx, &recordingTransport{Transport: serverT, notifications: s.Notifications}, nil)
+	if err != nil {
+		c.t.Fatalf("mock server connect failed: %v", err)
+	}
+	c.t.Cleanup(func() { ss.Close() })
+
+	cs, err := c.Client.Connect(ctx, clientT, nil)
+	if err != nil {
+		c.t.Fatalf("mock client connect failed: %v", err)
+	}
+	c.t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+// recordingTransport copies the notifications written to its connection to a channel
+type recordingTransport struct {
+	transport.Transport
+	notifications chan<- *protocol.JSONRPCMessage
+}
+
+func (t *recordingTransport) Connect(ctx context.Context) (transport.Connection, error) {
+	conn, err := t.Transport.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{Connection: conn, notifications: t.notifications}, nil
+}
+
+type recordingConn struct {
+	transport.Connection
+	notifications chan<- *protocol.JSONRPCMessage
+}
+
+func (c *recordingConn) Write(ctx context.Context, msg *protocol.JSONRPCMessage) error {
+	if err := c.Connection.Write(ctx, msg); err != nil {
+		return err
+	}
+	for _, m := range append([]*protocol.JSONRPCMessage{msg}, msg.Batch...) {
+		if m.Method == "" || !m.IsNotification() {
+			continue
+		}
+		select {
+		case c.notifications <- m:
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package protocol
+
+import "fmt"
+
+// ToolToAnthropicTool converts a tool to an Anthropic Messages API tool definition:
+//
+//	{"name": ..., "description": ..., "input_schema": ...}
+//
+// The description is omitted when empty.
+func ToolToAnthropicTool(t Tool) map[string]interface{} {
+	tool := map[string]interface{}{
+		"name": t.Name,
+	}
+	if t.Description != "" {
+		tool["description"] = t.Description
+	}
+	schema := t.InputSchema
+	if schema == nil {
+		schema = JSONSchema{"type": "object", "properties": map[string]interface{}{}}
+	}
+	tool["input_schema"] = map[string]interface{}(schema)
+	return tool
+}
+
+// AnthropicToolUseToCallToolParams converts a "tool_use" content block produced by an
+// Anthropic model to tools/call params
+func AnthropicToolUseToCallToolParams(toolUse map[string]interface{}) (*CallToolParams, error) {
+	content, err := AnthropicBlockToContent(toolUse)
+	if err != nil {
+		return nil, err
+	}
+	tu, ok := content.(ToolUseContent)
+	if !ok {
+		return nil, fmt.Errorf("anthropic block: type %q is not tool_use", content.GetType())
+	}
+	return &CallToolParams{Name: tu.Name, Arguments: tu.Input}, nil
+}
+
+// ContentToAnthropicBlock converts text, image, tool_use and tool_result content to an
+// Anthropic Messages API content block
+func ContentToAnthropicBlock(c Content) (map[string]interface{}, error) {
+	switch c := c.(type) {
+	case TextContent:
+		return map[string]interface{}{"type": "text", "text": c.Text}, nil
+	case ImageContent:
+		return anthropicImageBlock(c.MimeType, c.Data), nil
+	case ToolUseContent:
+		input := c.Input
+		if input == nil {
+			input = map[string]interface{}{}
+		}
+		return map[string]interface{}{"type": "tool_use", "id": c.ID, "name": c.Name, "input": input}, nil
+	case ToolResultContent:
+		blocks := make([]interface{}, 0, len(c.Content))
+		for _, cb := range c.Content {
+			switch cb.Type {
+			case ContentTypeText:
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": cb.Text})
+			case ContentTypeImage:
+				blocks = append(blocks, anthropicImageBlock(cb.MimeType, cb.Data))
+			default:
+				return nil, fmt.Errorf("anthropic block: unsupported tool result content type %q", cb.Type)
+			}
+		}
+		block := map[string]interface{}{"type": "tool_result", "tool_use_id": c.ToolUseID, "content": blocks}
+		if c.IsError {
+			block["is_error"] = true
+		}
+		return block, nil
+	default:
+		return nil, fmt.Errorf("anthropic block: unsupported content type %q", c.GetType())
+	}
+}
+
+// AnthropicBlockToContent converts an Anthropic Messages API text, image, tool_use or
+// tool_result content block to content. Images must have a base64 source.
+func AnthropicBlockToContent(block map[string]interface{}) (Content, error) {
+	blockType, _ := block["type"].(string)
+	switch blockType {
+	case "text":
+		text, _ := block["text"].(string)
+		return NewTextContent(text), nil
+	case "image":
+		mimeType, data, err := anthropicImageSource(block)
+		if err != nil {
+			return nil, err
+		}
+		return NewImageContent(data, mimeType), nil
+	case "tool_use":
+		name, _ := block["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("anthropic block: tool_use without name")
+		}
+		id, _ := block["id"].(string)
+		input := map[string]interface{}{}
+		if raw, ok := block["input"]; ok && raw != nil {
+			if input, ok = raw.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("anthropic block: tool_use %q input must be an object", name)
+			}
+		}
+		return NewToolUseContent(id, name, input), nil
+	case "tool_result":
+		id, _ := block["tool_use_id"].(string)
+		var blocks []ContentBlock
+		switch content := block["content"].(type) {
+		case nil:
+		case string:
+			blocks = append(blocks, NewTextContentBlock(content))
+		case []interface{}:
+			for _, item := range content {
+				b, _ := item.(map[string]interface{})
+				c, err := AnthropicBlockToContent(b)
+				if err != nil {
+					return nil, err
+				}
+				switch c := c.(type) {
+				case TextContent:
+					blocks = append(blocks, NewTextContentBlock(c.Text))
+				case ImageContent:
+					blocks = append(blocks, NewImageContentBlock(c.Data, c.MimeType))
+				default:
+					return nil, fmt.Errorf("anthropic block: unsupported tool_result content type %q", c.GetType())
+				}
+			}
+		default:
+			return nil, fmt.Errorf("anthropic block: tool_result content must be a string or an array")
+		}
+		isError, _ := block["is_error"].(bool)
+		return NewToolResultContentWithError(id, blocks, isError), nil
+	default:
+		return nil, fmt.Errorf("anthropic block: unsupported type %q", blockType)
+	}
+}
+
+func anthropicImageBlock(mimeType, data string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "image",
+		"source": map[string]interface{}{
+			"type":       "base64",
+			"media_type": mimeType,
+			"data":       data,
+		},
+	}
+}
+
+func anthropicImageSource(block map[string]interface{}) (mimeType, data string, err error) {
+	source, _ := block["source"].(map[string]interface{})
+	if sourceType, _ := source["type"].(string); sourceType != "base64" {
+		return "", "", fmt.Errorf("anthropic block: unsupported image source type %q", sourceType)
+	}
+	mimeType, _ = source["media_type"].(string)
+	data, _ = source["data"].(string)
+	return mimeType, data, nil
+}
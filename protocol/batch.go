@@ -0,0 +1,51 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// NewBatchMessage wraps messages into a single JSON-RPC batch message
+func NewBatchMessage(msgs ...*JSONRPCMessage) *JSONRPCMessage {
+	if msgs == nil {
+		msgs = []*JSONRPCMessage{}
+	}
+	return &JSONRPCMessage{Batch: msgs}
+}
+
+// IsBatch reports whether the message is a JSON-RPC batch
+func (m *JSONRPCMessage) IsBatch() bool {
+	return m != nil && m.Batch != nil
+}
+
+// jsonrpcMessage avoids recursion into the custom (un)marshalers
+type jsonrpcMessage JSONRPCMessage
+
+func (m JSONRPCMessage) MarshalJSON() ([]byte, error) {
+	if m.Batch != nil {
+		return json.Marshal(m.Batch)
+	}
+	return json.Marshal(jsonrpcMessage(m))
+}
+
+func (m *JSONRPCMessage) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []*JSONRPCMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return err
+		}
+		if batch == nil {
+			batch = []*JSONRPCMessage{}
+		}
+		*m = JSONRPCMessage{Batch: batch}
+		return nil
+	}
+
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	*m = JSONRPCMessage(msg)
+	return nil
+}
@@ -11,6 +11,7 @@ type ReferenceType string
 const (
 	ReferenceTypePrompt   ReferenceType = "ref/prompt"   // Prompt reference
 	ReferenceTypeResource ReferenceType = "ref/resource" // Resource reference
+	ReferenceTypeTool     ReferenceType = "ref/tool"     // Tool reference (SDK extension, completes tool arguments)
 )
 
 type PromptReference struct {
@@ -24,6 +25,12 @@ type ResourceReference struct {
 	URI  string        `json:"uri"`  // Resource URI (may contain template variables)
 }
 
+// ToolReference tool reference
+type ToolReference struct {
+	Type ReferenceType `json:"type"` // Must be "ref/tool"
+	Name string        `json:"name"` // Tool name
+}
+
 // CompletionReference completion reference (PromptReference or ResourceReference)
 type CompletionReference interface {
 	GetType() ReferenceType
@@ -37,6 +44,10 @@ func (r ResourceReference) GetType() ReferenceType {
 	return r.Type
 }
 
+func (t ToolReference) GetType() ReferenceType {
+	return t.Type
+}
+
 type CompletionArgument struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
@@ -102,10 +113,23 @@ func UnmarshalCompletionReference(data map[string]any) (CompletionReference, err
 			URI:  uri,
 		}, nil
 
+	case ReferenceTypeTool:
+		name, ok := data["name"].(string)
+		if !ok {
+			return nil, &MCPError{
+				Code:    -32602,
+				Message: "Invalid tool reference: missing or invalid name field",
+			}
+		}
+		return ToolReference{
+			Type: ReferenceTypeTool,
+			Name: name,
+		}, nil
+
 	default:
 		return nil, &MCPError{
 			Code:    -32602,
-			Message: "Invalid reference type: must be 'ref/prompt' or 'ref/resource'",
+			Message: "Invalid reference type: must be 'ref/prompt', 'ref/resource' or 'ref/tool'",
 		}
 	}
 }
@@ -124,6 +148,13 @@ func NewResourceReference(uri string) ResourceReference {
 	}
 }
 
+func NewToolReference(name string) ToolReference {
+	return ToolReference{
+		Type: ReferenceTypeTool,
+		Name: name,
+	}
+}
+
 func NewCompletionResult(values []string, hasMore bool) CompletionResult {
 	// Limit to maximum 100 results
 	if len(values) > 100 {
@@ -11,6 +11,9 @@ const (
 	ElicitationActionAccept  ElicitationAction = "accept"
 	ElicitationActionDecline ElicitationAction = "decline"
 	ElicitationActionCancel  ElicitationAction = "cancel"
+	// ElicitationActionTimeout is reported by the server SDK when the user did not answer
+	// in time; clients never send it
+	ElicitationActionTimeout ElicitationAction = "timeout"
 )
 
 // ElicitationMode represents the mode of elicitation (MCP 2025-11-25)
@@ -90,14 +93,18 @@ func (r *ElicitationResult) IsCancelled() bool {
 	return r.Action == ElicitationActionCancel
 }
 
+func (r *ElicitationResult) IsTimedOut() bool {
+	return r.Action == ElicitationActionTimeout
+}
+
 func (r *ElicitationResult) Validate() error {
 	switch r.Action {
 	case ElicitationActionAccept:
 		if r.Content == nil {
 			return fmt.Errorf("elicitation accept action must have content")
 		}
-	case ElicitationActionDecline, ElicitationActionCancel:
-		// decline and cancel should not have content
+	case ElicitationActionDecline, ElicitationActionCancel, ElicitationActionTimeout:
+		// decline, cancel and timeout should not have content
 		if r.Content != nil {
 			return fmt.Errorf("elicitation %s action should not have content", r.Action)
 		}
@@ -128,7 +135,7 @@ func (r *ElicitationResult) UnmarshalJSON(data []byte) error {
 // ValidateElicitationAction validates whether the elicitation action is valid
 func ValidateElicitationAction(action string) bool {
 	switch ElicitationAction(action) {
-	case ElicitationActionAccept, ElicitationActionDecline, ElicitationActionCancel:
+	case ElicitationActionAccept, ElicitationActionDecline, ElicitationActionCancel, ElicitationActionTimeout:
 		return true
 	default:
 		return false
@@ -253,3 +260,126 @@ func CreateEnumElicitationSchema(propertyName, description string, options []str
 
 	return schema
 }
+
+// ElicitationSchemaBuilder builds the flat object schema requested by form mode
+// elicitation, one primitive field at a time
+type ElicitationSchemaBuilder struct {
+	properties map[string]interface{}
+	required   []string
+}
+
+func NewElicitationSchemaBuilder() *ElicitationSchemaBuilder {
+	return &ElicitationSchemaBuilder{properties: make(map[string]interface{})}
+}
+
+func (b *ElicitationSchemaBuilder) AddStringField(name, description string, required bool) *ElicitationSchemaBuilder {
+	return b.addField(name, map[string]interface{}{"type": "string", "description": description}, required)
+}
+
+// AddIntField adds an integer field; min and max are optional bounds
+func (b *ElicitationSchemaBuilder) AddIntField(name, description string, min, max *float64, required bool) *ElicitationSchemaBuilder {
+	prop := map[string]interface{}{"type": "integer", "description": description}
+	if min != nil {
+		prop["minimum"] = *min
+	}
+	if max != nil {
+		prop["maximum"] = *max
+	}
+	return b.addField(name, prop, required)
+}
+
+func (b *ElicitationSchemaBuilder) AddBoolField(name, description string, required bool) *ElicitationSchemaBuilder {
+	return b.addField(name, map[string]interface{}{"type": "boolean", "description": description}, required)
+}
+
+// AddChoiceField adds a string field restricted to options
+func (b *ElicitationSchemaBuilder) AddChoiceField(name, description string, options []string, required bool) *ElicitationSchemaBuilder {
+	return b.addField(name, map[string]interface{}{
+		"type":        "string",
+		"description": description,
+		"enum":        append([]string(nil), options...),
+	}, required)
+}
+
+func (b *ElicitationSchemaBuilder) addField(name string, prop map[string]interface{}, required bool) *ElicitationSchemaBuilder {
+	if _, exists := b.properties[name]; !exists && required {
+		b.required = append(b.required, name)
+	}
+	b.properties[name] = prop
+	return b
+}
+
+// Build returns the schema; the builder can keep being used afterwards
+func (b *ElicitationSchemaBuilder) Build() JSONSchema {
+	properties := make(map[string]interface{}, len(b.properties))
+	for name, prop := range b.properties {
+		properties[name] = prop
+	}
+	schema := JSONSchema{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(b.required) > 0 {
+		schema["required"] = append([]string(nil), b.required...)
+	}
+	return schema
+}
+
+// ValidateElicitationSchema checks that schema is usable as a form mode requestedSchema:
+// an object whose properties are all of a primitive type (string, number, integer or
+// boolean) and whose required names are all declared
+func ValidateElicitationSchema(schema JSONSchema) error {
+	if schema["type"] != "object" {
+		return fmt.Errorf("elicitation schema must be of type object, got %v", schema["type"])
+	}
+
+	var properties map[string]interface{}
+	switch p := schema["properties"].(type) {
+	case nil:
+	case map[string]interface{}:
+		properties = p
+	case JSONSchema:
+		properties = p
+	default:
+		return fmt.Errorf("elicitation schema properties must be an object")
+	}
+	for name, raw := range properties {
+		var prop map[string]interface{}
+		switch p := raw.(type) {
+		case map[string]interface{}:
+			prop = p
+		case JSONSchema:
+			prop = p
+		default:
+			return fmt.Errorf("elicitation property %s must be an object", name)
+		}
+		switch prop["type"] {
+		case "string", "number", "integer", "boolean":
+		default:
+			return fmt.Errorf("elicitation property %s must have a primitive type, got %v", name, prop["type"])
+		}
+	}
+
+	var required []string
+	switch r := schema["required"].(type) {
+	case nil:
+	case []string:
+		required = r
+	case []interface{}:
+		for _, name := range r {
+			s, ok := name.(string)
+			if !ok {
+				return fmt.Errorf("elicitation schema required must list property names")
+			}
+			required = append(required, s)
+		}
+	default:
+		return fmt.Errorf("elicitation schema required must list property names")
+	}
+	for _, name := range required {
+		if _, ok := properties[name]; !ok {
+			return fmt.Errorf("elicitation schema requires undeclared property %s", name)
+		}
+	}
+	return nil
+}
@@ -12,6 +12,8 @@ const (
 	MethodResourcesTemplatesList = "resources/templates/list"
 	MethodResourcesSubscribe     = "resources/subscribe"
 	MethodResourcesUnsubscribe   = "resources/unsubscribe"
+	// MethodResourcesWrite replaces the contents of a writable resource (SDK extension)
+	MethodResourcesWrite = "resources/write"
 
 	MethodPromptsList = "prompts/list"
 	MethodPromptsGet  = "prompts/get"
@@ -41,6 +43,9 @@ const (
 	NotificationResourcesListChanged = "notifications/resources/list_changed"
 	NotificationResourcesUpdated     = "notifications/resources/updated"
 
+	// NotificationResourcesChunk carries one chunk of a streamed resources/read result (SDK extension)
+	NotificationResourcesChunk = "notifications/resources/chunk"
+
 	NotificationPromptsListChanged = "notifications/prompts/list_changed"
 
 	NotificationRootsListChanged = "notifications/roots/list_changed"
@@ -55,4 +60,7 @@ const (
 
 	// Tasks notifications (MCP 2025-11-25)
 	NotificationTasksStatus = "notifications/tasks/status"
+
+	// NotificationAuthTokenRotated delivers a new session token (SDK extension)
+	NotificationAuthTokenRotated = "notifications/auth/token_rotated"
 )
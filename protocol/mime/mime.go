@@ -0,0 +1,146 @@
+// Package mime detects the MIME type of resource contents.
+package mime
+
+import (
+	"bytes"
+	stdmime "mime"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMimeType is returned when the type cannot be determined
+const DefaultMimeType = "application/octet-stream"
+
+// signature is a magic byte sequence found at offset in files of mimeType
+type signature struct {
+	offset   int
+	magic    []byte
+	mimeType string
+}
+
+var signatures = []signature{
+	{0, []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+	{0, []byte("\xff\xd8\xff"), "image/jpeg"},
+	{0, []byte("GIF87a"), "image/gif"},
+	{0, []byte("GIF89a"), "image/gif"},
+	{0, []byte("II*\x00"), "image/tiff"},
+	{0, []byte("MM\x00*"), "image/tiff"},
+	{0, []byte("%PDF-"), "application/pdf"},
+	{0, []byte("PK\x03\x04"), "application/zip"},
+	{0, []byte("PK\x05\x06"), "application/zip"},
+	{0, []byte("\x1f\x8b"), "application/gzip"},
+	{0, []byte("BZh"), "application/x-bzip2"},
+	{0, []byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+	{0, []byte("Rar!\x1a\x07"), "application/vnd.rar"},
+	{257, []byte("ustar"), "application/x-tar"},
+	{0, []byte("\x00asm"), "application/wasm"},
+	{0, []byte("ID3"), "audio/mpeg"},
+	{0, []byte("OggS"), "audio/ogg"},
+	{0, []byte("fLaC"), "audio/flac"},
+	{4, []byte("ftyp"), "video/mp4"},
+	{0, []byte("\x1a\x45\xdf\xa3"), "video/webm"},
+	{0, []byte("wOFF"), "font/woff"},
+	{0, []byte("wOF2"), "font/woff2"},
+}
+
+// riffTypes maps the form type of a RIFF container (bytes 8-12) to its MIME type
+var riffTypes = map[string]string{
+	"WEBP": "image/webp",
+	"WAVE": "audio/wav",
+	"AVI ": "video/x-msvideo",
+}
+
+// extensionTypes covers common extensions that the system MIME tables may lack
+var extensionTypes = map[string]string{
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+	".csv":  "text/csv",
+	".log":  "text/plain",
+	".html": "text/html",
+	".htm":  "text/html",
+	".css":  "text/css",
+	".js":   "text/javascript",
+	".json": "application/json",
+	".xml":  "application/xml",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".toml": "application/toml",
+	".go":   "text/x-go",
+	".py":   "text/x-python",
+	".sh":   "application/x-sh",
+	".svg":  "image/svg+xml",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".ico":  "image/x-icon",
+	".pdf":  "application/pdf",
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".tar":  "application/x-tar",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".mp4":  "video/mp4",
+	".wasm": "application/wasm",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".jar":  "application/java-archive",
+	".epub": "application/epub+zip",
+}
+
+// DetectMimeType returns the MIME type of data, read from filename.
+// Magic bytes take precedence, then the file extension; unknown contents
+// are reported as DefaultMimeType. ZIP archives keep a more specific
+// extension-based type, since formats such as .docx and .jar are ZIP containers.
+func DetectMimeType(data []byte, filename string) string {
+	byExtension := TypeByExtension(filename)
+
+	if mimeType := detectMagic(data); mimeType != "" {
+		if mimeType == "application/zip" && byExtension != "" {
+			return byExtension
+		}
+		return mimeType
+	}
+	if byExtension != "" {
+		return byExtension
+	}
+	return DefaultMimeType
+}
+
+// TypeByExtension returns the MIME type for filename's extension, or "" if unknown
+func TypeByExtension(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return ""
+	}
+	if mimeType, ok := extensionTypes[ext]; ok {
+		return mimeType
+	}
+	if mimeType := stdmime.TypeByExtension(ext); mimeType != "" {
+		// Drop parameters such as charset to match the built-in table
+		if mediaType, _, err := stdmime.ParseMediaType(mimeType); err == nil {
+			return mediaType
+		}
+		return mimeType
+	}
+	return ""
+}
+
+// detectMagic returns the MIME type identified by data's leading bytes, or ""
+func detectMagic(data []byte) string {
+	if len(data) >= 12 && bytes.HasPrefix(data, []byte("RIFF")) {
+		if mimeType, ok := riffTypes[string(data[8:12])]; ok {
+			return mimeType
+		}
+	}
+	for _, sig := range signatures {
+		end := sig.offset + len(sig.magic)
+		if len(data) >= end && bytes.Equal(data[sig.offset:end], sig.magic) {
+			return sig.mimeType
+		}
+	}
+	return ""
+}
@@ -32,9 +32,31 @@ type RootsListChangedParams struct {
 	Meta map[string]any `json:"_meta,omitempty"`
 }
 
+// ResourceChunkNotificationParams carries one chunk of a streamed resource (SDK extension).
+// Chunks of a single read are sent in order, before the resources/read response.
+type ResourceChunkNotificationParams struct {
+	Meta map[string]any `json:"_meta,omitempty"`
+	// Streamed resource URI
+	URI string `json:"uri"`
+	// Zero-based position of this chunk in the stream
+	Index    int    `json:"index"`
+	MimeType string `json:"mimeType,omitempty"`
+	// Exactly one of Text or Blob (base64) is set
+	Text string `json:"text,omitempty"`
+	Blob string `json:"blob,omitempty"`
+}
+
 // ResourceUpdatedNotificationParams resource update notification parameters
 type ResourceUpdatedNotificationParams struct {
 	Meta map[string]any `json:"_meta,omitempty"`
 	// Updated resource URI
 	URI string `json:"uri"`
 }
+
+// TokenRotatedNotificationParams carries a new session token (SDK extension). The client
+// authenticates subsequent requests with it; the previous token stays valid for a grace
+// period so that requests already in flight are not rejected.
+type TokenRotatedNotificationParams struct {
+	Meta  map[string]any `json:"_meta,omitempty"`
+	Token string         `json:"token"`
+}
@@ -0,0 +1,69 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolToOpenAIFunction converts a tool to an OpenAI function-calling tool definition:
+//
+//	{"type": "function", "function": {"name": ..., "description": ..., "parameters": ...}}
+//
+// The input schema is used as the parameters; the description is omitted when empty.
+func ToolToOpenAIFunction(t Tool) map[string]interface{} {
+	function := map[string]interface{}{
+		"name": t.Name,
+	}
+	if t.Description != "" {
+		function["description"] = t.Description
+	}
+	parameters := t.InputSchema
+	if parameters == nil {
+		parameters = JSONSchema{"type": "object", "properties": map[string]interface{}{}}
+	}
+	function["parameters"] = map[string]interface{}(parameters)
+
+	return map[string]interface{}{
+		"type":     "function",
+		"function": function,
+	}
+}
+
+// OpenAIFunctionCallToCallToolParams converts a function call produced by an OpenAI
+// model to tools/call params. call may be an entry of a message's "tool_calls", holding
+// a "function" object, or a legacy "function_call" object itself. Its "arguments" may be
+// a JSON-encoded string, as sent by OpenAI, or an object.
+func OpenAIFunctionCallToCallToolParams(call map[string]interface{}) (*CallToolParams, error) {
+	function := call
+	if f, ok := call["function"]; ok {
+		if function, ok = f.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("function call: \"function\" must be an object")
+		}
+	}
+
+	name, _ := function["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("function call: missing function name")
+	}
+
+	arguments := map[string]any{}
+	switch args := function["arguments"].(type) {
+	case nil:
+	case string:
+		if args != "" {
+			if err := json.Unmarshal([]byte(args), &arguments); err != nil {
+				return nil, fmt.Errorf("function call %q: arguments are not a JSON object: %w", name, err)
+			}
+		}
+	case map[string]interface{}:
+		arguments = args
+	default:
+		return nil, fmt.Errorf("function call %q: arguments must be a JSON string or an object", name)
+	}
+	if arguments == nil {
+		// "null" arguments
+		arguments = map[string]any{}
+	}
+
+	return &CallToolParams{Name: name, Arguments: arguments}, nil
+}
@@ -6,6 +6,8 @@ type PromptArgument struct {
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"`
 	Required    bool   `json:"required,omitempty"`
+	// Default is applied server-side when an optional argument is omitted by the client
+	Default string `json:"default,omitempty"`
 }
 
 type Prompt struct {
@@ -66,6 +68,7 @@ type GetPromptRequest struct {
 
 // GetPromptParams parameter type for getting prompt templates
 type GetPromptParams struct {
+	Meta      map[string]any    `json:"_meta,omitempty"`
 	Name      string            `json:"name"`
 	Arguments map[string]string `json:"arguments,omitempty"`
 }
@@ -95,6 +98,15 @@ func NewPromptArgument(name, description string, required bool) PromptArgument {
 	}
 }
 
+// NewPromptArgumentWithDefault creates an optional argument whose value defaults to defaultValue
+func NewPromptArgumentWithDefault(name, description, defaultValue string) PromptArgument {
+	return PromptArgument{
+		Name:        name,
+		Description: description,
+		Default:     defaultValue,
+	}
+}
+
 func NewPromptMessage(role Role, content Content) PromptMessage {
 	return PromptMessage{
 		Role:    role,
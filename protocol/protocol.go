@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -40,6 +41,25 @@ const (
 	ErrorCodeInvalidParams = InvalidParams
 )
 
+// Range of JSON-RPC server error codes, shared by the MCP codes above and application-defined ones
+const (
+	MinServerErrorCode = -32099
+	MaxServerErrorCode = -32000
+)
+
+// CheckCustomErrorCode returns an error if code cannot identify an application-defined error:
+// it must lie within the server error range and not be one of the MCP error codes.
+func CheckCustomErrorCode(code int) error {
+	if code < MinServerErrorCode || code > MaxServerErrorCode {
+		return fmt.Errorf("error code %d outside the range %d to %d", code, MinServerErrorCode, MaxServerErrorCode)
+	}
+	switch code {
+	case ToolNotFound, ResourceNotFound, PromptNotFound, InvalidTool, InvalidResource, InvalidPrompt, URLElicitationRequired:
+		return fmt.Errorf("error code %d is reserved by MCP", code)
+	}
+	return nil
+}
+
 type JSONRPCMessage struct {
 	JSONRPC string          `json:"jsonrpc"`
 	ID      json.RawMessage `json:"id,omitempty"`
@@ -47,6 +67,25 @@ type JSONRPCMessage struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   *JSONRPCError   `json:"error,omitempty"`
+
+	// Batch holds the members of a JSON-RPC batch. When non-nil the message
+	// is encoded as a JSON array and all other fields are ignored.
+	Batch []*JSONRPCMessage `json:"-"`
+
+	ctx context.Context
+}
+
+// WithContext returns a shallow copy of m carrying ctx. Transports use it to pass values
+// of the request that delivered m, such as HTTP headers, to the handlers.
+func (m *JSONRPCMessage) WithContext(ctx context.Context) *JSONRPCMessage {
+	copied := *m
+	copied.ctx = ctx
+	return &copied
+}
+
+// Context returns the context set with WithContext, or nil
+func (m *JSONRPCMessage) Context() context.Context {
+	return m.ctx
 }
 
 type JSONRPCError struct {
@@ -66,6 +105,18 @@ func (e *MCPError) Error() string {
 	return e.Message
 }
 
+// MCPErrorCode returns the JSON-RPC error code
+func (e *MCPError) MCPErrorCode() int {
+	return e.Code
+}
+
+// Is reports whether target carries the same error code, so that errors.Is matches
+// an error received over the wire against the server's sentinel errors
+func (e *MCPError) Is(target error) bool {
+	coder, ok := target.(interface{ MCPErrorCode() int })
+	return ok && coder.MCPErrorCode() == e.Code
+}
+
 // NewMCPError creates a new MCP error
 func NewMCPError(code int, message string, data interface{}) *MCPError {
 	return &MCPError{
@@ -75,6 +126,12 @@ func NewMCPError(code int, message string, data interface{}) *MCPError {
 	}
 }
 
+// NewMCPErrorWithCode creates an MCP error without data, typically with a code
+// registered through Server.RegisterErrorCode
+func NewMCPErrorWithCode(code int, message string) *MCPError {
+	return NewMCPError(code, message, nil)
+}
+
 type ContentType string
 
 const (
@@ -209,6 +266,9 @@ func UnmarshalContent(data []byte) (Content, error) {
 		if err := json.Unmarshal(data, &erc); err != nil {
 			return nil, err
 		}
+		if erc.Resource.Blob != "" && erc.Resource.BlobEncoding == "" {
+			erc.Resource.BlobEncoding = BlobEncodingBase64
+		}
 		return erc, nil
 	case ContentTypeToolUse:
 		var tuc ToolUseContent
@@ -395,6 +455,8 @@ type ToolsCapability struct {
 type ResourcesCapability struct {
 	Subscribe   bool `json:"subscribe,omitempty"`
 	ListChanged bool `json:"listChanged,omitempty"`
+	// Writable reports that some resources accept resources/write. SDK extension.
+	Writable bool `json:"writable,omitempty"`
 }
 
 type PromptsCapability struct {
@@ -506,6 +568,28 @@ func IsVersionSupported(version string) bool {
 	return false
 }
 
+// NegotiateProtocolVersion picks the version to use with a peer requesting requested:
+// requested itself if supported, otherwise the latest supported version preceding it.
+// A peer older than every supported version is offered the latest one, which it may reject.
+func NegotiateProtocolVersion(requested string) string {
+	if IsVersionSupported(requested) {
+		return requested
+	}
+	for _, supported := range GetSupportedVersions() {
+		if supported < requested {
+			return supported
+		}
+	}
+	return MCPVersion
+}
+
+// ProtocolVersionAtLeast reports whether version is minimum or a later revision,
+// e.g. ProtocolVersionAtLeast(v, MCPVersion2025_06_18) for audio content.
+// Versions are YYYY-MM-DD dates and compare lexically.
+func ProtocolVersionAtLeast(version, minimum string) bool {
+	return version >= minimum
+}
+
 func IDToString(id json.RawMessage) string {
 	if len(id) == 0 {
 		return ""
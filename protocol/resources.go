@@ -1,5 +1,15 @@
 package protocol
 
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
 type Resource struct {
 	URI         string         `json:"uri"`
 	Name        string         `json:"name"`
@@ -13,12 +23,125 @@ type Resource struct {
 }
 
 type ResourceContents struct {
-	URI         string      `json:"uri"`
-	Title       string      `json:"title,omitempty"`
-	MimeType    string      `json:"mimeType,omitempty"`
-	Text        string      `json:"text,omitempty"`
-	Blob        string      `json:"blob,omitempty"`
-	Annotations *Annotation `json:"annotations,omitempty"`
+	URI          string      `json:"uri"`
+	Title        string      `json:"title,omitempty"`
+	MimeType     string      `json:"mimeType,omitempty"`
+	Text         string      `json:"text,omitempty"`
+	Blob         string      `json:"blob,omitempty"`
+	BlobEncoding string      `json:"blobEncoding,omitempty"` // Encoding of Blob, MCP only defines "base64"
+	Annotations  *Annotation `json:"annotations,omitempty"`
+	// ETag identifies this version of the contents, see ReadResourceParams.IfNoneMatch.
+	// SDK extension.
+	ETag string `json:"etag,omitempty"`
+	// Version names this revision of the contents. The server keeps a history of the
+	// versions it served, readable through ReadResourceParams.Version. SDK extension.
+	Version string `json:"version,omitempty"`
+	// LastModified is when this version of the contents was last changed. SDK extension.
+	LastModified time.Time      `json:"lastModified,omitzero"`
+	Meta         map[string]any `json:"_meta,omitempty"`
+}
+
+// BlobEncodingBase64 is the only Blob encoding defined by MCP
+const BlobEncodingBase64 = "base64"
+
+// ContentEncodingMetaKey is the _meta key naming the compression applied to text
+// contents, which are then carried as a Blob (SDK extension). Only "gzip" is defined.
+const ContentEncodingMetaKey = "contentEncoding"
+
+// Decompress restores text contents compressed by the server, as indicated by the
+// ContentEncodingMetaKey entry of Meta. Other contents are returned unchanged.
+func (rc ResourceContents) Decompress() (ResourceContents, error) {
+	encoding, _ := rc.Meta[ContentEncodingMetaKey].(string)
+	if encoding == "" {
+		return rc, nil
+	}
+	if encoding != "gzip" {
+		return rc, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+
+	data, err := rc.DecodeBlob()
+	if err != nil {
+		return rc, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return rc, fmt.Errorf("decompress %s: %w", rc.URI, err)
+	}
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		return rc, fmt.Errorf("decompress %s: %w", rc.URI, err)
+	}
+
+	decoded := rc
+	decoded.Text = string(text)
+	decoded.Blob, decoded.BlobEncoding = "", ""
+	decoded.Meta = make(map[string]any, len(rc.Meta))
+	for k, v := range rc.Meta {
+		if k != ContentEncodingMetaKey {
+			decoded.Meta[k] = v
+		}
+	}
+	if len(decoded.Meta) == 0 {
+		decoded.Meta = nil
+	}
+	return decoded, nil
+}
+
+// DecodeBlob returns the binary content carried in Blob
+func (rc ResourceContents) DecodeBlob() ([]byte, error) {
+	if rc.BlobEncoding != "" && rc.BlobEncoding != BlobEncodingBase64 {
+		return nil, fmt.Errorf("unsupported blob encoding: %s", rc.BlobEncoding)
+	}
+	return base64.StdEncoding.DecodeString(rc.Blob)
+}
+
+// IsBinary reports whether the contents are binary (Blob) rather than text
+func (rc ResourceContents) IsBinary() bool {
+	return rc.Blob != "" || rc.BlobEncoding != ""
+}
+
+// Bytes returns the decoded Blob of binary contents, or the Text otherwise
+func (rc ResourceContents) Bytes() ([]byte, error) {
+	if rc.IsBinary() {
+		return rc.DecodeBlob()
+	}
+	return []byte(rc.Text), nil
+}
+
+// MarshalJSON always emits "blob" for binary contents, even when empty,
+// so that empty binary data is not mistaken for empty text
+func (rc ResourceContents) MarshalJSON() ([]byte, error) {
+	type alias ResourceContents
+	if !rc.IsBinary() {
+		return json.Marshal(alias(rc))
+	}
+	a := alias(rc)
+	a.Text = ""
+	return json.Marshal(struct {
+		alias
+		Blob string `json:"blob"`
+	}{a, rc.Blob})
+}
+
+// UnmarshalJSON marks contents carrying a "blob" field as base64-encoded binary
+func (rc *ResourceContents) UnmarshalJSON(data []byte) error {
+	type alias ResourceContents
+	var temp struct {
+		alias
+		Blob *string `json:"blob"`
+	}
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+
+	*rc = ResourceContents(temp.alias)
+	if temp.Blob != nil {
+		rc.Blob = *temp.Blob
+		if rc.BlobEncoding == "" {
+			rc.BlobEncoding = BlobEncodingBase64
+		}
+	}
+	return nil
 }
 
 // ListResourcesRequest resources/list request and response
@@ -43,11 +166,41 @@ type ReadResourceRequest struct {
 
 // ReadResourceParams parameter type for reading resources
 type ReadResourceParams struct {
-	URI string `json:"uri"`
+	Meta map[string]any `json:"_meta,omitempty"`
+	URI  string         `json:"uri"`
+	// Accept lists the preferred MIME types using HTTP Accept syntax, e.g. "text/csv, application/json;q=0.5".
+	// SDK extension used by resources added with Server.AddNegotiatedResource.
+	Accept string `json:"accept,omitempty"`
+	// IfNoneMatch is the ETag of contents the client already holds. If the resource still
+	// has that ETag the server answers with NotModified instead of the contents.
+	// SDK extension.
+	IfNoneMatch string `json:"ifNoneMatch,omitempty"`
+	// Version requests a past version of the contents, as listed in
+	// ReadResourceResult.AvailableVersions. SDK extension.
+	Version string `json:"version,omitempty"`
 }
 
 type ReadResourceResult struct {
+	Meta     map[string]any     `json:"_meta,omitempty"`
 	Contents []ResourceContents `json:"contents"`
+	// NotModified reports that the contents still match ReadResourceParams.IfNoneMatch;
+	// Contents is empty then. SDK extension.
+	NotModified bool `json:"notModified,omitempty"`
+	// AvailableVersions lists the versions of the resource the server can serve, oldest
+	// first. SDK extension.
+	AvailableVersions []string `json:"availableVersions,omitempty"`
+}
+
+// WriteResourceParams resources/write request and response (SDK extension)
+type WriteResourceParams struct {
+	Meta map[string]any `json:"_meta,omitempty"`
+	URI  string         `json:"uri"`
+	// Contents is the new contents of the resource; its URI may be left empty
+	Contents ResourceContents `json:"contents"`
+}
+
+type WriteResourceResult struct {
+	Meta map[string]any `json:"_meta,omitempty"`
 }
 
 // ListResourceTemplatesRequest resources/templates/list request and response
@@ -105,11 +258,19 @@ func NewTextResourceContents(uri, text string) ResourceContents {
 	}
 }
 
-func NewBlobResourceContents(uri, blob, mimeType string) ResourceContents {
+// NewBinaryResourceContents creates binary resource contents, base64-encoding data.
+// It is equivalent to NewBlobResourceContents.
+func NewBinaryResourceContents(uri, mimeType string, data []byte) ResourceContents {
+	return NewBlobResourceContents(uri, mimeType, data)
+}
+
+// NewBlobResourceContents creates binary resource contents, base64-encoding data
+func NewBlobResourceContents(uri, mimeType string, data []byte) ResourceContents {
 	return ResourceContents{
-		URI:      uri,
-		MimeType: mimeType,
-		Blob:     blob,
+		URI:          uri,
+		MimeType:     mimeType,
+		Blob:         base64.StdEncoding.EncodeToString(data),
+		BlobEncoding: BlobEncodingBase64,
 	}
 }
 
@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// SchemaTypeChange is a field whose JSON Schema "type" changed
+type SchemaTypeChange struct {
+	Field   string `json:"field"`
+	OldType string `json:"oldType"`
+	NewType string `json:"newType"`
+}
+
+// SchemaChanges describes how the top-level fields of an object schema changed between
+// two versions of a tool, from the point of view of clients built for the old version
+type SchemaChanges struct {
+	AddedRequired   []string           `json:"addedRequired,omitempty"`   // breaking
+	RemovedRequired []string           `json:"removedRequired,omitempty"` // safe
+	ChangedTypes    []SchemaTypeChange `json:"changedTypes,omitempty"`    // breaking
+	AddedOptional   []string           `json:"addedOptional,omitempty"`   // safe
+	RemovedOptional []string           `json:"removedOptional,omitempty"` // potentially breaking
+}
+
+// IsBreaking reports whether arguments valid for the old schema may be rejected by the
+// new one, because a field became required or changed type
+func (c SchemaChanges) IsBreaking() bool {
+	return len(c.AddedRequired) > 0 || len(c.ChangedTypes) > 0
+}
+
+// IsPotentiallyBreaking reports whether the changes are breaking or remove optional
+// fields, which clients may still send and expect to be honored
+func (c SchemaChanges) IsPotentiallyBreaking() bool {
+	return c.IsBreaking() || len(c.RemovedOptional) > 0
+}
+
+// IsEmpty reports whether no change was found
+func (c SchemaChanges) IsEmpty() bool {
+	return len(c.AddedRequired) == 0 && len(c.RemovedRequired) == 0 && len(c.ChangedTypes) == 0 &&
+		len(c.AddedOptional) == 0 && len(c.RemovedOptional) == 0
+}
+
+// Descriptions returns one human-readable line per change, breaking changes first
+func (c SchemaChanges) Descriptions() []string {
+	var lines []string
+	for _, f := range c.AddedRequired {
+		lines = append(lines, fmt.Sprintf("BREAKING: field %q is now required", f))
+	}
+	for _, tc := range c.ChangedTypes {
+		lines = append(lines, fmt.Sprintf("BREAKING: field %q changed type from %s to %s", tc.Field, tc.OldType, tc.NewType))
+	}
+	for _, f := range c.RemovedOptional {
+		lines = append(lines, fmt.Sprintf("POTENTIALLY BREAKING: optional field %q was removed", f))
+	}
+	for _, f := range c.RemovedRequired {
+		lines = append(lines, fmt.Sprintf("safe: field %q is no longer required", f))
+	}
+	for _, f := range c.AddedOptional {
+		lines = append(lines, fmt.Sprintf("safe: optional field %q was added", f))
+	}
+	return lines
+}
+
+// CompareSchemas compares the top-level properties of two versions of an object schema,
+// such as a tool's InputSchema. A field whose "type" is missing matches any type.
+func CompareSchemas(old, new map[string]interface{}) SchemaChanges {
+	oldSchema, newSchema := normalizeObjectSchema(old), normalizeObjectSchema(new)
+
+	var c SchemaChanges
+	for _, name := range slices.Sorted(maps.Keys(newSchema.Properties)) {
+		required := slices.Contains(newSchema.Required, name)
+		oldProp, existed := oldSchema.Properties[name]
+		wasRequired := slices.Contains(oldSchema.Required, name)
+
+		switch {
+		case required && !wasRequired:
+			c.AddedRequired = append(c.AddedRequired, name)
+		case !required && wasRequired:
+			c.RemovedRequired = append(c.RemovedRequired, name)
+		case !existed:
+			c.AddedOptional = append(c.AddedOptional, name)
+		}
+		if existed {
+			oldType, newType := string(oldProp.Type), string(newSchema.Properties[name].Type)
+			if oldType != "" && newType != "" && oldType != newType {
+				c.ChangedTypes = append(c.ChangedTypes, SchemaTypeChange{Field: name, OldType: oldType, NewType: newType})
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(oldSchema.Properties)) {
+		if _, exists := newSchema.Properties[name]; exists {
+			continue
+		}
+		if slices.Contains(oldSchema.Required, name) {
+			c.RemovedRequired = append(c.RemovedRequired, name)
+		} else {
+			c.RemovedOptional = append(c.RemovedOptional, name)
+		}
+	}
+	return c
+}
+
+// objectSchema is the part of an object schema CompareSchemas looks at. Types are kept
+// as raw JSON so that "string" and ["string","null"] compare as different.
+type objectSchema struct {
+	Properties map[string]struct {
+		Type json.RawMessage `json:"type"`
+	} `json:"properties"`
+	Required []string `json:"required"`
+}
+
+// normalizeObjectSchema reads schema through its JSON form, so that schemas built from
+// different Go types compare equal
+func normalizeObjectSchema(schema map[string]interface{}) objectSchema {
+	var s objectSchema
+	if data, err := json.Marshal(schema); err == nil {
+		_ = json.Unmarshal(data, &s)
+	}
+	return s
+}
@@ -1,8 +1,10 @@
 package protocol
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/santhosh-tekuri/jsonschema/v6"
@@ -32,6 +34,13 @@ type Tool struct {
 	Icons        []Icon          `json:"icons,omitempty"`        // MCP 2025-11-25: Icons for UI display
 	Annotations  *ToolAnnotation `json:"annotations,omitempty"`  // MCP 2025-06-18: Tool behavior annotations
 	Meta         map[string]any  `json:"_meta,omitempty"`        // MCP 2025-06-18: Extended metadata
+
+	// Priority ranks the tool for clients that pick tools automatically, from 0.0 to 1.0,
+	// higher is more preferred. 0.0 means unspecified, with no ordering guarantee.
+	// tools/list returns tools by descending priority. SDK extension.
+	Priority float64 `json:"priority,omitempty"`
+	// Category groups related tools, e.g. "filesystem". SDK extension.
+	Category string `json:"category,omitempty"`
 }
 
 // ToolAnnotation describes tool behavior characteristics (MCP 2025-06-18)
@@ -48,6 +57,35 @@ type ToolAnnotation struct {
 	OpenWorldHint bool `json:"openWorldHint,omitempty"`
 }
 
+func NewToolAnnotation() *ToolAnnotation {
+	return &ToolAnnotation{}
+}
+
+func (a *ToolAnnotation) WithTitle(title string) *ToolAnnotation {
+	a.Title = title
+	return a
+}
+
+func (a *ToolAnnotation) AsReadOnly() *ToolAnnotation {
+	a.ReadOnlyHint = true
+	return a
+}
+
+func (a *ToolAnnotation) AsDestructive() *ToolAnnotation {
+	a.DestructiveHint = true
+	return a
+}
+
+func (a *ToolAnnotation) AsIdempotent() *ToolAnnotation {
+	a.IdempotentHint = true
+	return a
+}
+
+func (a *ToolAnnotation) AsOpenWorld() *ToolAnnotation {
+	a.OpenWorldHint = true
+	return a
+}
+
 type ToolList struct {
 	Tools []Tool `json:"tools"`
 }
@@ -57,10 +95,57 @@ type CallToolParams struct {
 	Name      string         `json:"name"`
 	Arguments map[string]any `json:"arguments"`
 	Task      *TaskMetadata  `json:"task,omitempty"` // MCP 2025-11-25: Task metadata for task-augmented requests
+
+	// DryRun asks the server to validate the arguments without running the tool.
+	// It is carried as _meta["dryRun"]. SDK extension.
+	DryRun bool `json:"-"`
+
+	// IdempotencyKey identifies the call across retries: a server with an idempotency
+	// store answers a repeated key with the result of the first successful call instead
+	// of running the tool again. It is carried as _meta["idempotencyKey"]. SDK extension.
+	IdempotencyKey string `json:"-"`
+}
+
+// Keys of CallToolParams fields carried in _meta
+const (
+	dryRunMetaKey         = "dryRun"
+	idempotencyKeyMetaKey = "idempotencyKey"
+)
+
+func (p CallToolParams) MarshalJSON() ([]byte, error) {
+	type plain CallToolParams
+	if p.DryRun || p.IdempotencyKey != "" {
+		meta := make(map[string]any, len(p.Meta)+2)
+		for k, v := range p.Meta {
+			meta[k] = v
+		}
+		if p.DryRun {
+			meta[dryRunMetaKey] = true
+		}
+		if p.IdempotencyKey != "" {
+			meta[idempotencyKeyMetaKey] = p.IdempotencyKey
+		}
+		p.Meta = meta
+	}
+	return json.Marshal(plain(p))
+}
+
+func (p *CallToolParams) UnmarshalJSON(data []byte) error {
+	type plain CallToolParams
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	p.DryRun, _ = p.Meta[dryRunMetaKey].(bool)
+	p.IdempotencyKey, _ = p.Meta[idempotencyKeyMetaKey].(string)
+	return nil
 }
 
 type ListToolsParams struct {
 	Cursor string `json:"cursor,omitempty"`
+
+	// IncludeDisabled also lists tools disabled on the server, marked with
+	// _meta "disabled": true, for administrative clients. SDK extension.
+	IncludeDisabled bool `json:"includeDisabled,omitempty"`
 }
 
 type CallToolResult struct {
@@ -133,6 +218,26 @@ func NewToolWithOutput(name, description string, inputSchema, outputSchema JSONS
 	}
 }
 
+// WithParamExample appends examples to the "examples" of the paramName property of the
+// input schema, which clients may use to fill in arguments. Unknown parameters are ignored.
+func (t *Tool) WithParamExample(paramName string, examples ...interface{}) *Tool {
+	properties, _ := t.InputSchema["properties"].(map[string]interface{})
+	if properties == nil {
+		properties, _ = t.InputSchema["properties"].(JSONSchema)
+	}
+	property, _ := properties[paramName].(map[string]interface{})
+	if property == nil {
+		property, _ = properties[paramName].(JSONSchema)
+	}
+	if property == nil {
+		return t
+	}
+
+	existing, _ := property["examples"].([]interface{})
+	property["examples"] = append(slices.Clip(existing), examples...)
+	return t
+}
+
 func NewToolResult(content []Content, isError bool) *CallToolResult {
 	return &CallToolResult{
 		Content: content,
@@ -154,6 +259,107 @@ func NewToolResultError(errorMsg string) *CallToolResult {
 	}
 }
 
+// NewToolResultErrorWithData creates an error tool result carrying structured error details.
+// data is returned as StructuredContent alongside the error message.
+func NewToolResultErrorWithData(errorMsg string, data interface{}) *CallToolResult {
+	result := NewToolResultError(errorMsg)
+	result.StructuredContent = data
+	return result
+}
+
+// ToolResultBuilder accumulates mixed content parts into a CallToolResult
+type ToolResultBuilder struct {
+	content []Content
+}
+
+// NewToolResultBuilder starts an empty tool result.
+//
+//	result := protocol.NewToolResultBuilder().
+//		AddText("summary").
+//		AddImage(pngData, "image/png").
+//		AddResourceLink("file:///report.pdf", "report").
+//		Build()
+func NewToolResultBuilder() *ToolResultBuilder {
+	return &ToolResultBuilder{}
+}
+
+// AddText appends text content
+func (b *ToolResultBuilder) AddText(text string) *ToolResultBuilder {
+	return b.AddContent(NewTextContent(text))
+}
+
+// AddImage appends image content, base64-encoding data
+func (b *ToolResultBuilder) AddImage(data []byte, mimeType string) *ToolResultBuilder {
+	return b.AddContent(NewImageContent(base64.StdEncoding.EncodeToString(data), mimeType))
+}
+
+// AddAudio appends audio content, base64-encoding data
+func (b *ToolResultBuilder) AddAudio(data []byte, mimeType string) *ToolResultBuilder {
+	return b.AddContent(NewAudioContent(base64.StdEncoding.EncodeToString(data), mimeType))
+}
+
+// AddResourceLink appends a link to the resource at uri
+func (b *ToolResultBuilder) AddResourceLink(uri, name string) *ToolResultBuilder {
+	link := NewResourceLinkContent(uri)
+	link.Name = name
+	return b.AddContent(link)
+}
+
+// AddResource appends an embedded resource
+func (b *ToolResultBuilder) AddResource(resource ResourceContents) *ToolResultBuilder {
+	return b.AddContent(NewEmbeddedResourceContent(resource))
+}
+
+// AddContent appends any content part
+func (b *ToolResultBuilder) AddContent(content Content) *ToolResultBuilder {
+	b.content = append(b.content, content)
+	return b
+}
+
+// Build returns the result holding the accumulated content
+func (b *ToolResultBuilder) Build() *CallToolResult {
+	content := make([]Content, len(b.content))
+	copy(content, b.content)
+	return NewToolResult(content, false)
+}
+
+// NewAnnotatedToolResultText creates a text tool result whose content carries ann (MCP 2025-06-18)
+func NewAnnotatedToolResultText(text string, ann *Annotation) *CallToolResult {
+	return NewToolResultText(text).WithAnnotation(0, ann)
+}
+
+// WithAnnotation attaches ann to the content item at idx (MCP 2025-06-18).
+// Out-of-range indexes and content types without annotations are left unchanged.
+func (ctr *CallToolResult) WithAnnotation(idx int, ann *Annotation) *CallToolResult {
+	if idx < 0 || idx >= len(ctr.Content) {
+		return ctr
+	}
+
+	switch c := ctr.Content[idx].(type) {
+	case TextContent:
+		c.Annotations = ann
+		ctr.Content[idx] = c
+	case *TextContent:
+		c.Annotations = ann
+	case ImageContent:
+		c.Annotations = ann
+		ctr.Content[idx] = c
+	case *ImageContent:
+		c.Annotations = ann
+	case AudioContent:
+		c.Annotations = ann
+		ctr.Content[idx] = c
+	case *AudioContent:
+		c.Annotations = ann
+	case ResourceLinkContent:
+		c.Annotations = ann
+		ctr.Content[idx] = c
+	case *ResourceLinkContent:
+		c.Annotations = ann
+	}
+	return ctr
+}
+
 // NewToolResultWithStructured creates a tool result with structured content (MCP 2025-06-18)
 func NewToolResultWithStructured(content []Content, structuredContent interface{}) *CallToolResult {
 	return &CallToolResult{
@@ -264,6 +470,47 @@ func NumberParameter(name, description string, required bool) ToolParameter {
 	}
 }
 
+// StringParameterWithDefault is StringParameter with a "default" value in its schema
+func StringParameterWithDefault(name, description, defaultValue string, required bool) ToolParameter {
+	p := StringParameter(name, description, required)
+	p.Schema["default"] = defaultValue
+	return p
+}
+
+// IntegerParameterWithDefault creates an integer parameter with a "default" value in its schema
+func IntegerParameterWithDefault(name, description string, defaultValue int, required bool) ToolParameter {
+	return ToolParameter{
+		Name:        name,
+		Description: description,
+		Required:    required,
+		Schema: JSONSchema{
+			"type":    "integer",
+			"default": defaultValue,
+		},
+	}
+}
+
+// NumberParameterWithDefault is NumberParameter with a "default" value in its schema
+func NumberParameterWithDefault(name, description string, defaultValue float64, required bool) ToolParameter {
+	p := NumberParameter(name, description, required)
+	p.Schema["default"] = defaultValue
+	return p
+}
+
+// EnumParameter creates a string parameter restricted to values
+func EnumParameter(name, description string, values []string, required bool) ToolParameter {
+	p := StringParameter(name, description, required)
+	p.Schema["enum"] = append([]string(nil), values...)
+	return p
+}
+
+// EnumParameterWithDefault is EnumParameter with a "default" value in its schema
+func EnumParameterWithDefault(name, description string, values []string, defaultValue string, required bool) ToolParameter {
+	p := EnumParameter(name, description, values, required)
+	p.Schema["default"] = defaultValue
+	return p
+}
+
 func BooleanParameter(name, description string, required bool) ToolParameter {
 	return ToolParameter{
 		Name:        name,
@@ -275,15 +522,81 @@ func BooleanParameter(name, description string, required bool) ToolParameter {
 	}
 }
 
-func ObjectParameter(name, description string, required bool, properties JSONSchema, required_props []string) ToolParameter {
+// ArrayParameter creates an array parameter whose elements must match items
+func ArrayParameter(name, description string, items JSONSchema, required bool) ToolParameter {
 	return ToolParameter{
+		Name:        name,
+		Description: description,
+		Required:    required,
+		Schema: JSONSchema{
+			"type":  "array",
+			"items": items,
+		},
+	}
+}
+
+// ArrayOfStringsParameter creates an array parameter of strings
+func ArrayOfStringsParameter(name, description string, required bool) ToolParameter {
+	return ArrayParameter(name, description, JSONSchema{"type": "string"}, required)
+}
+
+// ArrayOfNumbersParameter creates an array parameter of numbers
+func ArrayOfNumbersParameter(name, description string, required bool) ToolParameter {
+	return ArrayParameter(name, description, JSONSchema{"type": "number"}, required)
+}
+
+func ObjectParameter(name, description string, required bool, properties JSONSchema, required_props []string) ToolParameter {
+	p := ToolParameter{
 		Name:        name,
 		Description: description,
 		Required:    required,
 		Schema: JSONSchema{
 			"type":       "object",
 			"properties": properties,
-			"required":   required_props,
 		},
 	}
+	// A null "required" is not a valid schema
+	if len(required_props) > 0 {
+		p.Schema["required"] = required_props
+	}
+	return p
+}
+
+// NestedObjectParameter creates an object parameter grouping fields, e.g. an address made
+// of street, city and zip. The fields marked Required make up the object's "required" list.
+func NestedObjectParameter(name, description string, required bool, fields ...ToolParameter) ToolParameter {
+	return ToolParameter{
+		Name:        name,
+		Description: description,
+		Required:    required,
+		Schema:      NewToolInputSchema(fields...),
+	}
+}
+
+// NewToolInputSchema builds an object input schema from parameters
+func NewToolInputSchema(params ...ToolParameter) JSONSchema {
+	properties := make(JSONSchema, len(params))
+	required := make([]string, 0, len(params))
+	for _, p := range params {
+		property := make(JSONSchema, len(p.Schema)+1)
+		for k, v := range p.Schema {
+			property[k] = v
+		}
+		if p.Description != "" {
+			property["description"] = p.Description
+		}
+		properties[p.Name] = property
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+
+	schema := JSONSchema{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
 }
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// SessionInfo describes a connected session, see ListSessions
+type SessionInfo struct {
+	ID              string                `json:"id"`
+	ConnectedAt     time.Time             `json:"connectedAt"`
+	LastActive      time.Time             `json:"lastActive"`
+	ClientInfo      *protocol.ClientInfo  `json:"clientInfo,omitempty"` // nil before initialize
+	ProtocolVersion string                `json:"protocolVersion,omitempty"`
+	PendingRequests int                   `json:"pendingRequests"`
+	Subscriptions   []string              `json:"subscriptions,omitempty"`
+	LogLevel        protocol.LoggingLevel `json:"logLevel,omitempty"`
+	BytesRead       int64                 `json:"bytesRead"`
+	BytesWritten    int64                 `json:"bytesWritten"`
+	MessagesRead    int64                 `json:"messagesRead"`
+	MessagesWritten int64                 `json:"messagesWritten"`
+}
+
+// info returns a snapshot of the session
+func (ss *ServerSession) info() SessionInfo {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	info := SessionInfo{
+		ID:              ss.ID(),
+		ConnectedAt:     ss.connectedAt,
+		LastActive:      ss.lastActive,
+		ProtocolVersion: ss.state.ProtocolVersion,
+		PendingRequests: len(ss.pendingRequests),
+		Subscriptions:   slices.Clone(ss.state.Subscriptions),
+		LogLevel:        ss.state.LogLevel,
+		BytesRead:       ss.BytesRead(),
+		BytesWritten:    ss.BytesWritten(),
+		MessagesRead:    ss.MessagesRead(),
+		MessagesWritten: ss.MessagesWritten(),
+	}
+	if ss.state.InitializeParams != nil {
+		clientInfo := ss.state.InitializeParams.ClientInfo
+		info.ClientInfo = &clientInfo
+	}
+	return info
+}
+
+// touch records activity on the session
+func (ss *ServerSession) touch() {
+	ss.mu.Lock()
+	ss.lastActive = time.Now()
+	ss.mu.Unlock()
+}
+
+// ListSessions returns the sessions connected with Connect or Run, in connection order.
+// Stateless HTTP requests handled with HandleMessage are not included.
+func (s *Server) ListSessions() []SessionInfo {
+	s.mu.Lock()
+	sessions := slices.Clone(s.sessions)
+	s.mu.Unlock()
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, ss := range sessions {
+		infos = append(infos, ss.info())
+	}
+	return infos
+}
+
+// GetSession returns the connected session with the given ID
+func (s *Server) GetSession(id string) (*SessionInfo, bool) {
+	s.mu.Lock()
+	sessions := slices.Clone(s.sessions)
+	s.mu.Unlock()
+
+	for _, ss := range sessions {
+		if ss.ID() == id {
+			info := ss.info()
+			return &info, true
+		}
+	}
+	return nil, false
+}
+
+// AdminHandler serves the administrative API: GET /admin/sessions lists the sessions and
+// GET /admin/sessions/{id} returns one. With ServerOptions.AdminAuthToken, requests
+// must carry it as "Authorization: Bearer <token>".
+//
+// StartAdmin serves it on ServerOptions.AdminAddr; use it directly to mount the API elsewhere,
+// but not on the MCP handler's listener.
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, s.ListSessions())
+	})
+	mux.HandleFunc("GET /admin/sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		info, ok := s.GetSession(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		writeAdminJSON(w, info)
+	})
+
+	token := s.opts.AdminAuthToken
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mcp-admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeAdminJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// ErrAdminAuthRequired is returned by Server.StartAdmin when ServerOptions.AdminAddr is
+// not a loopback address and ServerOptions.AdminAuthToken is empty
+var ErrAdminAuthRequired = errors.New("admin API on a non-loopback address requires an auth token")
+
+// AdminAddr returns the address the administrative API listens on, or nil if it was
+// not started with StartAdmin
+func (s *Server) AdminAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.adminAddr
+}
+
+// StartAdmin serves AdminHandler on ServerOptions.AdminAddr until Shutdown. Without an
+// AdminAuthToken, only loopback addresses such as "localhost:9090" are accepted.
+func (s *Server) StartAdmin() error {
+	addr := s.opts.AdminAddr
+	if addr == "" {
+		return errors.New("admin API address not set")
+	}
+	if s.opts.AdminAuthToken == "" && !isLoopbackAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrAdminAuthRequired, addr)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.admin != nil {
+		return errors.New("admin API already started")
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("admin API: %w", err)
+	}
+	s.adminAddr = lis.Addr()
+	s.admin = &http.Server{Handler: s.AdminHandler(), ReadHeaderTimeout: 10 * time.Second}
+	go func(admin *http.Server) { _ = admin.Serve(lis) }(s.admin)
+	return nil
+}
+
+// isLoopbackAddr reports whether the host of addr is "localhost" or a loopback IP.
+// An empty host listens on every interface and is not loopback.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
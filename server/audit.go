@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// auditResultLimit caps the result summary recorded in an AuditEvent
+const auditResultLimit = 1024
+
+// AuditEvent records a single tools/call invocation
+type AuditEvent struct {
+	SessionID string          `json:"sessionId,omitempty"`
+	RequestID string          `json:"requestId,omitempty"`
+	ClientID  string          `json:"clientId,omitempty"`
+	ClientIP  string          `json:"clientIp,omitempty"`
+	ToolName  string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    string          `json:"result,omitempty"` // Text content of the result, truncated
+	IsError   bool            `json:"isError"`
+	Error     string          `json:"error,omitempty"` // Protocol-level error, if the call failed
+	StartTime time.Time       `json:"startTime"`
+	EndTime   time.Time       `json:"endTime"`
+}
+
+type ctxKeyRequestID struct{}
+
+func contextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKeyRequestID{}, requestID)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(ctxKeyRequestID{}).(string)
+	return requestID
+}
+
+// auditRecord collects details of a tools/call from the middleware chain for its AuditEvent
+type auditRecord struct {
+	mu        sync.Mutex
+	arguments map[string]any // masked arguments, see NewMaskingMiddleware
+}
+
+type ctxKeyAuditRecord struct{}
+
+func contextWithAuditRecord(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyAuditRecord{}, &auditRecord{})
+}
+
+func auditRecordFromContext(ctx context.Context) *auditRecord {
+	rec, _ := ctx.Value(ctxKeyAuditRecord{}).(*auditRecord)
+	return rec
+}
+
+func (r *auditRecord) setArguments(args map[string]any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.arguments = args
+}
+
+// audit hands the outcome of a tools/call to the audit handler without blocking the caller
+func (s *Server) audit(ctx context.Context, ss *ServerSession, params json.RawMessage, start time.Time, result interface{}, err error) {
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	_ = json.Unmarshal(params, &call)
+	var masked map[string]any
+	if rec := auditRecordFromContext(ctx); rec != nil {
+		rec.mu.Lock()
+		masked = rec.arguments
+		rec.mu.Unlock()
+	}
+	if masked == nil {
+		// The call did not reach the masking middleware, e.g. it was denied or is unknown
+		s.mu.Lock()
+		rules := s.maskingRules
+		s.mu.Unlock()
+		if len(rules) > 0 {
+			var args map[string]any
+			if err := json.Unmarshal(call.Arguments, &args); err == nil {
+				masked = maskToolArguments(call.Name, args, rules)
+			}
+		}
+	}
+	if masked != nil {
+		if data, err := json.Marshal(masked); err == nil {
+			call.Arguments = data
+		}
+	}
+
+	event := AuditEvent{
+		SessionID: ss.ID(),
+		RequestID: requestIDFromContext(ctx),
+		ClientID:  ss.ClientID(),
+		ClientIP:  ss.ClientIP(),
+		ToolName:  call.Name,
+		Arguments: call.Arguments,
+		StartTime: start,
+		EndTime:   time.Now(),
+	}
+	if err != nil {
+		event.IsError = true
+		event.Error = err.Error()
+	}
+	switch r := result.(type) {
+	case *protocol.CallToolResult:
+		if r != nil {
+			event.IsError = r.IsError
+			event.Result = summarizeToolResult(r)
+		}
+	case *protocol.CreateTaskResult:
+		event.Result = fmt.Sprintf("task %s created", r.Task.TaskID)
+	}
+
+	handler := s.opts.AuditHandler
+	go handler(context.WithoutCancel(ctx), event)
+}
+
+func summarizeToolResult(result *protocol.CallToolResult) string {
+	var parts []string
+	for _, c := range result.Content {
+		if text, ok := c.(protocol.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	summary := strings.Join(parts, "\n")
+	if len(summary) > auditResultLimit {
+		summary = summary[:auditResultLimit] + "..."
+	}
+	return summary
+}
+
+// FileAuditLogger appends audit events to a file as newline-delimited JSON, see
+// NewFileAuditLogger
+type FileAuditLogger struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// NewFileAuditLogger opens path in append-only mode, creating it if needed. Use its Log
+// method as ServerOptions.AuditHandler, and Close it once the server has shut down.
+func NewFileAuditLogger(path string) (*FileAuditLogger, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &FileAuditLogger{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Log appends event to the file; it is an AuditHandler. Write errors are dropped, as the
+// handler cannot report them.
+func (l *FileAuditLogger) Log(ctx context.Context, event AuditEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_ = l.enc.Encode(event)
+}
+
+// Close closes the file. Events logged afterwards are dropped.
+func (l *FileAuditLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
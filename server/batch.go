@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// handleBatch handles a JSON-RPC batch and returns a single batch response.
+// Notifications and client responses do not contribute to the response array,
+// so nil is returned when the batch contains no requests.
+func (s *Server) handleBatch(ctx context.Context, ss *ServerSession, batch []*protocol.JSONRPCMessage) *protocol.JSONRPCMessage {
+	if len(batch) == 0 {
+		return &protocol.JSONRPCMessage{
+			JSONRPC: "2.0",
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidRequest,
+				Message: "Invalid Request: empty batch",
+			},
+		}
+	}
+
+	concurrency := s.opts.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	responses := make([]*protocol.JSONRPCMessage, len(batch))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, msg := range batch {
+		if msg == nil || msg.IsBatch() {
+			responses[i] = &protocol.JSONRPCMessage{
+				JSONRPC: "2.0",
+				Error: &protocol.JSONRPCError{
+					Code:    protocol.InvalidRequest,
+					Message: "Invalid Request",
+				},
+			}
+			continue
+		}
+
+		// Responses to server-initiated requests are routed like unbatched ones
+		if msg.Method == "" && msg.ID != nil {
+			if adapter, ok := ss.conn.(*connAdapter); ok {
+				adapter.handleResponse(msg)
+			}
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, msg *protocol.JSONRPCMessage) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			responses[i] = s.handleMessage(ctx, ss, msg)
+		}(i, msg)
+	}
+	wg.Wait()
+
+	out := make([]*protocol.JSONRPCMessage, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			out = append(out, resp)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return protocol.NewBatchMessage(out...)
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// BroadcastNotification sends a notification to every connected session accepted by filter,
+// or to all of them if filter is nil. It returns the errors of failed sends, joined.
+func (s *Server) BroadcastNotification(method string, params interface{}, filter func(*ServerSession) bool) error {
+	s.mu.Lock()
+	sessions := make([]*ServerSession, len(s.sessions))
+	copy(sessions, s.sessions)
+	s.mu.Unlock()
+
+	var errs []error
+	for _, ss := range sessions {
+		if ss.conn == nil || (filter != nil && !filter(ss)) {
+			continue
+		}
+		if err := ss.conn.SendNotification(context.Background(), method, params); err != nil {
+			errs = append(errs, fmt.Errorf("session %s: %w", ss.ID(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FilterByClientID selects the sessions of the client authenticated as id (see ServerSession.ClientID)
+func FilterByClientID(id string) func(*ServerSession) bool {
+	return func(ss *ServerSession) bool {
+		return ss.ClientID() == id
+	}
+}
+
+// FilterAuthenticated selects the sessions established with transport authentication
+func FilterAuthenticated() func(*ServerSession) bool {
+	return func(ss *ServerSession) bool {
+		return ss.ClientID() != "" || ss.jwtClaims != nil
+	}
+}
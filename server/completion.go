@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// CompletionFn returns candidate values for an argument given the partial input typed so far,
+// and whether more candidates exist than were returned
+type CompletionFn func(ctx context.Context, input string) ([]string, bool, error)
+
+// completionKey identifies the argument a CompletionFn completes
+type completionKey struct {
+	refType protocol.ReferenceType
+	name    string
+	arg     string
+}
+
+// SetToolCompletion registers fn to complete argument argName of the named tool.
+// Clients request it with a "ref/tool" reference. A nil fn removes the completion.
+func (s *Server) SetToolCompletion(toolName, argName string, fn CompletionFn) {
+	s.setCompletion(completionKey{protocol.ReferenceTypeTool, toolName, argName}, fn)
+}
+
+// SetPromptCompletion registers fn to complete argument argName of the named prompt.
+// A nil fn removes the completion.
+func (s *Server) SetPromptCompletion(promptName, argName string, fn CompletionFn) {
+	s.setCompletion(completionKey{protocol.ReferenceTypePrompt, promptName, argName}, fn)
+}
+
+func (s *Server) setCompletion(key completionKey, fn CompletionFn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if fn == nil {
+		delete(s.completions, key)
+		return
+	}
+	s.completions[key] = fn
+}
+
+// lookupCompletion returns the completion registered for the request's reference and argument
+func (s *Server) lookupCompletion(req *protocol.CompleteRequest) (CompletionFn, error) {
+	ref, err := protocol.UnmarshalCompletionReference(req.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	key := completionKey{refType: ref.GetType(), arg: req.Argument.Name}
+	switch r := ref.(type) {
+	case protocol.ToolReference:
+		key.name = r.Name
+	case protocol.PromptReference:
+		key.name = r.Name
+	default:
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.completions[key], nil
+}
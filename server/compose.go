@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+type ctxKeyPipelineInput struct{}
+
+// PipelineInputFromContext returns the result of the previous step when called from a
+// step of a Pipeline, or nil for the first step and outside pipelines
+func PipelineInputFromContext(ctx context.Context) *protocol.CallToolResult {
+	result, _ := ctx.Value(ctxKeyPipelineInput{}).(*protocol.CallToolResult)
+	return result
+}
+
+// Pipeline chains handlers into one: each step receives the same request, with the
+// result of the previous step available from PipelineInputFromContext. The pipeline
+// stops at the first step returning an error or a tool error result and returns it;
+// otherwise the result of the last step is returned. Steps may themselves be wrapped
+// in middleware, and the pipeline can be registered and wrapped like any ToolHandler.
+func Pipeline(steps ...ToolHandler) ToolHandler {
+	return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+		var result *protocol.CallToolResult
+		for _, step := range steps {
+			var err error
+			result, err = step(context.WithValue(ctx, ctxKeyPipelineInput{}, result), req)
+			if err != nil || (result != nil && result.IsError) {
+				return result, err
+			}
+		}
+		if result == nil {
+			result = &protocol.CallToolResult{Content: []protocol.Content{}}
+		}
+		return result, nil
+	}
+}
+
+// Fallback returns a handler calling primary and, if it fails with an error or a tool
+// error result, calling fallback with the same request instead
+func Fallback(primary, fallback ToolHandler) ToolHandler {
+	return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+		result, err := primary(ctx, req)
+		if err == nil && (result == nil || !result.IsError) {
+			return result, nil
+		}
+		return fallback(ctx, req)
+	}
+}
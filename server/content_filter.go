@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// ErrProhibitedContent is returned by RegexContentFilter.FilterInput for arguments
+// matching one of its patterns
+var ErrProhibitedContent = errors.New("prohibited content")
+
+const filteredValue = "[FILTERED]"
+
+// ContentFilter screens tool calls for adversarial content such as prompt injections.
+// Implementations may use pattern lists or AI-based classifiers.
+type ContentFilter interface {
+	// FilterInput returns an error to reject a call with the given arguments
+	FilterInput(toolName string, args map[string]interface{}) error
+	// FilterOutput returns the result to send to the client in place of result
+	FilterOutput(result *protocol.CallToolResult) *protocol.CallToolResult
+}
+
+// NewContentFilterMiddleware checks tool arguments and results with filter. Rejected calls
+// are answered with an "input contains prohibited content" tool error without running the
+// tool.
+func NewContentFilterMiddleware(filter ContentFilter) Middleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+			if err := filter.FilterInput(req.Params.Name, req.Params.Arguments); err != nil {
+				return protocol.NewToolResultError("input contains prohibited content"), nil
+			}
+
+			result, err := next(ctx, req)
+			if result != nil {
+				result = filter.FilterOutput(result)
+			}
+			return result, err
+		}
+	}
+}
+
+// RegexContentFilter is a ContentFilter matching regular expressions, see
+// NewRegexContentFilter
+type RegexContentFilter struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRegexContentFilter creates a filter rejecting calls whose string arguments, at any
+// nesting level, match any of patterns, and replacing matches in the text content of
+// results with "[FILTERED]"
+func NewRegexContentFilter(patterns []*regexp.Regexp) *RegexContentFilter {
+	return &RegexContentFilter{patterns: patterns}
+}
+
+func (f *RegexContentFilter) FilterInput(toolName string, args map[string]interface{}) error {
+	if f.matchValue(args) {
+		return ErrProhibitedContent
+	}
+	return nil
+}
+
+func (f *RegexContentFilter) matchValue(v interface{}) bool {
+	switch val := v.(type) {
+	case string:
+		for _, p := range f.patterns {
+			if p.MatchString(val) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range val {
+			if f.matchValue(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if f.matchValue(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *RegexContentFilter) FilterOutput(result *protocol.CallToolResult) *protocol.CallToolResult {
+	var content []protocol.Content
+	for i, c := range result.Content {
+		text, ok := c.(protocol.TextContent)
+		if !ok {
+			continue
+		}
+		filtered := text.Text
+		for _, p := range f.patterns {
+			filtered = p.ReplaceAllString(filtered, filteredValue)
+		}
+		if filtered == text.Text {
+			continue
+		}
+		if content == nil {
+			// Copy on first change, handlers may return shared results
+			content = slices.Clone(result.Content)
+		}
+		text.Text = filtered
+		content[i] = text
+	}
+	if content == nil {
+		return result
+	}
+
+	filtered := *result
+	filtered.Content = content
+	return &filtered
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// AddDeduplicatedTool adds a tool whose identical concurrent calls share one execution.
+// The first call with given arguments runs h; calls with the same arguments arriving
+// before it completes wait for it and receive the same result or error. With
+// ToolOptions.DeduplicationWindow, calls arriving within that window after the first
+// one started also reuse its result.
+//
+// h runs with the context of the first call. It suits expensive read-only tools, such
+// as LLM calls or database queries, whose result does not depend on the caller.
+func (s *Server) AddDeduplicatedTool(t *protocol.Tool, h ToolHandler, opts ...*ToolOptions) error {
+	var toolOpts ToolOptions
+	if len(opts) > 0 && opts[0] != nil {
+		toolOpts = *opts[0]
+	}
+	toolOpts.dedupWaiting = new(atomic.Int32)
+	return s.AddTool(t, deduplicate(t.Name, h, toolOpts.DeduplicationWindow, toolOpts.dedupWaiting), &toolOpts)
+}
+
+// DeduplicationWaiters returns how many calls of a tool added with AddDeduplicatedTool
+// are waiting for the shared execution of an identical call
+func (s *Server) DeduplicationWaiters(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.tools[name]
+	if !ok || st.opts.dedupWaiting == nil {
+		return 0
+	}
+	return int(st.opts.dedupWaiting.Load())
+}
+
+// dedupKey identifies identical calls of a tool
+type dedupKey struct {
+	tool string
+	args string // hex SHA-256 of the JSON-encoded arguments
+}
+
+// toolFlight is a tool execution shared by identical calls
+type toolFlight struct {
+	done   chan struct{}
+	result *protocol.CallToolResult
+	err    error
+}
+
+func deduplicate(name string, h ToolHandler, window time.Duration, waiting *atomic.Int32) ToolHandler {
+	var flights sync.Map // dedupKey -> *toolFlight
+
+	return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+		// encoding/json sorts map keys, so equal arguments encode identically
+		args, err := json.Marshal(req.Params.Arguments)
+		if err != nil {
+			return h(ctx, req)
+		}
+		sum := sha256.Sum256(args)
+		key := dedupKey{tool: name, args: hex.EncodeToString(sum[:])}
+
+		flight := &toolFlight{done: make(chan struct{})}
+		if existing, loaded := flights.LoadOrStore(key, flight); loaded {
+			flight = existing.(*toolFlight)
+			waiting.Add(1)
+			select {
+			case <-flight.done:
+				waiting.Add(-1)
+			case <-ctx.Done():
+				waiting.Add(-1)
+				return nil, ctx.Err()
+			}
+			if flight.result == nil {
+				return nil, flight.err
+			}
+			result := *flight.result
+			return &result, flight.err
+		}
+
+		started := time.Now()
+		completed := false
+		defer func() {
+			if !completed {
+				// h panicked; don't leave the waiters without an answer
+				flight.result, flight.err = nil, fmt.Errorf("tool %q: shared call did not complete", name)
+			}
+			close(flight.done)
+
+			if remaining := window - time.Since(started); completed && remaining > 0 {
+				time.AfterFunc(remaining, func() { flights.CompareAndDelete(key, flight) })
+			} else {
+				flights.CompareAndDelete(key, flight)
+			}
+		}()
+		flight.result, flight.err = h(ctx, req)
+		completed = true
+		return flight.result, flight.err
+	}
+}
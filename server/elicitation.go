@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// elicitValueField is the schema property holding the answer to the single-value helpers
+const elicitValueField = "value"
+
+// ElicitWithTimeout is Elicit with a bound on how long the user may take to answer.
+// When timeout elapses first, the result has the ElicitationActionTimeout action and
+// no error is returned. Zero or negative waits until ctx ends.
+func (ss *ServerSession) ElicitWithTimeout(ctx context.Context, params *protocol.ElicitationCreateParams, timeout time.Duration) (*protocol.ElicitationResult, error) {
+	if timeout <= 0 {
+		return ss.elicit(ctx, params)
+	}
+
+	elicitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := ss.elicit(elicitCtx, params)
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return protocol.NewElicitationResult(protocol.ElicitationActionTimeout, nil), nil
+	}
+	return result, err
+}
+
+// ElicitStringWithTimeout asks the user for a single string. The value is "" unless the
+// result was accepted.
+func (ss *ServerSession) ElicitStringWithTimeout(ctx context.Context, message string, timeout time.Duration) (string, *protocol.ElicitationResult, error) {
+	schema := protocol.NewElicitationSchemaBuilder().AddStringField(elicitValueField, message, true).Build()
+	return ss.elicitValue(ctx, message, schema, nil, timeout)
+}
+
+// ElicitChoiceWithTimeout asks the user to pick one of options. The value is "" unless
+// the result was accepted.
+func (ss *ServerSession) ElicitChoiceWithTimeout(ctx context.Context, message string, options []string, timeout time.Duration) (string, *protocol.ElicitationResult, error) {
+	schema := protocol.NewElicitationSchemaBuilder().AddChoiceField(elicitValueField, message, options, true).Build()
+	return ss.elicitValue(ctx, message, schema, options, timeout)
+}
+
+func (ss *ServerSession) elicitValue(ctx context.Context, message string, schema protocol.JSONSchema, options []string, timeout time.Duration) (string, *protocol.ElicitationResult, error) {
+	result, err := ss.ElicitWithTimeout(ctx, protocol.NewElicitationCreateParams(message, schema), timeout)
+	if err != nil {
+		return "", nil, err
+	}
+	if !result.IsAccepted() {
+		return "", result, nil
+	}
+
+	content, _ := result.Content.(map[string]any)
+	value, ok := content[elicitValueField].(string)
+	if !ok {
+		return "", result, fmt.Errorf("elicitation answer has no string %q field", elicitValueField)
+	}
+	if options != nil && !slices.Contains(options, value) {
+		return "", result, fmt.Errorf("elicitation answer %q is not one of %v", value, options)
+	}
+	return value, result, nil
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// ElicitationFlow asks the client for input in several sequential elicitation steps,
+// wizard style, stopping at the first step the user declines or cancels
+type ElicitationFlow struct {
+	ctx       context.Context
+	session   *ServerSession
+	steps     []*protocol.ElicitationCreateParams
+	results   []protocol.ElicitationResult
+	onDecline func(stepIdx int)
+}
+
+// NewElicitationFlow creates an empty flow eliciting from session's client
+func NewElicitationFlow(ctx context.Context, session *ServerSession) *ElicitationFlow {
+	return &ElicitationFlow{ctx: ctx, session: session}
+}
+
+// Step queues a form mode elicitation
+func (f *ElicitationFlow) Step(message string, schema protocol.JSONSchema) *ElicitationFlow {
+	f.steps = append(f.steps, protocol.NewElicitationCreateParams(message, schema))
+	return f
+}
+
+// OnDecline sets a function called with the index of the step the user declined
+func (f *ElicitationFlow) OnDecline(fn func(stepIdx int)) *ElicitationFlow {
+	f.onDecline = fn
+	return f
+}
+
+// Run sends the steps in order and returns the results received. A declined or
+// cancelled step ends the flow without error; its result is the last one returned.
+// An error is returned if a step could not be completed, along with the earlier results.
+func (f *ElicitationFlow) Run() ([]protocol.ElicitationResult, error) {
+	f.results = f.results[:0]
+	for i, step := range f.steps {
+		result, err := f.session.Elicit(f.ctx, step)
+		if err != nil {
+			return f.results, fmt.Errorf("elicitation step %d: %w", i, err)
+		}
+		f.results = append(f.results, *result)
+
+		if result.IsDeclined() && f.onDecline != nil {
+			f.onDecline(i)
+		}
+		if !result.IsAccepted() {
+			break
+		}
+	}
+	return f.results, nil
+}
+
+// Result returns the result of step i from the last Run, or nil if that step was not reached
+func (f *ElicitationFlow) Result(i int) *protocol.ElicitationResult {
+	if i < 0 || i >= len(f.results) {
+		return nil
+	}
+	return &f.results[i]
+}
+
+// Completed reports whether the last Run got every step accepted
+func (f *ElicitationFlow) Completed() bool {
+	return len(f.results) == len(f.steps) &&
+		(len(f.results) == 0 || f.results[len(f.results)-1].IsAccepted())
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/voocel/mcp-sdk-go/protocol"
@@ -25,6 +26,66 @@ const (
 	ErrDependency     ErrorCode = "dependency_error" // Dependency Service Error
 )
 
+// MCPErrorCoder is implemented by errors that map to a specific JSON-RPC error code.
+// Handler errors implementing it are reported with that code instead of InternalError.
+type MCPErrorCoder interface {
+	MCPErrorCode() int
+}
+
+// RegisterErrorCode declares an application-defined JSON-RPC error code, e.g. -32010 for
+// authorization failures. code must lie in the server error range (-32099 to -32000) and
+// not be an MCP error code. Handler errors carrying a registered code, such as
+// protocol.NewMCPErrorWithCode(code, msg), are reported with the name in their data
+// unless they carry data of their own.
+func (s *Server) RegisterErrorCode(code int, name string) error {
+	if err := protocol.CheckCustomErrorCode(code); err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("error code %d: empty name", code)
+	}
+	s.errorCodes.Store(code, name)
+	return nil
+}
+
+// ErrorCodeName returns the name registered for code, or "" if it is not registered
+func (s *Server) ErrorCodeName(code int) string {
+	name, _ := s.errorCodes.Load(code)
+	n, _ := name.(string)
+	return n
+}
+
+// Sentinel errors for requests naming an unknown tool, resource or prompt.
+// They are returned wrapped with the name, so test for them with errors.Is.
+// Since protocol.MCPError matches by code, errors.Is also works on the errors
+// a client receives from the server.
+var (
+	ErrToolNotFound     error = &notFoundError{kind: "tool", code: protocol.ToolNotFound}
+	ErrResourceNotFound error = &notFoundError{kind: "resource", code: protocol.ResourceNotFound}
+	ErrPromptNotFound   error = &notFoundError{kind: "prompt", code: protocol.PromptNotFound}
+)
+
+// ErrToolExists is returned by Server.AddTool for a duplicate tool name when
+// ServerOptions.StrictToolRegistration is set
+var ErrToolExists = errors.New("tool already exists")
+
+// ErrNoConnection is returned by ServerSession.RotateToken for sessions created by
+// Server.HandleMessage, which have no connection to the client
+var ErrNoConnection = errors.New("session has no connection")
+
+type notFoundError struct {
+	kind string
+	code int
+}
+
+func (e *notFoundError) Error() string {
+	return e.kind + " not found"
+}
+
+func (e *notFoundError) MCPErrorCode() int {
+	return e.code
+}
+
 type ToolError struct {
 	Code    ErrorCode
 	Message string
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// UpdateResourceETag records the current ETag of a resource added with AddResource.
+// Resource handlers call it after the underlying content changes; a conditional read whose
+// IfNoneMatch equals the recorded ETag is then answered with NotModified without calling
+// the handler. An empty etag reverts to hashing the contents returned by each read.
+// Unknown URIs are ignored.
+func (s *Server) UpdateResourceETag(uri string, etag string) {
+	s.mu.Lock()
+	if sr, exists := s.resources[uri]; exists {
+		sr.etag = etag
+	}
+	s.mu.Unlock()
+}
+
+// ResourceETag returns an ETag derived from the URI, type and data of contents
+func ResourceETag(contents []protocol.ResourceContents) string {
+	h := md5.New()
+	for _, c := range contents {
+		for _, field := range []string{c.URI, c.MimeType, c.Text, c.Blob} {
+			h.Write([]byte(field))
+			h.Write([]byte{0})
+		}
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// tagContents fills in the ETag of contents that have none and returns it. etag is the
+// resource's recorded ETag; without one, an ETag set by the handler is used, falling
+// back to a hash of the contents.
+func tagContents(contents []protocol.ResourceContents, etag string) string {
+	if etag == "" {
+		for _, c := range contents {
+			if c.ETag != "" {
+				etag = c.ETag
+				break
+			}
+		}
+	}
+	if etag == "" {
+		etag = ResourceETag(contents)
+	}
+	for i := range contents {
+		if contents[i].ETag == "" {
+			contents[i].ETag = etag
+		}
+	}
+	return etag
+}
+
+func notModifiedResult() *protocol.ReadResourceResult {
+	return &protocol.ReadResourceResult{Contents: []protocol.ResourceContents{}, NotModified: true}
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/protocol/mime"
+)
+
+// FSResourceOptions selects the files an FSResourceProvider exposes.
+// Patterns use path.Match syntax and are matched against both the slash-separated path
+// relative to the root and the base name, so "*.go" matches Go files at any depth and
+// "docs/*" the files directly under docs. An excluded directory is skipped entirely.
+type FSResourceOptions struct {
+	// Include lists the patterns of files to expose; empty exposes every file
+	Include []string
+	// Exclude lists the patterns of files and directories to leave out
+	Exclude []string
+	// MaxFileSize leaves out larger files, in bytes. Zero means no limit.
+	MaxFileSize int64
+	// IncludeHidden exposes files and directories whose name starts with a dot
+	IncludeHidden bool
+}
+
+// FSResourceProvider exposes the files under a directory as resources, with URIs of
+// the form file:///<relpath>
+type FSResourceProvider struct {
+	root     string
+	realRoot string // root with symlinks resolved, which served files must be under
+	opts     FSResourceOptions
+
+	mu   sync.Mutex
+	uris map[string]bool // registered resource URIs
+}
+
+// NewFSResourceProvider creates a provider for the files under root
+func NewFSResourceProvider(root string, opts FSResourceOptions) *FSResourceProvider {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	realRoot := root
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		realRoot = resolved
+	}
+	return &FSResourceProvider{
+		root:     root,
+		realRoot: realRoot,
+		opts:     opts,
+		uris:     make(map[string]bool),
+	}
+}
+
+// Register walks the directory tree and adds a resource for every qualifying file.
+// Contents are read from disk on each resources/read, text files as text and others
+// as blobs, with the MIME type detected from the contents and file extension.
+func (p *FSResourceProvider) Register(s *Server) error {
+	return p.registerDir(s, p.root)
+}
+
+// Watch keeps the resources of s in sync with the directory tree until ctx is done:
+// new qualifying files are added, removed files are removed and subscribers of modified
+// files are notified. Call Register first to add the existing files.
+func (p *FSResourceProvider) Watch(ctx context.Context, s *Server) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create file watcher: %w", err)
+	}
+	if err := p.watchDir(watcher, p.root); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				p.handleEvent(s, watcher, event)
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *FSResourceProvider) handleEvent(s *Server, watcher *fsnotify.Watcher, event fsnotify.Event) {
+	rel, err := filepath.Rel(p.root, event.Name)
+	if err != nil || rel == "." {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	uri := "file:///" + rel
+
+	switch {
+	case event.Op.Has(fsnotify.Remove) || event.Op.Has(fsnotify.Rename):
+		// The name is gone; for a directory, so are the files under it
+		p.removeUnder(s, uri)
+	case event.Op.Has(fsnotify.Create) || event.Op.Has(fsnotify.Write):
+		// Lstat, so that symlinks are not followed out of the root; they never qualify
+		info, err := os.Lstat(event.Name)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			if event.Op.Has(fsnotify.Create) && !p.excluded(rel) {
+				p.watchDir(watcher, event.Name)
+				p.registerDir(s, event.Name)
+			}
+			return
+		}
+
+		p.mu.Lock()
+		registered := p.uris[uri]
+		p.mu.Unlock()
+		switch qualifies := p.qualifies(rel, info); {
+		case registered && qualifies:
+			s.NotifyResourceUpdated(uri)
+		case registered:
+			p.remove(s, uri)
+		case qualifies:
+			p.add(s, event.Name, rel, info)
+		}
+	}
+}
+
+// registerDir adds the qualifying files under dir
+func (p *FSResourceProvider) registerDir(s *Server, dir string) error {
+	return filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(p.root, name)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if p.excluded(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if p.qualifies(rel, info) {
+			p.add(s, name, rel, info)
+		}
+		return nil
+	})
+}
+
+// watchDir watches dir and the directories under it that are not excluded
+func (p *FSResourceProvider) watchDir(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if rel, _ := filepath.Rel(p.root, name); rel != "." && p.excluded(filepath.ToSlash(rel)) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(name); err != nil {
+			return fmt.Errorf("watch %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// qualifies reports whether the file at rel is exposed
+func (p *FSResourceProvider) qualifies(rel string, info fs.FileInfo) bool {
+	if !info.Mode().IsRegular() || p.excluded(rel) {
+		return false
+	}
+	if p.opts.MaxFileSize > 0 && info.Size() > p.opts.MaxFileSize {
+		return false
+	}
+	return len(p.opts.Include) == 0 || matchAny(p.opts.Include, rel)
+}
+
+// excluded reports whether the file or directory at rel is hidden or matches an
+// exclude pattern. Include patterns only apply to files.
+func (p *FSResourceProvider) excluded(rel string) bool {
+	if !p.opts.IncludeHidden {
+		for _, part := range strings.Split(rel, "/") {
+			if strings.HasPrefix(part, ".") {
+				return true
+			}
+		}
+	}
+	return matchAny(p.opts.Exclude, rel)
+}
+
+func matchAny(patterns []string, rel string) bool {
+	base := path.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *FSResourceProvider) add(s *Server, name, rel string, info fs.FileInfo) {
+	uri := "file:///" + rel
+	size := info.Size()
+	s.AddResource(&protocol.Resource{
+		URI:      uri,
+		Name:     rel,
+		MimeType: detectFileMimeType(name),
+		Size:     &size,
+	}, p.readHandler(name))
+
+	p.mu.Lock()
+	p.uris[uri] = true
+	p.mu.Unlock()
+}
+
+func (p *FSResourceProvider) remove(s *Server, uri string) {
+	p.mu.Lock()
+	delete(p.uris, uri)
+	p.mu.Unlock()
+	s.RemoveResource(uri)
+}
+
+// removeUnder removes uri and the resources below it
+func (p *FSResourceProvider) removeUnder(s *Server, uri string) {
+	p.mu.Lock()
+	var removed []string
+	for u := range p.uris {
+		if u == uri || strings.HasPrefix(u, uri+"/") {
+			removed = append(removed, u)
+			delete(p.uris, u)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, u := range removed {
+		s.RemoveResource(u)
+	}
+}
+
+func (p *FSResourceProvider) readHandler(name string) ResourceHandler {
+	return func(ctx context.Context, req *ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+		// The file may have been replaced by a symlink since it was registered
+		resolved, err := filepath.EvalSymlinks(name)
+		if err != nil || !p.underRoot(resolved) {
+			return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, req.Params.URI)
+		}
+		name := resolved
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, req.Params.URI)
+		}
+		if p.opts.MaxFileSize > 0 && info.Size() > p.opts.MaxFileSize {
+			return nil, protocol.NewMCPError(protocol.InvalidParams, "File exceeds the maximum size", map[string]any{
+				"uri":     req.Params.URI,
+				"size":    info.Size(),
+				"maxSize": p.opts.MaxFileSize,
+			})
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", req.Params.URI, err)
+		}
+
+		mimeType := mime.DetectMimeType(data, name)
+		if isTextMimeType(mimeType) || (mimeType == mime.DefaultMimeType && utf8.Valid(data)) {
+			contents := protocol.NewTextResourceContents(req.Params.URI, string(data))
+			if mimeType != mime.DefaultMimeType {
+				contents.MimeType = mimeType
+			}
+			return protocol.NewReadResourceResult(contents), nil
+		}
+		return protocol.NewReadResourceResult(protocol.NewBlobResourceContents(req.Params.URI, mimeType, data)), nil
+	}
+}
+
+// underRoot reports whether the symlink-free path name is inside the provider's root
+func (p *FSResourceProvider) underRoot(name string) bool {
+	rel, err := filepath.Rel(p.realRoot, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// detectFileMimeType detects the MIME type of a file from its first bytes and name
+func detectFileMimeType(name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		return mime.TypeByExtension(name)
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	mimeType := mime.DetectMimeType(head[:n], name)
+	if mimeType == mime.DefaultMimeType && utf8.Valid(head[:completeUTF8Prefix(head[:n])]) {
+		return "text/plain"
+	}
+	return mimeType
+}
@@ -0,0 +1,554 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// defaultHTTPUserAgent is sent when HTTPResourceOptions.UserAgent is empty
+const defaultHTTPUserAgent = "mcp-sdk-go"
+
+// defaultHTTPResourceMaxBytes limits response bodies when HTTPResourceOptions.MaxBytes is zero
+const defaultHTTPResourceMaxBytes = 10 << 20
+
+// HTTPResourceOptions configures an HTTPResourceProvider
+type HTTPResourceOptions struct {
+	// Timeout limits each request. Zero means 30 seconds.
+	Timeout time.Duration
+	// CacheTTL is how long fetched contents are served from the cache. While a resource
+	// has subscribers it is refetched every CacheTTL and they are notified when its
+	// contents change. Zero disables caching.
+	CacheTTL time.Duration
+	// UserAgent is sent with every request and used to select the robots.txt rules
+	UserAgent string
+	// TransformHTML converts HTML pages to markdown, dropping scripts, styles and markup
+	TransformHTML bool
+	// RespectRobotsTxt refuses to fetch URLs that the site's robots.txt disallows
+	RespectRobotsTxt bool
+	// MaxBytes limits the size of fetched response bodies, larger responses fail the
+	// read. Zero means 10 MiB.
+	MaxBytes int64
+}
+
+// HTTPResourceProvider exposes remote URLs as resources whose URI is the URL itself
+type HTTPResourceProvider struct {
+	urls   []string
+	opts   HTTPResourceOptions
+	client *http.Client
+
+	mu     sync.Mutex
+	cache  map[string]*httpCacheEntry
+	robots map[string]*robotsRules // scheme://host -> rules
+}
+
+type httpCacheEntry struct {
+	contents protocol.ResourceContents
+	expires  time.Time
+}
+
+// NewHTTPResourceProvider creates a provider for urls
+func NewHTTPResourceProvider(urls []string, opts HTTPResourceOptions) *HTTPResourceProvider {
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = defaultHTTPUserAgent
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultHTTPResourceMaxBytes
+	}
+	return &HTTPResourceProvider{
+		urls:   urls,
+		opts:   opts,
+		client: &http.Client{Timeout: timeout},
+		cache:  make(map[string]*httpCacheEntry),
+		robots: make(map[string]*robotsRules),
+	}
+}
+
+// Register adds a resource for every URL. Contents are fetched on the first
+// resources/read and cached for CacheTTL.
+func (p *HTTPResourceProvider) Register(s *Server) error {
+	for _, rawURL := range p.urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("parse resource URL %q: %w", rawURL, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("resource URL %q: must be an absolute http or https URL", rawURL)
+		}
+	}
+
+	for _, rawURL := range p.urls {
+		r := &protocol.Resource{URI: rawURL, Name: rawURL}
+		if p.opts.CacheTTL > 0 {
+			s.AddWatchedResource(r, p.readHandler(), p)
+		} else {
+			s.AddResource(r, p.readHandler())
+		}
+	}
+	return nil
+}
+
+func (p *HTTPResourceProvider) readHandler() ResourceHandler {
+	return func(ctx context.Context, req *ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+		contents, err := p.get(ctx, req.Params.URI, false)
+		if err != nil {
+			return nil, err
+		}
+		return protocol.NewReadResourceResult(contents), nil
+	}
+}
+
+// get returns the contents of uri from the cache, or fetches them when they expired
+// or refresh is set
+func (p *HTTPResourceProvider) get(ctx context.Context, uri string, refresh bool) (protocol.ResourceContents, error) {
+	if !refresh {
+		p.mu.Lock()
+		entry := p.cache[uri]
+		p.mu.Unlock()
+		if entry != nil && time.Now().Before(entry.expires) {
+			return entry.contents, nil
+		}
+	}
+
+	contents, err := p.fetch(ctx, uri)
+	if err != nil {
+		return protocol.ResourceContents{}, err
+	}
+	if p.opts.CacheTTL > 0 {
+		p.mu.Lock()
+		p.cache[uri] = &httpCacheEntry{contents: contents, expires: time.Now().Add(p.opts.CacheTTL)}
+		p.mu.Unlock()
+	}
+	return contents, nil
+}
+
+func (p *HTTPResourceProvider) fetch(ctx context.Context, uri string) (protocol.ResourceContents, error) {
+	if p.opts.RespectRobotsTxt {
+		allowed, err := p.robotsAllowed(ctx, uri)
+		if err != nil {
+			return protocol.ResourceContents{}, err
+		}
+		if !allowed {
+			return protocol.ResourceContents{}, protocol.NewMCPError(protocol.InvalidParams, "URL disallowed by robots.txt", map[string]any{"uri": uri})
+		}
+	}
+
+	resp, err := p.do(ctx, uri)
+	if err != nil {
+		return protocol.ResourceContents{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return protocol.ResourceContents{}, fmt.Errorf("fetch %s: %s", uri, resp.Status)
+	}
+	if resp.ContentLength > p.opts.MaxBytes {
+		return protocol.ResourceContents{}, fmt.Errorf("fetch %s: response of %d bytes exceeds the %d byte limit", uri, resp.ContentLength, p.opts.MaxBytes)
+	}
+	// Read one byte past the limit to tell a body of exactly MaxBytes from a larger one
+	data, err := io.ReadAll(io.LimitReader(resp.Body, p.opts.MaxBytes+1))
+	if err != nil {
+		return protocol.ResourceContents{}, fmt.Errorf("fetch %s: %w", uri, err)
+	}
+	if int64(len(data)) > p.opts.MaxBytes {
+		return protocol.ResourceContents{}, fmt.Errorf("fetch %s: response exceeds the %d byte limit", uri, p.opts.MaxBytes)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+		mimeType, _, _ = mime.ParseMediaType(mimeType)
+	}
+	if mimeType == "text/html" && p.opts.TransformHTML {
+		text, err := htmlToMarkdown(data)
+		if err != nil {
+			return protocol.ResourceContents{}, fmt.Errorf("convert %s: %w", uri, err)
+		}
+		contents := protocol.NewTextResourceContents(uri, text)
+		contents.MimeType = "text/markdown"
+		return contents, nil
+	}
+	if isTextMimeType(mimeType) && utf8.Valid(data) {
+		contents := protocol.NewTextResourceContents(uri, string(data))
+		contents.MimeType = mimeType
+		return contents, nil
+	}
+	return protocol.NewBlobResourceContents(uri, mimeType, data), nil
+}
+
+func (p *HTTPResourceProvider) do(ctx context.Context, uri string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", uri, err)
+	}
+	req.Header.Set("User-Agent", p.opts.UserAgent)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", uri, err)
+	}
+	return resp, nil
+}
+
+// Watch refetches uri every CacheTTL and reports changed contents. It implements
+// ResourceWatcher, so refetching only happens while the resource has subscribers.
+func (p *HTTPResourceProvider) Watch(ctx context.Context, uri string, changed chan<- struct{}) error {
+	go func() {
+		ticker := time.NewTicker(p.opts.CacheTTL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			p.mu.Lock()
+			var previous *protocol.ResourceContents
+			if entry := p.cache[uri]; entry != nil {
+				previous = &entry.contents
+			}
+			p.mu.Unlock()
+
+			contents, err := p.get(ctx, uri, true)
+			if err != nil || (previous != nil && sameContents(contents, *previous)) {
+				continue
+			}
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return nil
+}
+
+func sameContents(a, b protocol.ResourceContents) bool {
+	return a.MimeType == b.MimeType && a.Text == b.Text && a.Blob == b.Blob
+}
+
+// Unwatch implements ResourceWatcher; the refetch loop ends with the Watch context
+func (p *HTTPResourceProvider) Unwatch(uri string) {}
+
+// robotsAllowed reports whether the robots.txt of uri's site lets the user agent fetch it.
+// Rules are fetched once per site; a missing robots.txt allows everything.
+func (p *HTTPResourceProvider) robotsAllowed(ctx context.Context, uri string) (bool, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false, fmt.Errorf("parse %s: %w", uri, err)
+	}
+	site := u.Scheme + "://" + u.Host
+
+	p.mu.Lock()
+	rules, ok := p.robots[site]
+	p.mu.Unlock()
+	if !ok {
+		resp, err := p.do(ctx, site+"/robots.txt")
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+			rules = parseRobotsTxt(io.LimitReader(resp.Body, p.opts.MaxBytes), p.opts.UserAgent)
+		case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+			rules = &robotsRules{}
+		default:
+			// Unreachable robots.txt: don't cache, try again on the next fetch
+			return false, fmt.Errorf("fetch %s/robots.txt: %s", site, resp.Status)
+		}
+		p.mu.Lock()
+		p.robots[site] = rules
+		p.mu.Unlock()
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allowed(path), nil
+}
+
+// robotsRules are the Allow and Disallow lines of the robots.txt group for a user agent
+type robotsRules struct {
+	allow    []string
+	disallow []string
+}
+
+// parseRobotsTxt returns the rules of the group matching userAgent, or of the "*" group
+// if no group names it
+func parseRobotsTxt(r io.Reader, userAgent string) *robotsRules {
+	product := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0])
+
+	var specific, wildcard robotsRules
+	var foundSpecific bool
+	var current []*robotsRules // groups the lines being read apply to
+	inAgents := false          // reading the User-agent lines of a group
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = nil
+				inAgents = true
+			}
+			agent := strings.ToLower(value)
+			switch {
+			case agent == "*":
+				current = append(current, &wildcard)
+			case agent != "" && strings.Contains(product, agent):
+				current = append(current, &specific)
+				foundSpecific = true
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if value == "" {
+				continue
+			}
+			for _, rules := range current {
+				if key == "allow" {
+					rules.allow = append(rules.allow, value)
+				} else {
+					rules.disallow = append(rules.disallow, value)
+				}
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	if foundSpecific {
+		return &specific
+	}
+	return &wildcard
+}
+
+// allowed applies the most specific (longest) matching rule to path; Allow wins ties
+func (r *robotsRules) allowed(path string) bool {
+	allowLen, disallowLen := -1, -1
+	for _, pattern := range r.allow {
+		if len(pattern) > allowLen && robotsMatch(pattern, path) {
+			allowLen = len(pattern)
+		}
+	}
+	for _, pattern := range r.disallow {
+		if len(pattern) > disallowLen && robotsMatch(pattern, path) {
+			disallowLen = len(pattern)
+		}
+	}
+	return disallowLen < 0 || allowLen >= disallowLen
+}
+
+// robotsMatch matches a robots.txt path pattern, where * matches any characters and a
+// trailing $ anchors the end of the path, against the start of path
+func robotsMatch(pattern, path string) bool {
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSuffix(pattern, "$")), `\*`, ".*")
+	if strings.HasSuffix(pattern, "$") {
+		expr += "$"
+	}
+	re, err := regexp.Compile(expr)
+	return err == nil && re.MatchString(path)
+}
+
+// htmlToMarkdown renders the text of an HTML document as markdown: headings, paragraphs,
+// lists, links, emphasis and code are kept, other markup is dropped
+func htmlToMarkdown(data []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	var b markdownBuilder
+	b.render(doc)
+	return strings.TrimSpace(b.String()) + "\n", nil
+}
+
+type markdownBuilder struct {
+	strings.Builder
+	pre  bool
+	list []int // item counters of the enclosing lists, -1 for unordered
+}
+
+// block ends the current line and leaves a blank line
+func (b *markdownBuilder) block() {
+	s := b.String()
+	switch {
+	case s == "" || strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		b.WriteString("\n")
+	default:
+		b.WriteString("\n\n")
+	}
+}
+
+func (b *markdownBuilder) newline() {
+	if s := b.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		b.WriteString("\n")
+	}
+}
+
+// text writes s with its whitespace collapsed, except in preformatted blocks
+func (b *markdownBuilder) text(s string) {
+	if b.pre {
+		b.WriteString(s)
+		return
+	}
+	if s != "" && strings.TrimLeft(s, " \t\r\n") != s {
+		b.space()
+	}
+	b.WriteString(strings.Join(strings.Fields(s), " "))
+	if s != "" && strings.TrimRight(s, " \t\r\n") != s {
+		b.space()
+	}
+}
+
+// space separates words, unless at the start of a line
+func (b *markdownBuilder) space() {
+	if s := b.String(); s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		b.WriteString(" ")
+	}
+}
+
+func (b *markdownBuilder) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.render(c)
+	}
+}
+
+func (b *markdownBuilder) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		b.children(n)
+		return
+	}
+
+	switch n.Data {
+	case "script", "style", "noscript", "head", "template", "svg", "iframe":
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		b.block()
+		b.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		b.children(n)
+		b.block()
+	case "p", "div", "section", "article", "header", "footer", "main", "nav", "table", "blockquote":
+		b.block()
+		b.children(n)
+		b.block()
+	case "tr":
+		b.newline()
+		b.children(n)
+	case "td", "th":
+		b.children(n)
+		b.WriteString(" ")
+	case "br":
+		b.WriteString("\n")
+	case "hr":
+		b.block()
+		b.WriteString("---")
+		b.block()
+	case "ul", "ol":
+		counter := -1
+		if n.Data == "ol" {
+			counter = 0
+		}
+		b.newline()
+		b.list = append(b.list, counter)
+		b.children(n)
+		b.list = b.list[:len(b.list)-1]
+		b.block()
+	case "li":
+		b.newline()
+		depth := len(b.list)
+		if depth == 0 {
+			b.WriteString("- ")
+		} else {
+			b.WriteString(strings.Repeat("  ", depth-1))
+			if b.list[depth-1] >= 0 {
+				b.list[depth-1]++
+				fmt.Fprintf(b, "%d. ", b.list[depth-1])
+			} else {
+				b.WriteString("- ")
+			}
+		}
+		b.children(n)
+	case "a":
+		href := htmlAttr(n, "href")
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			b.children(n)
+			return
+		}
+		b.WriteString("[")
+		b.children(n)
+		b.WriteString("](" + href + ")")
+	case "strong", "b":
+		b.WriteString("**")
+		b.children(n)
+		b.WriteString("**")
+	case "em", "i":
+		b.WriteString("_")
+		b.children(n)
+		b.WriteString("_")
+	case "code":
+		if b.pre {
+			b.children(n)
+			return
+		}
+		b.WriteString("`")
+		b.children(n)
+		b.WriteString("`")
+	case "pre":
+		b.block()
+		b.WriteString("```\n")
+		b.pre = true
+		b.children(n)
+		b.pre = false
+		b.newline()
+		b.WriteString("```")
+		b.block()
+	case "img":
+		if alt := htmlAttr(n, "alt"); alt != "" {
+			b.WriteString("![" + alt + "](" + htmlAttr(n, "src") + ")")
+		}
+	default:
+		b.children(n)
+	}
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
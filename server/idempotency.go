@@ -0,0 +1,173 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// defaultIdempotencyTTL is how long results are kept when ServerOptions.IdempotencyTTL is zero
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// IdempotencyStore keeps the results of tools/call requests by idempotency key, see
+// protocol.CallToolParams.IdempotencyKey. Implementations backed by a shared store such
+// as Redis let retries reach any server instance.
+type IdempotencyStore interface {
+	// Check returns the result stored for key, if it has not expired
+	Check(key string) (*protocol.CallToolResult, bool)
+	// Store keeps result for key during ttl
+	Store(key string, result *protocol.CallToolResult, ttl time.Duration)
+}
+
+// idempotencySweepMin is the number of stored results below which
+// InMemoryIdempotencyStore skips cleanup
+const idempotencySweepMin = 1024
+
+// DefaultIdempotencyEntries is the number of results kept by an InMemoryIdempotencyStore
+// whose MaxEntries is zero
+const DefaultIdempotencyEntries = 10000
+
+// InMemoryIdempotencyStore is an in-process IdempotencyStore. Expired results are swept
+// whenever the store doubles in size, and the oldest results are dropped beyond MaxEntries.
+type InMemoryIdempotencyStore struct {
+	// MaxEntries caps the number of stored results. Zero means DefaultIdempotencyEntries.
+	// Set it before the store is used.
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // key -> element of order holding an *idempotencyEntry
+	order   *list.List               // oldest first
+	sweepAt int
+}
+
+type idempotencyEntry struct {
+	key     string
+	result  *protocol.CallToolResult
+	expires time.Time
+}
+
+// NewInMemoryIdempotencyStore creates an empty in-memory store
+func NewInMemoryIdempotencyStore() *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		sweepAt: idempotencySweepMin,
+	}
+}
+
+func (m *InMemoryIdempotencyStore) Check(key string) (*protocol.CallToolResult, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*idempotencyEntry)
+	if time.Now().After(entry.expires) {
+		m.remove(elem)
+		return nil, false
+	}
+	return entry.result, true
+}
+
+func (m *InMemoryIdempotencyStore) Store(key string, result *protocol.CallToolResult, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+	if len(m.entries) >= m.sweepAt {
+		for elem := m.order.Front(); elem != nil; {
+			next := elem.Next()
+			if now.After(elem.Value.(*idempotencyEntry).expires) {
+				m.remove(elem)
+			}
+			elem = next
+		}
+		m.sweepAt = max(2*len(m.entries), idempotencySweepMin)
+	}
+	maxEntries := m.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultIdempotencyEntries
+	}
+	for m.order.Len() >= maxEntries {
+		m.remove(m.order.Front())
+	}
+	m.entries[key] = m.order.PushBack(&idempotencyEntry{key: key, result: result, expires: now.Add(ttl)})
+}
+
+func (m *InMemoryIdempotencyStore) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.entries, elem.Value.(*idempotencyEntry).key)
+}
+
+// idempotencyStoreKey scopes an idempotency key to the calling client and a tool, so that
+// clients reusing keys across tools don't receive the result of another tool, and no
+// client receives the result of another. Clients are identified by their authenticated
+// client ID, or else by their session ID or, for stateless requests, their IP address.
+func idempotencyStoreKey(ss *ServerSession, tool, key string) string {
+	var owner string
+	switch {
+	case ss == nil:
+	case ss.ClientID() != "":
+		owner = "id:" + ss.ClientID()
+	case ss.ID() != "":
+		owner = "session:" + ss.ID()
+	default:
+		owner = "ip:" + ss.ClientIP()
+	}
+	return owner + "\x00" + tool + "\x00" + key
+}
+
+// callToolIdempotent runs call unless the store holds a result for the request's
+// idempotency key, and stores the result of a successful call. A retry arriving while
+// the call with its key is still running waits for it, then returns its stored result,
+// or runs the tool itself if that call failed.
+func (s *Server) callToolIdempotent(ctx context.Context, ss *ServerSession, req *protocol.CallToolParams, call func() (*protocol.CallToolResult, error)) (*protocol.CallToolResult, error) {
+	store := s.opts.IdempotencyStore
+	if store == nil || req.IdempotencyKey == "" {
+		return call()
+	}
+
+	key := idempotencyStoreKey(ss, req.Name, req.IdempotencyKey)
+	flight := &toolFlight{done: make(chan struct{})}
+	for {
+		if result, ok := store.Check(key); ok {
+			return result, nil
+		}
+		existing, running := s.idempotencyFlights.LoadOrStore(key, flight)
+		if !running {
+			break
+		}
+		select {
+		case <-existing.(*toolFlight).done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	defer func() {
+		s.idempotencyFlights.CompareAndDelete(key, flight)
+		close(flight.done)
+	}()
+
+	// A call that completed between Check and LoadOrStore has stored its result
+	if result, ok := store.Check(key); ok {
+		return result, nil
+	}
+	result, err := call()
+	if err != nil || result == nil || result.IsError {
+		return result, err
+	}
+	ttl := s.opts.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	store.Store(key, result, ttl)
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// SendLog sends a notifications/message log entry to the client. As with Log, nothing is
+// sent unless the client enabled logging at level or below via logging/setLevel.
+func (ss *ServerSession) SendLog(ctx context.Context, level protocol.LoggingLevel, logger string, data interface{}) error {
+	return ss.Log(ctx, &protocol.LoggingMessageParams{
+		Level:  level,
+		Logger: logger,
+		Data:   data,
+	})
+}
+
+// SessionLogger sends structured log messages to the client of the current tool call.
+// Each message is sent as a JSON object holding "msg" and the given key-value fields.
+type SessionLogger struct {
+	ctx     context.Context
+	session *ServerSession
+	name    string
+}
+
+type ctxKeySessionLogger struct{}
+
+func contextWithLogger(ctx context.Context, logger *SessionLogger) context.Context {
+	return context.WithValue(ctx, ctxKeySessionLogger{}, logger)
+}
+
+// LoggerFromContext returns the logger for the current tool call, named after the tool.
+// Outside a tool call, the returned logger discards messages.
+func LoggerFromContext(ctx context.Context) *SessionLogger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(ctxKeySessionLogger{}).(*SessionLogger); ok {
+			return logger
+		}
+	}
+	return &SessionLogger{}
+}
+
+// newLogger keeps the values of ctx (such as the task ID) but not its cancellation
+func (r *CallToolRequest) newLogger(ctx context.Context) *SessionLogger {
+	return &SessionLogger{ctx: context.WithoutCancel(ctx), session: r.Session, name: r.Params.Name}
+}
+
+// Named returns a copy of the logger reporting name as the logger name
+func (l *SessionLogger) Named(name string) *SessionLogger {
+	named := *l
+	named.name = name
+	return &named
+}
+
+func (l *SessionLogger) Debug(msg string, fields ...interface{}) error {
+	return l.log(protocol.LogLevelDebug, msg, fields)
+}
+
+func (l *SessionLogger) Info(msg string, fields ...interface{}) error {
+	return l.log(protocol.LogLevelInfo, msg, fields)
+}
+
+func (l *SessionLogger) Warn(msg string, fields ...interface{}) error {
+	return l.log(protocol.LogLevelWarning, msg, fields)
+}
+
+func (l *SessionLogger) Error(msg string, fields ...interface{}) error {
+	return l.log(protocol.LogLevelError, msg, fields)
+}
+
+func (l *SessionLogger) log(level protocol.LoggingLevel, msg string, fields []interface{}) error {
+	if l.session == nil || l.session.conn == nil {
+		return nil
+	}
+	return l.session.SendLog(l.ctx, level, l.name, logData(msg, fields))
+}
+
+// logData pairs up fields as keys and values, as log/slog does; a trailing value without
+// a key is reported under "!BADKEY"
+func logData(msg string, fields []interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(fields)/2+1)
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 == len(fields) {
+			data["!BADKEY"] = fields[i]
+			break
+		}
+		key, ok := fields[i].(string)
+		if !ok {
+			key = fmt.Sprint(fields[i])
+		}
+		data[key] = fields[i+1]
+	}
+	data["msg"] = msg
+	return data
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// MaskingStrategy is how a MaskingRule hides a value
+type MaskingStrategy string
+
+const (
+	// MaskingRedact replaces the value with "[REDACTED]"
+	MaskingRedact MaskingStrategy = "redact"
+	// MaskingHash replaces the value with the hex SHA-256 of its text
+	MaskingHash MaskingStrategy = "hash"
+	// MaskingPartialMask keeps the first and last MaskingRule.Visible characters
+	MaskingPartialMask MaskingStrategy = "partial"
+)
+
+const redactedValue = "[REDACTED]"
+
+// MaskingRule masks an argument of a tool, see NewMaskingMiddleware
+type MaskingRule struct {
+	// Tool is the tool name, "*" applies the rule to every tool
+	Tool string
+
+	// Argument is the name of the top-level argument to mask
+	Argument string
+
+	Strategy MaskingStrategy
+
+	// Visible is the number of characters kept at each end by MaskingPartialMask
+	Visible int
+
+	// Result applies the rule to the Argument field of the result's StructuredContent
+	// instead of the arguments. Such fields are masked in the response sent to the client;
+	// middlewares added after NewMaskingMiddleware still see the original result.
+	Result bool
+}
+
+type ctxKeyMaskedArguments struct{}
+
+// MaskedArgumentsFromContext returns the tool call arguments masked by
+// NewMaskingMiddleware, or nil if no masking middleware ran
+func MaskedArgumentsFromContext(ctx context.Context) map[string]any {
+	args, _ := ctx.Value(ctxKeyMaskedArguments{}).(map[string]any)
+	return args
+}
+
+// loggedArguments returns the arguments of req as they may be logged
+func loggedArguments(ctx context.Context, req *CallToolRequest) map[string]any {
+	if args := MaskedArgumentsFromContext(ctx); args != nil {
+		return args
+	}
+	return req.Params.Arguments
+}
+
+// NewMaskingMiddleware masks sensitive tool arguments, such as emails or card numbers,
+// in the audit log and in the logging middlewares added after it, while the handler
+// still receives the original values. Rules with Result set mask StructuredContent
+// fields of the result instead.
+//
+// Calls rejected before the middleware chain runs, e.g. by the RBACPolicy or a quota,
+// are audited with their original arguments; use Server.UseMasking to mask those too.
+func NewMaskingMiddleware(rules []MaskingRule) Middleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+			var resultRules []MaskingRule
+			for _, rule := range rules {
+				if rule.Result && (rule.Tool == "*" || rule.Tool == req.Params.Name) {
+					resultRules = append(resultRules, rule)
+				}
+			}
+
+			if masked := maskToolArguments(req.Params.Name, req.Params.Arguments, rules); masked != nil {
+				ctx = context.WithValue(ctx, ctxKeyMaskedArguments{}, masked)
+				if rec := auditRecordFromContext(ctx); rec != nil {
+					rec.setArguments(masked)
+				}
+			}
+
+			result, err := next(ctx, req)
+			if result != nil && result.StructuredContent != nil && len(resultRules) > 0 {
+				if fields := structuredFields(result.StructuredContent); fields != nil {
+					if masked := maskFields(fields, resultRules); masked != nil {
+						copied := *result
+						copied.StructuredContent = masked
+						result = &copied
+					}
+				}
+			}
+			return result, err
+		}
+	}
+}
+
+// UseMasking adds NewMaskingMiddleware(rules) to the middleware chain and applies the
+// argument rules to every AuditEvent, including calls rejected before the chain runs
+func (s *Server) UseMasking(rules []MaskingRule) {
+	s.Use(NewMaskingMiddleware(rules))
+
+	s.mu.Lock()
+	s.maskingRules = append(s.maskingRules, rules...)
+	s.mu.Unlock()
+}
+
+// maskToolArguments returns the arguments of a call of tool with the argument rules applied,
+// or nil if no rule matched
+func maskToolArguments(tool string, args map[string]any, rules []MaskingRule) map[string]any {
+	var argRules []MaskingRule
+	for _, rule := range rules {
+		if !rule.Result && (rule.Tool == "*" || rule.Tool == tool) {
+			argRules = append(argRules, rule)
+		}
+	}
+	return maskFields(args, argRules)
+}
+
+// maskFields returns a copy of fields with the values matched by rules masked, or nil
+// if no rule matched
+func maskFields(fields map[string]any, rules []MaskingRule) map[string]any {
+	var out map[string]any
+	for _, rule := range rules {
+		v, ok := fields[rule.Argument]
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]any, len(fields))
+			for k, v := range fields {
+				out[k] = v
+			}
+		}
+		out[rule.Argument] = rule.mask(v)
+	}
+	return out
+}
+
+func (r MaskingRule) mask(v any) string {
+	text, ok := v.(string)
+	if !ok {
+		data, err := json.Marshal(v)
+		if err != nil {
+			text = fmt.Sprint(v)
+		} else {
+			text = string(data)
+		}
+	}
+
+	switch r.Strategy {
+	case MaskingHash:
+		sum := sha256.Sum256([]byte(text))
+		return hex.EncodeToString(sum[:])
+	case MaskingPartialMask:
+		runes := []rune(text)
+		if r.Visible <= 0 || len(runes) <= 2*r.Visible {
+			return strings.Repeat("*", len(runes))
+		}
+		hidden := len(runes) - 2*r.Visible
+		return string(runes[:r.Visible]) + strings.Repeat("*", hidden) + string(runes[len(runes)-r.Visible:])
+	default:
+		return redactedValue
+	}
+}
+
+// structuredFields returns the top-level fields of structured content, which handlers may
+// set to a map or any JSON-encodable struct
+func structuredFields(content any) map[string]any {
+	if fields, ok := content.(map[string]any); ok {
+		return fields
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
@@ -1,13 +1,21 @@
 package server
 
 import (
+	"container/list"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/voocel/mcp-sdk-go/protocol"
+	"golang.org/x/time/rate"
 )
 
 type Middleware func(ToolHandler) ToolHandler
@@ -43,7 +51,7 @@ func LoggingMiddleware(logger *slog.Logger) Middleware {
 
 			logger.Info("tool call started",
 				slog.String("tool", toolName),
-				slog.Any("arguments", req.Params.Arguments),
+				slog.Any("arguments", loggedArguments(ctx, req)),
 			)
 
 			result, err := next(ctx, req)
@@ -255,3 +263,411 @@ func ValidationMiddleware(validator ParamsValidator) Middleware {
 type ParamsValidator interface {
 	Validate(tool string, arguments map[string]any) error
 }
+
+// LogFormat is the output format used by NewLoggingMiddleware
+type LogFormat string
+
+const (
+	LogFormatText LogFormat = "text"
+	LogFormatJSON LogFormat = "json"
+)
+
+// LoggingOptions configures NewLoggingMiddleware
+type LoggingOptions struct {
+	// Format selects text or JSON log lines, defaults to LogFormatText
+	Format LogFormat
+
+	// MaskKeys lists argument keys whose values are replaced with "***" (e.g. PII or secrets).
+	// Keys are matched at any nesting level.
+	MaskKeys []string
+
+	// MaxArgBytes caps the JSON encoding of arguments and results, zero means no limit
+	MaxArgBytes int
+}
+
+const maskedValue = "***"
+
+// NewLoggingMiddleware returns a middleware that writes one structured log line per tool call
+// to w, including method, tool, session ID, arguments, result, error and latency.
+func NewLoggingMiddleware(w io.Writer, opts LoggingOptions) Middleware {
+	var handler slog.Handler
+	if opts.Format == LogFormatJSON {
+		handler = slog.NewJSONHandler(w, nil)
+	} else {
+		handler = slog.NewTextHandler(w, nil)
+	}
+	logger := slog.New(handler)
+
+	masked := make(map[string]bool, len(opts.MaskKeys))
+	for _, key := range opts.MaskKeys {
+		masked[key] = true
+	}
+
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, req)
+
+			sessionID := ""
+			if req.Session != nil {
+				sessionID = req.Session.ID()
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", protocol.MethodToolsCall),
+				slog.String("tool", req.Params.Name),
+				slog.String("session", sessionID),
+				slog.String("arguments", truncateJSON(maskArguments(loggedArguments(ctx, req), masked), opts.MaxArgBytes)),
+				slog.Duration("latency", time.Since(start)),
+			}
+			if result != nil {
+				attrs = append(attrs,
+					slog.Bool("isError", result.IsError),
+					slog.String("result", truncateJSON(result, opts.MaxArgBytes)),
+				)
+			}
+
+			level := slog.LevelInfo
+			if err != nil {
+				level = slog.LevelError
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+
+			logger.LogAttrs(ctx, level, "tool call", attrs...)
+			return result, err
+		}
+	}
+}
+
+// maskArguments returns a copy of args with masked keys replaced, leaving the request untouched
+func maskArguments(args map[string]any, masked map[string]bool) map[string]any {
+	if len(masked) == 0 || args == nil {
+		return args
+	}
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		if masked[k] {
+			out[k] = maskedValue
+			continue
+		}
+		out[k] = maskValue(v, masked)
+	}
+	return out
+}
+
+func maskValue(v any, masked map[string]bool) any {
+	switch val := v.(type) {
+	case map[string]any:
+		return maskArguments(val, masked)
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = maskValue(item, masked)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// truncateJSON encodes v as JSON and cuts it to at most limit bytes
+func truncateJSON(v any, limit int) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<unencodable: %v>", err)
+	}
+	if limit > 0 && len(data) > limit {
+		return string(data[:limit]) + "...(truncated)"
+	}
+	return string(data)
+}
+
+// DefaultRateLimitKeys is the number of clients of Server.HandleMessage tracked by
+// NewRateLimitMiddleware unless WithRateLimitMaxKeys is used
+const DefaultRateLimitKeys = 10000
+
+// RateLimitOption configures NewRateLimitMiddleware
+type RateLimitOption func(*sessionRateLimiter)
+
+// WithRateLimitClock sets the clock used to evaluate the limiters, mainly for testing
+func WithRateLimitClock(now func() time.Time) RateLimitOption {
+	return func(l *sessionRateLimiter) {
+		l.now = now
+	}
+}
+
+// WithRateLimitMaxKeys caps the number of clients of Server.HandleMessage tracked; the
+// least recently seen are evicted first. Connected sessions are not counted: their
+// buckets are kept until they disconnect.
+func WithRateLimitMaxKeys(n int) RateLimitOption {
+	return func(l *sessionRateLimiter) {
+		l.maxKeys = n
+	}
+}
+
+type sessionRateLimiter struct {
+	limit   rate.Limit
+	burst   int
+	now     func() time.Time
+	maxKeys int
+
+	mu       sync.Mutex
+	sessions map[*ServerSession]*rate.Limiter // connected sessions, removed on disconnect
+	limiters map[any]*list.Element            // rateLimitClient -> element holding a *keyedLimiter
+	lru      *list.List                       // most recently seen first
+}
+
+type keyedLimiter struct {
+	key     any
+	limiter *rate.Limiter
+}
+
+// rateLimitClient identifies a client across the sessions of Server.HandleMessage
+type rateLimitClient struct {
+	kind, value string
+}
+
+// rateLimitKey returns the bucket key of ss: the session itself while connected, and
+// its client ID or IP for the sessions of Server.HandleMessage, which last a single message
+func rateLimitKey(ss *ServerSession) any {
+	switch {
+	case ss == nil:
+		return rateLimitClient{}
+	case !ss.transient:
+		return ss
+	case ss.clientID != "":
+		return rateLimitClient{kind: "id", value: ss.clientID}
+	default:
+		return rateLimitClient{kind: "ip", value: ss.clientIP}
+	}
+}
+
+func (l *sessionRateLimiter) allow(ss *ServerSession) bool {
+	key := rateLimitKey(ss)
+	if connected, isSession := key.(*ServerSession); isSession {
+		return l.sessionLimiter(connected).AllowN(l.now(), 1)
+	}
+
+	l.mu.Lock()
+	elem, ok := l.limiters[key]
+	if ok {
+		l.lru.MoveToFront(elem)
+	} else {
+		elem = l.lru.PushFront(&keyedLimiter{key: key, limiter: rate.NewLimiter(l.limit, l.burst)})
+		l.limiters[key] = elem
+		for l.lru.Len() > l.maxKeys {
+			oldest := l.lru.Back()
+			l.lru.Remove(oldest)
+			delete(l.limiters, oldest.Value.(*keyedLimiter).key)
+		}
+	}
+	limiter := elem.Value.(*keyedLimiter).limiter
+	l.mu.Unlock()
+
+	return limiter.AllowN(l.now(), 1)
+}
+
+// sessionLimiter returns the bucket of a connected session. It is created, and its
+// removal on disconnect registered, on the session's first call.
+func (l *sessionRateLimiter) sessionLimiter(ss *ServerSession) *rate.Limiter {
+	l.mu.Lock()
+	limiter, ok := l.sessions[ss]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.sessions[ss] = limiter
+	}
+	l.mu.Unlock()
+
+	if !ok && !ss.onDisconnect(func() { l.forget(ss) }) {
+		// The session disconnected before the hook could be registered
+		l.forget(ss)
+	}
+	return limiter
+}
+
+func (l *sessionRateLimiter) forget(ss *ServerSession) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.sessions, ss)
+}
+
+// NewRateLimitMiddleware limits tool calls per ServerSession using a token bucket.
+// Calls handled with Server.HandleMessage, as by Streamable HTTP, share the bucket of
+// their client ID, or else of their client IP. Rejected calls return a tool error
+// result instead of a protocol error.
+func NewRateLimitMiddleware(limit rate.Limit, burst int, opts ...RateLimitOption) Middleware {
+	l := &sessionRateLimiter{
+		limit:    limit,
+		burst:    burst,
+		now:      time.Now,
+		maxKeys:  DefaultRateLimitKeys,
+		sessions: make(map[*ServerSession]*rate.Limiter),
+		limiters: make(map[any]*list.Element),
+		lru:      list.New(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.maxKeys <= 0 {
+		l.maxKeys = DefaultRateLimitKeys
+	}
+
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+			if !l.allow(req.Session) {
+				return protocol.NewToolResultError("rate limit exceeded"), nil
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// NewSchemaValidationMiddleware validates tool arguments against the registered tool's InputSchema
+// before calling the handler. Violations are returned as a tool error result listing every failed constraint.
+func NewSchemaValidationMiddleware() Middleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+			if req.Session == nil || req.Session.server == nil {
+				return next(ctx, req)
+			}
+
+			s := req.Session.server
+			s.mu.Lock()
+			st, ok := s.tools[req.Params.Name]
+			s.mu.Unlock()
+			if !ok || st.tool.InputSchema == nil {
+				return next(ctx, req)
+			}
+
+			args := req.Params.Arguments
+			if args == nil {
+				args = map[string]any{}
+			}
+
+			if err := validateAgainstSchema(st.tool.InputSchema, args); err != nil {
+				return invalidArgumentsResult(req.Params.Name, schemaViolations(err)), nil
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
+
+// NewCoercionMiddleware converts string arguments to the type declared for them in the tool's
+// InputSchema, since LLM-driven clients often send e.g. "10" instead of 10.
+// Strings are parsed into float64 for "number" and "integer" properties (the type encoding/json
+// produces for numbers, with integers required to be whole) and into bool for "boolean" properties
+// given "true" or "false". Values that do not parse are passed through unchanged.
+// The handler receives a coerced copy of the arguments; the original request is not modified.
+func NewCoercionMiddleware() Middleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+			if req.Session == nil || req.Session.server == nil || len(req.Params.Arguments) == 0 {
+				return next(ctx, req)
+			}
+
+			s := req.Session.server
+			s.mu.Lock()
+			st, ok := s.tools[req.Params.Name]
+			s.mu.Unlock()
+			if !ok {
+				return next(ctx, req)
+			}
+			properties := schemaObject(st.tool.InputSchema["properties"])
+			if len(properties) == 0 {
+				return next(ctx, req)
+			}
+
+			var args map[string]any
+			for name, value := range req.Params.Arguments {
+				str, isString := value.(string)
+				if !isString {
+					continue
+				}
+				coerced, ok := coerceString(str, schemaTypes(schemaObject(properties[name])))
+				if !ok {
+					continue
+				}
+				if args == nil {
+					args = make(map[string]any, len(req.Params.Arguments))
+					for k, v := range req.Params.Arguments {
+						args[k] = v
+					}
+				}
+				args[name] = coerced
+			}
+			if args == nil {
+				return next(ctx, req)
+			}
+
+			params := *req.Params
+			params.Arguments = args
+			coercedReq := *req
+			coercedReq.Params = &params
+			return next(ctx, &coercedReq)
+		}
+	}
+}
+
+// schemaObject returns v as a map if it is a schema object
+func schemaObject(v any) map[string]any {
+	switch m := v.(type) {
+	case map[string]any:
+		return m
+	case protocol.JSONSchema:
+		return m
+	}
+	return nil
+}
+
+// schemaTypes returns the JSON Schema types of a property, which may be a single type or a list
+func schemaTypes(property map[string]any) []string {
+	switch t := property["type"].(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// coerceString parses s as the first of types it is valid for; strings are left alone
+// when the property also accepts "string"
+func coerceString(s string, types []string) (any, bool) {
+	for _, t := range types {
+		if t == "string" {
+			return nil, false
+		}
+	}
+
+	s = strings.TrimSpace(s)
+	for _, t := range types {
+		switch t {
+		case "number":
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f, true
+			}
+		case "integer":
+			if f, err := strconv.ParseFloat(s, 64); err == nil && f == math.Trunc(f) {
+				return f, true
+			}
+		case "boolean":
+			switch {
+			case strings.EqualFold(s, "true"):
+				return true, true
+			case strings.EqualFold(s, "false"):
+				return false, true
+			}
+		}
+	}
+	return nil, false
+}
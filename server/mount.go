@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// ErrCircularMount is returned by Server.Mount when sub already mounts the server
+var ErrCircularMount = errors.New("circular mount")
+
+// Mount registers the tools, resources, resource templates and prompts of sub on s, so
+// that registrations can be split across packages. Tool and prompt names are prefixed
+// with prefix + "/", resource URIs and URI templates with prefix + "://". Requests reach
+// the sub handlers with their original names and URIs, still wrapped in the middleware
+// and resource middleware of sub; those of s apply on top.
+//
+// Mount copies what sub has registered at the time of the call. It fails if sub is s or
+// mounts s, directly or not, and with ServerOptions.StrictToolRegistration if a prefixed
+// tool name is already taken.
+func (s *Server) Mount(prefix string, sub *Server) error {
+	if prefix == "" {
+		return fmt.Errorf("mount: empty prefix")
+	}
+	if sub == s || sub.mounts(s) {
+		return fmt.Errorf("%w: %q", ErrCircularMount, prefix)
+	}
+
+	sub.mu.Lock()
+	tools := make([]*serverTool, 0, len(sub.tools))
+	for _, st := range sub.tools {
+		tools = append(tools, st)
+	}
+	resources := make([]*serverResource, 0, len(sub.resources))
+	for _, sr := range sub.resources {
+		resources = append(resources, sr)
+	}
+	templates := make([]*serverResourceTemplate, 0, len(sub.resourceTemplates))
+	for _, st := range sub.resourceTemplates {
+		templates = append(templates, st)
+	}
+	prompts := make([]*serverPrompt, 0, len(sub.prompts))
+	for _, sp := range sub.prompts {
+		prompts = append(prompts, sp)
+	}
+	resourceMiddlewares := sub.resourceMiddlewares
+	sub.mu.Unlock()
+
+	namePrefix, uriPrefix := prefix+"/", prefix+"://"
+	for _, st := range tools {
+		tool := *st.tool
+		tool.Name = namePrefix + tool.Name
+		// The sub handler already runs the validators
+		opts := st.opts
+		opts.Validators = nil
+		if _, err := s.putTool(&tool, mountedToolHandler(st.tool.Name, st.handler), []*ToolOptions{&opts}, !s.opts.StrictToolRegistration); err != nil {
+			return fmt.Errorf("mount %q: %w", prefix, err)
+		}
+	}
+	for _, sr := range resources {
+		resource := *sr.resource
+		resource.URI = uriPrefix + resource.URI
+		s.AddResource(&resource, mountedResourceHandler(uriPrefix, applyResourceMiddleware(sr.handler, resourceMiddlewares)))
+	}
+	for _, st := range templates {
+		template := *st.template
+		template.URITemplate = uriPrefix + template.URITemplate
+		s.AddResourceTemplate(&template, mountedResourceHandler(uriPrefix, applyResourceMiddleware(st.handler, resourceMiddlewares)))
+	}
+	for _, sp := range prompts {
+		prompt := *sp.prompt
+		prompt.Name = namePrefix + prompt.Name
+		s.AddPrompt(&prompt, mountedPromptHandler(sp.prompt.Name, sp.handler))
+	}
+
+	s.mu.Lock()
+	s.mounted = append(s.mounted, sub)
+	s.mu.Unlock()
+	return nil
+}
+
+// mounts reports whether target is mounted on s, directly or through other mounts
+func (s *Server) mounts(target *Server) bool {
+	s.mu.Lock()
+	mounted := s.mounted
+	s.mu.Unlock()
+
+	for _, m := range mounted {
+		if m == target || m.mounts(target) {
+			return true
+		}
+	}
+	return false
+}
+
+func mountedToolHandler(name string, h ToolHandler) ToolHandler {
+	return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+		params := *req.Params
+		params.Name = name
+		return h(ctx, &CallToolRequest{Session: req.Session, Params: &params})
+	}
+}
+
+func mountedResourceHandler(uriPrefix string, h ResourceHandler) ResourceHandler {
+	return func(ctx context.Context, req *ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+		params := *req.Params
+		params.URI = strings.TrimPrefix(params.URI, uriPrefix)
+		result, err := h(ctx, &ReadResourceRequest{Session: req.Session, Params: &params})
+		if err != nil || result == nil {
+			return result, err
+		}
+		for i := range result.Contents {
+			result.Contents[i].URI = uriPrefix + result.Contents[i].URI
+		}
+		return result, nil
+	}
+}
+
+func mountedPromptHandler(name string, h PromptHandler) PromptHandler {
+	return func(ctx context.Context, req *GetPromptRequest) (*protocol.GetPromptResult, error) {
+		params := *req.Params
+		params.Name = name
+		return h(ctx, &GetPromptRequest{Session: req.Session, Params: &params})
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// AddNegotiatedResource adds a resource available in several formats. handlers maps each
+// MIME type to the handler producing that format.
+//
+// resources/read picks the handler that best matches ReadResourceParams.Accept using
+// RFC 7231 quality values, and sets the chosen type as the MimeType of the returned
+// contents. Without an Accept value, the resource's declared MimeType is served if it has
+// a handler, otherwise the alphabetically first type.
+func (s *Server) AddNegotiatedResource(r *protocol.Resource, handlers map[string]ResourceHandler) {
+	types := make([]string, 0, len(handlers))
+	byType := make(map[string]ResourceHandler, len(handlers))
+	for mimeType, h := range handlers {
+		mimeType = strings.ToLower(mimeType)
+		types = append(types, mimeType)
+		byType[mimeType] = h
+	}
+	slices.Sort(types)
+	// The declared type is the default representation
+	if i := slices.Index(types, strings.ToLower(r.MimeType)); i > 0 {
+		preferred := types[i]
+		copy(types[1:i+1], types[:i])
+		types[0] = preferred
+	}
+
+	s.AddResource(r, func(ctx context.Context, req *ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+		mimeType := negotiateMimeType(req.Params.Accept, types)
+		if mimeType == "" {
+			return nil, protocol.NewMCPError(protocol.InvalidParams, "No acceptable representation", map[string]any{
+				"uri":       req.Params.URI,
+				"accept":    req.Params.Accept,
+				"available": types,
+			})
+		}
+
+		result, err := byType[mimeType](ctx, req)
+		if err != nil || result == nil {
+			return result, err
+		}
+		for i := range result.Contents {
+			result.Contents[i].MimeType = mimeType
+		}
+		return result, nil
+	})
+}
+
+// acceptRange is one media range of an Accept value
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// negotiateMimeType returns the entry of available (in preference order) best matching
+// accept, or "" if none is acceptable. An empty accept selects the first entry.
+func negotiateMimeType(accept string, available []string) string {
+	if len(available) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return available[0]
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, mimeType := range available {
+		typ, subtype, _ := strings.Cut(mimeType, "/")
+		if q := acceptQuality(ranges, typ, subtype); q > bestQ {
+			best, bestQ = mimeType, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the q of the most specific range matching typ/subtype
+func acceptQuality(ranges []acceptRange, typ, subtype string) float64 {
+	specificity, q := -1, 0.0
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			specificity, q = s, r.q
+		}
+	}
+	return q
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				continue
+			}
+			q = parsed
+		}
+		ranges = append(ranges, acceptRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
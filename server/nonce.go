@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// NonceHeader carries a unique value per request, see NewNonceMiddleware
+	NonceHeader = "X-MCP-Nonce"
+	// TimestampHeader carries the request time in Unix seconds, see NewNonceMiddleware
+	TimestampHeader = "X-MCP-Timestamp"
+)
+
+// nonceSweepMin is the number of stored nonces below which InMemoryNonceStore skips cleanup
+const nonceSweepMin = 1024
+
+// NonceStore records the nonces of accepted requests. Implementations backed by a
+// shared store such as Redis detect replays across server instances.
+type NonceStore interface {
+	// Seen reports whether nonce was already recorded and has not expired, and otherwise
+	// records it until expiry
+	Seen(nonce string, expiry time.Time) (bool, error)
+}
+
+// NewNonceMiddleware rejects replayed HTTP requests with 401 Unauthorized. Requests must
+// carry a unique X-MCP-Nonce header and an X-MCP-Timestamp header within window/2 of the
+// server time; nonces are remembered for window, so a replay is either detected by store
+// or rejected for its stale timestamp.
+func NewNonceMiddleware(store NonceStore, window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonce := r.Header.Get(NonceHeader)
+			if nonce == "" {
+				http.Error(w, "Missing nonce", http.StatusUnauthorized)
+				return
+			}
+			seconds, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid timestamp", http.StatusUnauthorized)
+				return
+			}
+
+			now := time.Now()
+			skew := now.Sub(time.Unix(seconds, 0))
+			if skew < 0 {
+				skew = -skew
+			}
+			if skew > window/2 {
+				http.Error(w, "Stale timestamp", http.StatusUnauthorized)
+				return
+			}
+
+			seen, err := store.Seen(nonce, now.Add(window))
+			if err != nil {
+				http.Error(w, "Nonce check failed", http.StatusInternalServerError)
+				return
+			}
+			if seen {
+				http.Error(w, "Replayed nonce", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// InMemoryNonceStore is an in-process NonceStore. Expired nonces are swept whenever the
+// store doubles in size, keeping it bounded by the nonces seen within a window.
+type InMemoryNonceStore struct {
+	mu      sync.Mutex
+	nonces  map[string]time.Time
+	sweepAt int
+}
+
+// NewInMemoryNonceStore creates an empty in-memory store
+func NewInMemoryNonceStore() *InMemoryNonceStore {
+	return &InMemoryNonceStore{nonces: make(map[string]time.Time), sweepAt: nonceSweepMin}
+}
+
+func (m *InMemoryNonceStore) Seen(nonce string, expiry time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if expires, ok := m.nonces[nonce]; ok && now.Before(expires) {
+		return true, nil
+	}
+
+	if len(m.nonces) >= m.sweepAt {
+		for n, expires := range m.nonces {
+			if !now.Before(expires) {
+				delete(m.nonces, n)
+			}
+		}
+		m.sweepAt = max(2*len(m.nonces), nonceSweepMin)
+	}
+	m.nonces[nonce] = expiry
+	return false, nil
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// OpenAPIVersion is the OpenAPI version of the documents built by ExportOpenAPI
+const OpenAPIVersion = "3.1.0"
+
+// toolResultSchema describes a CallToolResult, for tools without an output schema
+var toolResultSchema = protocol.JSONSchema{
+	"type": "object",
+	"properties": map[string]any{
+		"content":           map[string]any{"type": "array", "items": map[string]any{"type": "object"}},
+		"structuredContent": map[string]any{"type": "object"},
+		"isError":           map[string]any{"type": "boolean"},
+	},
+	"required": []any{"content"},
+}
+
+// promptResultSchema describes a GetPromptResult
+var promptResultSchema = protocol.JSONSchema{
+	"type": "object",
+	"properties": map[string]any{
+		"description": map[string]any{"type": "string"},
+		"messages":    map[string]any{"type": "array", "items": map[string]any{"type": "object"}},
+	},
+	"required": []any{"messages"},
+}
+
+// ExportOpenAPI describes the tools, resources and prompts registered on s as an OpenAPI
+// 3.1 document, for use with REST tooling:
+//   - each tool becomes POST /tools/{name}, taking its InputSchema and returning its
+//     OutputSchema, or a CallToolResult without one
+//   - each resource becomes GET /resources/{uri}, with the URI path-escaped
+//   - each prompt becomes POST /prompts/{name}, taking its arguments as an object
+//
+// Operation IDs are the item name prefixed with its kind, e.g. "tool_search". Resources
+// sharing a name get a numeric suffix, in URI order. Disabled tools and resources, and
+// resource templates, are not included.
+func ExportOpenAPI(s *Server) (*openapi3.T, error) {
+	doc := &openapi3.T{
+		OpenAPI: OpenAPIVersion,
+		Info: &openapi3.Info{
+			Title:       s.impl.Name,
+			Version:     s.impl.Version,
+			Description: s.opts.Instructions,
+		},
+		Paths: openapi3.NewPaths(),
+	}
+	operationIDs := make(map[string]bool)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range slices.Sorted(maps.Keys(s.tools)) {
+		st := s.tools[name]
+		if st.disabled {
+			continue
+		}
+		input, err := openAPISchema(st.tool.InputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("tool %q input schema: %w", name, err)
+		}
+		outputSchema := toolResultSchema
+		if st.tool.OutputSchema != nil {
+			outputSchema = st.tool.OutputSchema
+		}
+		output, err := openAPISchema(outputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("tool %q output schema: %w", name, err)
+		}
+		doc.Paths.Set("/tools/"+url.PathEscape(name), &openapi3.PathItem{Post: &openapi3.Operation{
+			OperationID: uniqueOperationID(operationIDs, "tool_"+name),
+			Summary:     st.tool.Title,
+			Description: st.tool.Description,
+			Tags:        []string{"tools"},
+			RequestBody: &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchema(input)},
+			Responses: openapi3.NewResponses(
+				openapi3.WithName("200", openapi3.NewResponse().WithDescription("Tool result").WithJSONSchema(output)),
+			),
+		}})
+	}
+
+	for _, uri := range slices.Sorted(maps.Keys(s.resources)) {
+		sr := s.resources[uri]
+		if sr.disabled {
+			continue
+		}
+		mimeType := sr.resource.MimeType
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		contents := openapi3.NewResponse().WithDescription("Resource contents").
+			WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{mimeType}))
+		doc.Paths.Set("/resources/"+url.PathEscape(uri), &openapi3.PathItem{Get: &openapi3.Operation{
+			OperationID: uniqueOperationID(operationIDs, "resource_"+sr.resource.Name),
+			Summary:     sr.resource.Title,
+			Description: sr.resource.Description,
+			Tags:        []string{"resources"},
+			Responses: openapi3.NewResponses(
+				openapi3.WithName("200", contents),
+				openapi3.WithName("404", openapi3.NewResponse().WithDescription("Resource not found")),
+			),
+		}})
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(s.prompts)) {
+		sp := s.prompts[name]
+		arguments := openapi3.NewObjectSchema()
+		for _, arg := range sp.prompt.Arguments {
+			prop := openapi3.NewStringSchema()
+			prop.Description = arg.Description
+			if arg.Default != "" {
+				prop.Default = arg.Default
+			}
+			arguments.WithProperty(arg.Name, prop)
+			if arg.Required {
+				arguments.Required = append(arguments.Required, arg.Name)
+			}
+		}
+		messages, err := openAPISchema(promptResultSchema)
+		if err != nil {
+			return nil, fmt.Errorf("prompt %q result schema: %w", name, err)
+		}
+		doc.Paths.Set("/prompts/"+url.PathEscape(name), &openapi3.PathItem{Post: &openapi3.Operation{
+			OperationID: uniqueOperationID(operationIDs, "prompt_"+name),
+			Summary:     sp.prompt.Title,
+			Description: sp.prompt.Description,
+			Tags:        []string{"prompts"},
+			RequestBody: &openapi3.RequestBodyRef{
+				Value: openapi3.NewRequestBody().WithRequired(len(arguments.Required) > 0).WithJSONSchema(arguments),
+			},
+			Responses: openapi3.NewResponses(
+				openapi3.WithName("200", openapi3.NewResponse().WithDescription("Prompt messages").WithJSONSchema(messages)),
+			),
+		}})
+	}
+
+	return doc, nil
+}
+
+// uniqueOperationID returns id, with a numeric suffix if it is already used, and
+// adds the result to used
+func uniqueOperationID(used map[string]bool, id string) string {
+	unique := id
+	for n := 2; used[unique]; n++ {
+		unique = id + "_" + strconv.Itoa(n)
+	}
+	used[unique] = true
+	return unique
+}
+
+// openAPISchema converts schema through its JSON form, as OpenAPI 3.1 schemas are JSON
+// Schema. Keywords unknown to openapi3.Schema are kept as extensions.
+func openAPISchema(schema protocol.JSONSchema) (*openapi3.Schema, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	converted := openapi3.NewSchema()
+	if err := json.Unmarshal(data, converted); err != nil {
+		return nil, err
+	}
+	return converted, nil
+}
+
+// OpenAPIHandler serves the document built by ExportOpenAPI as JSON, e.g. for Swagger UI.
+// The document is rebuilt on every request so that it follows registry changes.
+// Cross-origin requests are subject to ServerOptions.OpenAPICORS.
+func (s *Server) OpenAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cors := s.opts.OpenAPICORS; cors != nil && cors.ServeCORS(w, r, http.MethodGet, http.MethodHead) {
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		doc, err := ExportOpenAPI(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("encode OpenAPI document: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if _, err := w.Write(data); err != nil {
+			slog.Debug("OpenAPI document not sent", "path", r.URL.Path, "error", err)
+		}
+	})
+}
+
+// ServeOpenAPI registers OpenAPIHandler at path on http.DefaultServeMux. Servers using
+// their own mux register OpenAPIHandler on it instead.
+func (s *Server) ServeOpenAPI(path string) {
+	http.Handle(path, s.OpenAPIHandler())
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/base64"
+	"sort"
+)
+
+// encodeCursor encodes the last-seen key as an opaque pagination cursor
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// decodeCursor decodes an opaque pagination cursor back into the last-seen key
+func decodeCursor(cursor string) (string, bool) {
+	if cursor == "" {
+		return "", true
+	}
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", false
+	}
+	return string(key), true
+}
+
+// paginate sorts items by key and returns the page that follows cursor.
+// A non-positive pageSize disables pagination and returns every item.
+// The returned next cursor is nil when there are no more pages.
+//
+// Since the cursor carries the last-seen key rather than an offset, pages stay
+// consistent when items are added or removed between requests.
+func paginate[T any](items []T, key func(T) string, cursor string, pageSize int) ([]T, *string, bool) {
+	after, ok := decodeCursor(cursor)
+	if !ok {
+		return nil, nil, false
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return key(items[i]) < key(items[j])
+	})
+
+	start := 0
+	if cursor != "" {
+		start = sort.Search(len(items), func(i int) bool {
+			return key(items[i]) > after
+		})
+	}
+	items = items[start:]
+
+	if pageSize <= 0 || len(items) <= pageSize {
+		return items, nil, true
+	}
+
+	page := items[:pageSize]
+	next := encodeCursor(key(page[len(page)-1]))
+	return page, &next, true
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// ParamValidator checks a single tool argument, e.g. that a string is a valid
+// ISO 3166-1 country code. It returns an error describing the failure.
+type ParamValidator func(value interface{}) error
+
+// WithValidator registers fn to validate the argument name. Multiple validators may be
+// registered for the same argument; all of them are evaluated.
+//
+// Validators run after every middleware, so they see arguments already checked by
+// NewSchemaValidationMiddleware and converted by NewCoercionMiddleware. Arguments absent
+// from the call are skipped. Failures are combined into a single tool error result.
+func (o *ToolOptions) WithValidator(name string, fn ParamValidator) *ToolOptions {
+	if o.Validators == nil {
+		o.Validators = make(map[string][]ParamValidator)
+	}
+	o.Validators[name] = append(o.Validators[name], fn)
+	return o
+}
+
+// validateParams wraps next with the tool's parameter validators
+func validateParams(toolName string, validators map[string][]ParamValidator, next ToolHandler) ToolHandler {
+	return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+		if failures := paramFailures(validators, req.Params.Arguments); len(failures) > 0 {
+			return invalidArgumentsResult(toolName, failures), nil
+		}
+		return next(ctx, req)
+	}
+}
+
+// paramFailures runs validators over args, in argument name order
+func paramFailures(validators map[string][]ParamValidator, args map[string]any) []string {
+	names := make([]string, 0, len(validators))
+	for name := range validators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var failures []string
+	for _, name := range names {
+		value, ok := args[name]
+		if !ok {
+			continue
+		}
+		for _, fn := range validators[name] {
+			if err := fn(value); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+	}
+	return failures
+}
+
+func invalidArgumentsResult(toolName string, failures []string) *protocol.CallToolResult {
+	return protocol.NewToolResultError(fmt.Sprintf("invalid arguments for tool %s:\n- %s",
+		toolName, strings.Join(failures, "\n- ")))
+}
+
+// dryRunTool checks a tools/call request with DryRun set without invoking the handler:
+// the arguments are validated against the tool's input schema and parameter validators
+func dryRunTool(st *serverTool, args map[string]any) *protocol.CallToolResult {
+	if args == nil {
+		args = map[string]any{}
+	}
+	if st.tool.InputSchema != nil {
+		if err := validateAgainstSchema(st.tool.InputSchema, args); err != nil {
+			return invalidArgumentsResult(st.tool.Name, schemaViolations(err))
+		}
+	}
+	if failures := paramFailures(st.opts.Validators, args); len(failures) > 0 {
+		return invalidArgumentsResult(st.tool.Name, failures)
+	}
+	return protocol.NewToolResultText("dry run: arguments are valid")
+}
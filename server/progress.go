@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// ProgressReporter sends notifications/progress for the request that created it
+type ProgressReporter interface {
+	// Report sends the current progress. total is 0 when unknown.
+	Report(done, total float64, message string) error
+}
+
+type ctxKeyProgressReporter struct{}
+
+func contextWithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, ctxKeyProgressReporter{}, reporter)
+}
+
+// ProgressReporterFromContext returns the reporter for the current tool call.
+// If the client did not request progress, the returned reporter discards reports.
+func ProgressReporterFromContext(ctx context.Context) ProgressReporter {
+	if ctx != nil {
+		if reporter, ok := ctx.Value(ctxKeyProgressReporter{}).(ProgressReporter); ok {
+			return reporter
+		}
+	}
+	return noopProgressReporter{}
+}
+
+// ProgressToken returns the progress token sent by the client in _meta, or "" if none
+func (r *CallToolRequest) ProgressToken() string {
+	token := r.progressToken()
+	switch v := token.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (r *CallToolRequest) progressToken() any {
+	if r == nil || r.Params == nil || r.Params.Meta == nil {
+		return nil
+	}
+	return r.Params.Meta["progressToken"]
+}
+
+// NewProgressReporter returns a reporter bound to this request's session and progress token
+func (r *CallToolRequest) NewProgressReporter() ProgressReporter {
+	return r.newProgressReporter(context.Background())
+}
+
+// newProgressReporter keeps the values of ctx (such as the task ID) but not its cancellation
+func (r *CallToolRequest) newProgressReporter(ctx context.Context) ProgressReporter {
+	token := r.progressToken()
+	if token == nil || r.Session == nil || r.Session.conn == nil {
+		return noopProgressReporter{}
+	}
+	return &sessionProgressReporter{ctx: context.WithoutCancel(ctx), session: r.Session, token: token}
+}
+
+type sessionProgressReporter struct {
+	ctx     context.Context
+	session *ServerSession
+	token   any // preserved as sent by the client (string or number)
+}
+
+func (p *sessionProgressReporter) Report(done, total float64, message string) error {
+	return p.session.NotifyProgress(p.ctx, &protocol.ProgressNotificationParams{
+		ProgressToken: p.token,
+		Progress:      done,
+		Total:         total,
+		Message:       message,
+	})
+}
+
+type noopProgressReporter struct{}
+
+func (noopProgressReporter) Report(done, total float64, message string) error { return nil }
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+const (
+	metricStatusSuccess = "success"
+	metricStatusError   = "error"
+)
+
+type prometheusMetrics struct {
+	toolCalls     *prometheus.CounterVec
+	toolDuration  *prometheus.HistogramVec
+	resourceReads *prometheus.CounterVec
+}
+
+func newToolMetrics(reg prometheus.Registerer) *prometheusMetrics {
+	m := &prometheusMetrics{
+		toolCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mcp_tool_calls_total",
+			Help: "Total number of MCP tool calls, partitioned by tool name and status.",
+		}, []string{"name", "status"}),
+		toolDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mcp_tool_call_duration_seconds",
+			Help:    "Duration of MCP tool calls in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"name"}),
+	}
+	reg.MustRegister(m.toolCalls, m.toolDuration)
+	return m
+}
+
+func (m *prometheusMetrics) registerResourceMetrics(reg prometheus.Registerer) {
+	m.resourceReads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_resource_reads_total",
+		Help: "Total number of MCP resource reads, partitioned by URI and status.",
+	}, []string{"uri", "status"})
+	reg.MustRegister(m.resourceReads)
+}
+
+func (m *prometheusMetrics) middleware() Middleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, req)
+
+			status := metricStatusSuccess
+			if err != nil || (result != nil && result.IsError) {
+				status = metricStatusError
+			}
+			m.toolCalls.WithLabelValues(req.Params.Name, status).Inc()
+			m.toolDuration.WithLabelValues(req.Params.Name).Observe(time.Since(start).Seconds())
+
+			return result, err
+		}
+	}
+}
+
+func (m *prometheusMetrics) observeResourceRead(uri string, err error) {
+	status := metricStatusSuccess
+	if err != nil {
+		status = metricStatusError
+	}
+	m.resourceReads.WithLabelValues(uri, status).Inc()
+}
+
+// NewPrometheusMiddleware registers tool call metrics with reg and returns a middleware recording them:
+// mcp_tool_calls_total{name,status} and mcp_tool_call_duration_seconds{name}.
+// Use Server.UsePrometheus to also record resource reads.
+func NewPrometheusMiddleware(reg prometheus.Registerer) Middleware {
+	return newToolMetrics(reg).middleware()
+}
+
+// sessionCollector reports the message counters of the connected sessions at scrape time,
+// so that closed sessions drop out of the metrics
+type sessionCollector struct {
+	s        *Server
+	bytes    *prometheus.Desc
+	messages *prometheus.Desc
+}
+
+func newSessionCollector(s *Server) *sessionCollector {
+	return &sessionCollector{
+		s: s,
+		bytes: prometheus.NewDesc("mcp_session_bytes_total",
+			"Total size of the JSON-RPC messages of MCP sessions, by direction (read or written).",
+			[]string{"direction", "session_id"}, nil),
+		messages: prometheus.NewDesc("mcp_session_messages_total",
+			"Total number of JSON-RPC messages of MCP sessions, by direction (read or written).",
+			[]string{"direction", "session_id"}, nil),
+	}
+}
+
+func (c *sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.bytes
+	ch <- c.messages
+}
+
+func (c *sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	c.s.mu.Lock()
+	sessions := slices.Clone(c.s.sessions)
+	c.s.mu.Unlock()
+
+	for _, ss := range sessions {
+		id := ss.ID()
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, float64(ss.BytesRead()), "read", id)
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, float64(ss.BytesWritten()), "written", id)
+		ch <- prometheus.MustNewConstMetric(c.messages, prometheus.CounterValue, float64(ss.MessagesRead()), "read", id)
+		ch <- prometheus.MustNewConstMetric(c.messages, prometheus.CounterValue, float64(ss.MessagesWritten()), "written", id)
+	}
+}
+
+// UsePrometheus registers tool and resource metrics with reg and wires them into the server,
+// additionally recording mcp_resource_reads_total{uri,status}, where uri is the registered
+// URI or URI template of the resource read, and the traffic of each connected session as
+// mcp_session_bytes_total{direction,session_id} and mcp_session_messages_total{direction,session_id}
+func (s *Server) UsePrometheus(reg prometheus.Registerer) {
+	m := newToolMetrics(reg)
+	m.registerResourceMetrics(reg)
+	reg.MustRegister(newSessionCollector(s))
+
+	s.Use(m.middleware())
+
+	s.mu.Lock()
+	s.resourceReadObserver = m.observeResourceRead
+	s.mu.Unlock()
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/voocel/mcp-sdk-go/client"
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// RemoteServer is a backend MCP server federated by a Proxy
+type RemoteServer struct {
+	// Namespace is prefixed to the backend's tool names, prompt names and resource URIs, e.g. "fs:"
+	Namespace string
+
+	// Session is an initialized client session connected to the backend
+	Session *client.ClientSession
+}
+
+// Proxy exposes the tools, resources and prompts of several backend servers through a single Server.
+// Each backend's entries are registered under its namespace; calls are routed to the owning backend
+// with the namespace stripped.
+//
+// Backend catalogs are fetched by Refresh. Call it after construction, and again whenever a backend
+// reports a list change (e.g. from client.ClientOptions.ToolListChangedHandler).
+// If a backend goes offline its entries stay listed, but calls to them fail with an error
+// while the other backends keep serving.
+type Proxy struct {
+	server   *Server
+	backends []*RemoteServer
+
+	mu         sync.Mutex
+	registered map[*RemoteServer]*proxyEntries
+}
+
+// proxyEntries tracks the namespaced names registered for one backend
+type proxyEntries struct {
+	tools     map[string]bool
+	resources map[string]bool
+	prompts   map[string]bool
+}
+
+// NewProxy creates a proxy that registers the backends' entries on s
+func NewProxy(s *Server, backends ...*RemoteServer) *Proxy {
+	return &Proxy{
+		server:     s,
+		backends:   backends,
+		registered: make(map[*RemoteServer]*proxyEntries),
+	}
+}
+
+// Refresh queries every backend and synchronizes the entries registered on the server.
+// Backends that fail to answer keep their previously registered entries; their errors are joined
+// into the returned error.
+func (p *Proxy) Refresh(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for _, b := range p.backends {
+		if err := p.refreshBackend(ctx, b); err != nil {
+			errs = append(errs, fmt.Errorf("backend %q: %w", b.Namespace, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Proxy) refreshBackend(ctx context.Context, b *RemoteServer) error {
+	var caps protocol.ServerCapabilities
+	if init := b.Session.InitializeResult(); init != nil {
+		caps = init.Capabilities
+	}
+
+	next := &proxyEntries{
+		tools:     make(map[string]bool),
+		resources: make(map[string]bool),
+		prompts:   make(map[string]bool),
+	}
+
+	var tools []protocol.Tool
+	if caps.Tools != nil {
+		var err error
+		if tools, err = listAllTools(ctx, b.Session); err != nil {
+			return err
+		}
+	}
+	var resources []protocol.Resource
+	if caps.Resources != nil {
+		var err error
+		if resources, err = listAllResources(ctx, b.Session); err != nil {
+			return err
+		}
+	}
+	var prompts []protocol.Prompt
+	if caps.Prompts != nil {
+		var err error
+		if prompts, err = listAllPrompts(ctx, b.Session); err != nil {
+			return err
+		}
+	}
+
+	for i := range tools {
+		tool := tools[i]
+		name := tool.Name
+		tool.Name = b.Namespace + name
+		if tool.InputSchema == nil {
+			tool.InputSchema = protocol.JSONSchema{"type": "object"}
+		}
+		if _, err := p.server.putTool(&tool, proxyToolHandler(b, name), nil, true); err != nil {
+			return err
+		}
+		next.tools[tool.Name] = true
+	}
+	for i := range resources {
+		resource := resources[i]
+		uri := resource.URI
+		resource.URI = b.Namespace + uri
+		p.server.AddResource(&resource, proxyResourceHandler(b, uri))
+		next.resources[resource.URI] = true
+	}
+	for i := range prompts {
+		prompt := prompts[i]
+		name := prompt.Name
+		prompt.Name = b.Namespace + name
+		p.server.AddPrompt(&prompt, proxyPromptHandler(b, name))
+		next.prompts[prompt.Name] = true
+	}
+
+	// Drop entries the backend no longer offers
+	if prev := p.registered[b]; prev != nil {
+		for name := range prev.tools {
+			if !next.tools[name] {
+				p.server.RemoveTool(name)
+			}
+		}
+		for uri := range prev.resources {
+			if !next.resources[uri] {
+				p.server.RemoveResource(uri)
+			}
+		}
+		for name := range prev.prompts {
+			if !next.prompts[name] {
+				p.server.RemovePrompt(name)
+			}
+		}
+	}
+	p.registered[b] = next
+	return nil
+}
+
+func proxyToolHandler(b *RemoteServer, name string) ToolHandler {
+	return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+		params := *req.Params
+		params.Name = name
+		result, err := b.Session.CallTool(ctx, &params)
+		if err != nil {
+			return protocol.NewToolResultError(fmt.Sprintf("backend %q unavailable: %v", b.Namespace, err)), nil
+		}
+		return result, nil
+	}
+}
+
+func proxyResourceHandler(b *RemoteServer, uri string) ResourceHandler {
+	return func(ctx context.Context, req *ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+		result, err := b.Session.ReadResource(ctx, &protocol.ReadResourceParams{URI: uri})
+		if err != nil {
+			return nil, protocol.NewMCPError(protocol.InternalError, fmt.Sprintf("backend %q unavailable: %v", b.Namespace, err), map[string]any{"uri": req.Params.URI})
+		}
+		for i := range result.Contents {
+			if strings.HasPrefix(result.Contents[i].URI, uri) {
+				result.Contents[i].URI = b.Namespace + result.Contents[i].URI
+			}
+		}
+		return result, nil
+	}
+}
+
+func proxyPromptHandler(b *RemoteServer, name string) PromptHandler {
+	return func(ctx context.Context, req *GetPromptRequest) (*protocol.GetPromptResult, error) {
+		params := *req.Params
+		params.Name = name
+		result, err := b.Session.GetPrompt(ctx, &params)
+		if err != nil {
+			return nil, protocol.NewMCPError(protocol.InternalError, fmt.Sprintf("backend %q unavailable: %v", b.Namespace, err), map[string]any{"name": req.Params.Name})
+		}
+		return result, nil
+	}
+}
+
+func listAllTools(ctx context.Context, cs *client.ClientSession) ([]protocol.Tool, error) {
+	var all []protocol.Tool
+	params := &protocol.ListToolsParams{}
+	for {
+		result, err := cs.ListTools(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("list tools: %w", err)
+		}
+		all = append(all, result.Tools...)
+		if result.NextCursor == nil || *result.NextCursor == "" {
+			return all, nil
+		}
+		params.Cursor = *result.NextCursor
+	}
+}
+
+func listAllResources(ctx context.Context, cs *client.ClientSession) ([]protocol.Resource, error) {
+	var all []protocol.Resource
+	params := &protocol.ListResourcesParams{}
+	for {
+		result, err := cs.ListResources(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("list resources: %w", err)
+		}
+		all = append(all, result.Resources...)
+		if result.NextCursor == nil || *result.NextCursor == "" {
+			return all, nil
+		}
+		params.Cursor = *result.NextCursor
+	}
+}
+
+func listAllPrompts(ctx context.Context, cs *client.ClientSession) ([]protocol.Prompt, error) {
+	var all []protocol.Prompt
+	params := &protocol.ListPromptsParams{}
+	for {
+		result, err := cs.ListPrompts(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("list prompts: %w", err)
+		}
+		all = append(all, result.Prompts...)
+		if result.NextCursor == nil || *result.NextCursor == "" {
+			return all, nil
+		}
+		params.Cursor = *result.NextCursor
+	}
+}
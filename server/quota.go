@@ -0,0 +1,100 @@
+package server
+
+import (
+	"maps"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// ToolCallQuota limits the tool calls of a session, see ServerOptions.ToolCallQuotaFn.
+// Zero limits are unlimited. Days are UTC calendar days and minutes clock minutes.
+//
+// Counts are kept in ServerSessionState.ToolCallUsage, so they survive reconnections
+// through a SessionStore but are not shared between sessions or server instances. For
+// quotas per tenant across instances, or a sliding window, enforce them in a Middleware
+// instead, e.g. with a Redis sorted set per tenant: ZREMRANGEBYSCORE key -inf now-60s
+// drops calls older than a minute, ZCARD key counts the rest against the limit, and
+// ZADD key now <call ID> plus EXPIRE key 60 records an admitted call.
+type ToolCallQuota struct {
+	// DailyLimit caps the calls of all tools per day
+	DailyLimit int
+
+	// MinuteLimit caps the calls of all tools per minute
+	MinuteLimit int
+
+	// PerToolLimits caps the calls per day of individual tools, by name
+	PerToolLimits map[string]int
+}
+
+// NewFixedQuota creates a quota of daily calls and perMinute calls per minute
+func NewFixedQuota(daily, perMinute int) *ToolCallQuota {
+	return &ToolCallQuota{DailyLimit: daily, MinuteLimit: perMinute}
+}
+
+// ToolCallUsage counts the tool calls of a session against its ToolCallQuota
+type ToolCallUsage struct {
+	// Day is the UTC day, as "2006-01-02", of DailyCalls and ToolCalls
+	Day        string         `json:"day,omitempty"`
+	DailyCalls int            `json:"dailyCalls,omitempty"`
+	ToolCalls  map[string]int `json:"toolCalls,omitempty"`
+
+	// Minute is the start of the minute of MinuteCalls
+	Minute      time.Time `json:"minute,omitzero"`
+	MinuteCalls int       `json:"minuteCalls,omitempty"`
+}
+
+func (u ToolCallUsage) clone() ToolCallUsage {
+	u.ToolCalls = maps.Clone(u.ToolCalls)
+	return u
+}
+
+// consume counts a call of tool at now unless it exceeds quota, and reports whether it
+// was counted
+func (u *ToolCallUsage) consume(quota *ToolCallQuota, tool string, now time.Time) bool {
+	now = now.UTC()
+	if day := now.Format(time.DateOnly); u.Day != day {
+		u.Day, u.DailyCalls, u.ToolCalls = day, 0, nil
+	}
+	if minute := now.Truncate(time.Minute); !u.Minute.Equal(minute) {
+		u.Minute, u.MinuteCalls = minute, 0
+	}
+
+	if quota.DailyLimit > 0 && u.DailyCalls >= quota.DailyLimit {
+		return false
+	}
+	if quota.MinuteLimit > 0 && u.MinuteCalls >= quota.MinuteLimit {
+		return false
+	}
+	if limit := quota.PerToolLimits[tool]; limit > 0 && u.ToolCalls[tool] >= limit {
+		return false
+	}
+
+	u.DailyCalls++
+	u.MinuteCalls++
+	if u.ToolCalls == nil {
+		u.ToolCalls = make(map[string]int)
+	}
+	u.ToolCalls[tool]++
+	return true
+}
+
+// checkToolCallQuota counts a call of tool against the session's quota, returning the
+// result to answer with if the quota is exhausted
+func (s *Server) checkToolCallQuota(ss *ServerSession, tool string) (*protocol.CallToolResult, error) {
+	if s.opts.ToolCallQuotaFn == nil || ss == nil {
+		return nil, nil
+	}
+	quota := s.opts.ToolCallQuotaFn(ss)
+	if quota == nil {
+		return nil, nil
+	}
+
+	ss.mu.Lock()
+	allowed := ss.state.ToolCallUsage.consume(quota, tool, time.Now())
+	ss.mu.Unlock()
+	if !allowed {
+		return protocol.NewToolResultError("quota exceeded"), nil
+	}
+	return nil, s.persistSession(ss)
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/transport"
+)
+
+// RBACPolicy decides which tools, resources and prompts a session may use. ctx carries
+// the session's JWT claims, if any, see transport.JWTClaimsFromContext.
+//
+// Set it as ServerOptions.RBACPolicy to guard tools/call, resources/read and prompts/get,
+// or wrap tools only with NewRBACMiddleware.
+type RBACPolicy interface {
+	AllowTool(ctx context.Context, sessionID, toolName string) bool
+	AllowResource(ctx context.Context, sessionID, resourceURI string) bool
+	AllowPrompt(ctx context.Context, sessionID, promptName string) bool
+}
+
+// NewRBACMiddleware answers calls of tools denied by policy with an "access denied"
+// tool error instead of running them
+func NewRBACMiddleware(policy RBACPolicy) Middleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+			if !policy.AllowTool(rbacContext(ctx, req.Session), rbacSessionID(req.Session), req.Params.Name) {
+				return accessDeniedResult(), nil
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+func accessDeniedResult() *protocol.CallToolResult {
+	return protocol.NewToolResultError("access denied")
+}
+
+func accessDeniedError(kind, name string) error {
+	return protocol.NewMCPError(protocol.InvalidRequest, "Access denied", map[string]any{kind: name})
+}
+
+// rbacContext adds the JWT claims of ss to ctx unless the request carries its own
+func rbacContext(ctx context.Context, ss *ServerSession) context.Context {
+	if ss == nil || transport.JWTClaimsFromContext(ctx) != nil {
+		return ctx
+	}
+	if claims := ss.JWTClaims(ctx); claims != nil {
+		return context.WithValue(ctx, transport.JWTClaimsKey{}, claims)
+	}
+	return ctx
+}
+
+func rbacSessionID(ss *ServerSession) string {
+	if ss == nil {
+		return ""
+	}
+	return ss.ID()
+}
+
+// StaticRBACPolicy grants access from a fixed table, see NewStaticRBACPolicy
+type StaticRBACPolicy struct {
+	rules map[string][]string
+}
+
+// NewStaticRBACPolicy creates a policy from rules mapping session IDs to the glob patterns
+// of the tool names, resource URIs and prompt names they may use. The patterns under "*"
+// apply to every session. In patterns, * matches any sequence of characters, including
+// slashes, and ? any single character.
+func NewStaticRBACPolicy(rules map[string][]string) *StaticRBACPolicy {
+	return &StaticRBACPolicy{rules: rules}
+}
+
+func (p *StaticRBACPolicy) allow(sessionID, name string) bool {
+	return matchGlobs(p.rules[sessionID], name) || matchGlobs(p.rules["*"], name)
+}
+
+func (p *StaticRBACPolicy) AllowTool(ctx context.Context, sessionID, toolName string) bool {
+	return p.allow(sessionID, toolName)
+}
+
+func (p *StaticRBACPolicy) AllowResource(ctx context.Context, sessionID, resourceURI string) bool {
+	return p.allow(sessionID, resourceURI)
+}
+
+func (p *StaticRBACPolicy) AllowPrompt(ctx context.Context, sessionID, promptName string) bool {
+	return p.allow(sessionID, promptName)
+}
+
+// jwtRBACPolicy grants access by the roles listed in the session's JWT claims
+type jwtRBACPolicy struct {
+	claimsKey string
+	rolesKey  string
+	rules     map[string][]string
+}
+
+// JWTRBACPolicy creates a policy granting access by role. The roles are read from the JWT
+// claims validated by transport.NewJWTMiddleware: claims[rolesKey], or
+// claims[claimsKey][rolesKey] when claimsKey is set, as for Keycloak's
+// "realm_access": {"roles": [...]}. They may be a list or a space-separated string.
+//
+// rules maps roles to glob patterns as in NewStaticRBACPolicy; the patterns under "*"
+// apply to every session, including unauthenticated ones.
+func JWTRBACPolicy(claimsKey, rolesKey string, rules map[string][]string) RBACPolicy {
+	return &jwtRBACPolicy{claimsKey: claimsKey, rolesKey: rolesKey, rules: rules}
+}
+
+func (p *jwtRBACPolicy) allow(ctx context.Context, name string) bool {
+	if matchGlobs(p.rules["*"], name) {
+		return true
+	}
+	for _, role := range p.roles(transport.JWTClaimsFromContext(ctx)) {
+		if matchGlobs(p.rules[role], name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *jwtRBACPolicy) roles(claims jwt.MapClaims) []string {
+	var source map[string]interface{} = claims
+	if p.claimsKey != "" {
+		source, _ = claims[p.claimsKey].(map[string]interface{})
+	}
+
+	switch roles := source[p.rolesKey].(type) {
+	case string:
+		return strings.Fields(roles)
+	case []interface{}:
+		names := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	case []string:
+		return roles
+	}
+	return nil
+}
+
+func (p *jwtRBACPolicy) AllowTool(ctx context.Context, sessionID, toolName string) bool {
+	return p.allow(ctx, toolName)
+}
+
+func (p *jwtRBACPolicy) AllowResource(ctx context.Context, sessionID, resourceURI string) bool {
+	return p.allow(ctx, resourceURI)
+}
+
+func (p *jwtRBACPolicy) AllowPrompt(ctx context.Context, sessionID, promptName string) bool {
+	return p.allow(ctx, promptName)
+}
+
+func matchGlobs(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether name matches pattern, where * matches any sequence of
+// characters and ? any single character
+func matchGlob(pattern, name string) bool {
+	// Iterative matching, backtracking to the last * on a mismatch
+	p, n := 0, 0
+	star, starN := -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			star, starN = p, n
+			p++
+		case star >= 0:
+			starN++
+			p, n = star+1, starN
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// ResourceMiddleware wraps the handlers of resources/read, as Middleware does for tools
+type ResourceMiddleware func(ResourceHandler) ResourceHandler
+
+// UseResourceMiddleware adds middleware around every resource and resource template
+// handler, including those added later. Middleware is executed in the order added.
+func (s *Server) UseResourceMiddleware(middleware ...ResourceMiddleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resourceMiddlewares = append(s.resourceMiddlewares, middleware...)
+}
+
+// applyResourceMiddleware applies the middleware chain
+func applyResourceMiddleware(handler ResourceHandler, middlewares []ResourceMiddleware) ResourceHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// NewContentCachingMiddleware caches successful read results per URI and requested
+// Accept value for ttl. Cached results are shared by all sessions, so it must not wrap
+// handlers whose contents depend on the session.
+func NewContentCachingMiddleware(ttl time.Duration) ResourceMiddleware {
+	type cacheEntry struct {
+		result  *protocol.ReadResourceResult
+		expires time.Time
+	}
+	var (
+		mu    sync.Mutex
+		cache = make(map[string]cacheEntry)
+	)
+
+	return func(next ResourceHandler) ResourceHandler {
+		return func(ctx context.Context, req *ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+			key := req.Params.URI + "\x00" + req.Params.Accept
+			now := time.Now()
+
+			mu.Lock()
+			entry, ok := cache[key]
+			if ok && now.After(entry.expires) {
+				delete(cache, key)
+				ok = false
+			}
+			mu.Unlock()
+			if ok {
+				return copyReadResult(entry.result), nil
+			}
+
+			result, err := next(ctx, req)
+			if err != nil || result == nil {
+				return result, err
+			}
+			mu.Lock()
+			cache[key] = cacheEntry{result: copyReadResult(result), expires: now.Add(ttl)}
+			mu.Unlock()
+			return result, nil
+		}
+	}
+}
+
+// copyReadResult copies result deeply enough that the read path may modify the copy
+func copyReadResult(result *protocol.ReadResourceResult) *protocol.ReadResourceResult {
+	copied := *result
+	copied.Meta = maps.Clone(result.Meta)
+	copied.Contents = make([]protocol.ResourceContents, len(result.Contents))
+	for i, c := range result.Contents {
+		c.Meta = maps.Clone(c.Meta)
+		copied.Contents[i] = c
+	}
+	return &copied
+}
+
+// compressMinSize is the text size from which NewContentCompressorMiddleware compresses
+const compressMinSize = 1024
+
+// NewContentCompressorMiddleware gzip-compresses text contents of at least 1 KiB. They
+// are sent as a base64 Blob with _meta "contentEncoding": "gzip", which clients undo
+// with ResourceContents.Decompress.
+func NewContentCompressorMiddleware() ResourceMiddleware {
+	return func(next ResourceHandler) ResourceHandler {
+		return func(ctx context.Context, req *ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+			result, err := next(ctx, req)
+			if err != nil || result == nil {
+				return result, err
+			}
+			for i, c := range result.Contents {
+				if c.IsBinary() || len(c.Text) < compressMinSize {
+					continue
+				}
+				var buf bytes.Buffer
+				zw := gzip.NewWriter(&buf)
+				if _, err := zw.Write([]byte(c.Text)); err != nil {
+					return nil, fmt.Errorf("compress %s: %w", c.URI, err)
+				}
+				if err := zw.Close(); err != nil {
+					return nil, fmt.Errorf("compress %s: %w", c.URI, err)
+				}
+
+				compressed := protocol.NewBlobResourceContents(c.URI, c.MimeType, buf.Bytes())
+				compressed.Title, compressed.Annotations, compressed.ETag = c.Title, c.Annotations, c.ETag
+				compressed.Meta = maps.Clone(c.Meta)
+				if compressed.Meta == nil {
+					compressed.Meta = make(map[string]any, 1)
+				}
+				compressed.Meta[protocol.ContentEncodingMetaKey] = "gzip"
+				result.Contents[i] = compressed
+			}
+			return result, nil
+		}
+	}
+}
+
+// NewTemplateExpanderMiddleware executes text contents as Go text/template templates
+// with vars as data, e.g. "Hello {{.name}}". Referencing a variable missing from vars
+// fails the read.
+func NewTemplateExpanderMiddleware(vars map[string]string) ResourceMiddleware {
+	return func(next ResourceHandler) ResourceHandler {
+		return func(ctx context.Context, req *ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+			result, err := next(ctx, req)
+			if err != nil || result == nil {
+				return result, err
+			}
+			for i, c := range result.Contents {
+				if c.IsBinary() || !strings.Contains(c.Text, "{{") {
+					continue
+				}
+				tmpl, err := template.New(c.URI).Option("missingkey=error").Parse(c.Text)
+				if err != nil {
+					return nil, fmt.Errorf("parse template %s: %w", c.URI, err)
+				}
+				var buf strings.Builder
+				if err := tmpl.Execute(&buf, vars); err != nil {
+					return nil, fmt.Errorf("expand template %s: %w", c.URI, err)
+				}
+				result.Contents[i].Text = buf.String()
+			}
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// resourceStreamChunkSize is the maximum number of bytes carried by one chunk notification
+const resourceStreamChunkSize = 64 << 10
+
+// ResourceStreamHandler returns the content of a streaming resource as a reader together
+// with its MIME type. If the reader implements io.Closer it is closed once the read completes.
+type ResourceStreamHandler func(ctx context.Context, req *ReadResourceRequest) (io.Reader, string, error)
+
+// AddStreamingResource registers a resource whose content is produced incrementally,
+// e.g. a live log file, instead of being held in memory.
+//
+// When the session can deliver notifications (a persistent connection, or a Streamable HTTP
+// request that accepts text/event-stream) the content is sent as a sequence of
+// notifications/resources/chunk notifications, each becoming its own SSE data event, followed
+// by a resources/read result without content whose _meta.chunks holds the number of chunks.
+// Otherwise the stream is collected into a single ResourceContents.
+func (s *Server) AddStreamingResource(r *protocol.Resource, h ResourceStreamHandler) {
+	s.AddResource(r, func(ctx context.Context, req *ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+		return readResourceStream(ctx, req, h)
+	})
+}
+
+func readResourceStream(ctx context.Context, req *ReadResourceRequest, h ResourceStreamHandler) (*protocol.ReadResourceResult, error) {
+	reader, mimeType, err := h(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	uri := req.Params.URI
+	text := isTextMimeType(mimeType)
+
+	if req.Session == nil || req.Session.conn == nil {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("read resource stream: %w", err)
+		}
+		contents := protocol.ResourceContents{URI: uri, MimeType: mimeType, Text: string(data)}
+		if !text {
+			contents = protocol.NewBlobResourceContents(uri, mimeType, data)
+		}
+		return &protocol.ReadResourceResult{Contents: []protocol.ResourceContents{contents}}, nil
+	}
+
+	buf := make([]byte, resourceStreamChunkSize)
+	pending := 0 // bytes of an incomplete UTF-8 sequence carried over from the previous read
+	index := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		n, readErr := reader.Read(buf[pending:])
+		n += pending
+		eof := errors.Is(readErr, io.EOF)
+		if readErr != nil && !eof {
+			return nil, fmt.Errorf("read resource stream: %w", readErr)
+		}
+
+		chunk := buf[:n]
+		if text && !eof {
+			chunk = chunk[:completeUTF8Prefix(chunk)]
+		}
+		if len(chunk) > 0 {
+			params := &protocol.ResourceChunkNotificationParams{URI: uri, Index: index, MimeType: mimeType}
+			if text {
+				params.Text = string(chunk)
+			} else {
+				params.Blob = base64.StdEncoding.EncodeToString(chunk)
+			}
+			if err := req.Session.conn.SendNotification(ctx, protocol.NotificationResourcesChunk, params); err != nil {
+				return nil, fmt.Errorf("send resource chunk: %w", err)
+			}
+			index++
+		}
+		pending = copy(buf, buf[len(chunk):n])
+
+		if eof {
+			break
+		}
+	}
+
+	return &protocol.ReadResourceResult{
+		Meta:     map[string]any{"chunks": index},
+		Contents: []protocol.ResourceContents{{URI: uri, MimeType: mimeType}},
+	}, nil
+}
+
+// completeUTF8Prefix returns the length of b without a trailing incomplete UTF-8 sequence
+func completeUTF8Prefix(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
+
+func isTextMimeType(mimeType string) bool {
+	if mimeType == "" || strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	for _, s := range []string{"json", "xml", "javascript", "yaml"} {
+		if strings.Contains(mimeType, s) {
+			return true
+		}
+	}
+	return false
+}
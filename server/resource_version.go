@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"slices"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// defaultResourceVersionHistory is the number of versions kept per resource when
+// ServerOptions.ResourceVersionHistory is zero
+const defaultResourceVersionHistory = 10
+
+// defaultResourceVersionURIs is the number of resources whose version history is kept
+// when ServerOptions.ResourceVersionURIs is zero
+const defaultResourceVersionURIs = 1000
+
+// VersionedResourceHandler reads a resource at version, as requested with
+// ReadResourceParams.Version. An empty version asks for the current contents.
+type VersionedResourceHandler func(ctx context.Context, req *ReadResourceRequest, version string) (*protocol.ReadResourceResult, error)
+
+// AddVersionedResource adds a resource whose handler can produce any of its versions,
+// e.g. from a database or version control. Reads of a version are passed to h instead of
+// being served from the server's version history.
+func (s *Server) AddVersionedResource(r *protocol.Resource, h VersionedResourceHandler) {
+	s.mu.Lock()
+
+	s.resources[r.URI] = &serverResource{
+		resource: r,
+		handler: func(ctx context.Context, req *ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+			return h(ctx, req, req.Params.Version)
+		},
+		versioned: true,
+	}
+
+	s.mu.Unlock()
+
+	s.syncResourceWatch(r.URI)
+	s.notifyResourceListChanged()
+}
+
+// versionHistory is a ring buffer of the latest versions served for a resource
+type versionHistory struct {
+	uri     string
+	entries []resourceVersion
+	next    int // slot written by the next add
+	full    bool
+}
+
+type resourceVersion struct {
+	version  string
+	contents []protocol.ResourceContents
+}
+
+func newVersionHistory(uri string, size int) *versionHistory {
+	if size <= 0 {
+		size = defaultResourceVersionHistory
+	}
+	return &versionHistory{uri: uri, entries: make([]resourceVersion, size)}
+}
+
+// add records contents as version, replacing the contents of the newest entry if it has
+// the same version
+func (h *versionHistory) add(version string, contents []protocol.ResourceContents) {
+	entry := resourceVersion{version: version, contents: slices.Clone(contents)}
+	if last := (h.next - 1 + len(h.entries)) % len(h.entries); (h.full || h.next > 0) && h.entries[last].version == version {
+		h.entries[last] = entry
+		return
+	}
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// ordered returns the entries, oldest first
+func (h *versionHistory) ordered() []resourceVersion {
+	if !h.full {
+		return h.entries[:h.next]
+	}
+	return append(slices.Clone(h.entries[h.next:]), h.entries[:h.next]...)
+}
+
+func (h *versionHistory) get(version string) ([]protocol.ResourceContents, bool) {
+	for _, entry := range h.entries {
+		if entry.version == version && entry.contents != nil {
+			return slices.Clone(entry.contents), true
+		}
+	}
+	return nil, false
+}
+
+func (h *versionHistory) versions() []string {
+	var versions []string
+	for _, entry := range h.ordered() {
+		versions = append(versions, entry.version)
+	}
+	return versions
+}
+
+// contentsVersion returns the first version set on contents
+func contentsVersion(contents []protocol.ResourceContents) string {
+	for _, c := range contents {
+		if c.Version != "" {
+			return c.Version
+		}
+	}
+	return ""
+}
+
+// historicResourceVersion returns version of uri from the version history
+func (s *Server) historicResourceVersion(uri, version string) (*protocol.ReadResourceResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem := s.resourceVersions[uri]
+	if elem == nil {
+		return nil, false
+	}
+	s.resourceVersionLRU.MoveToFront(elem)
+	h := elem.Value.(*versionHistory)
+	contents, ok := h.get(version)
+	if !ok {
+		return nil, false
+	}
+	return &protocol.ReadResourceResult{Contents: contents, AvailableVersions: h.versions()}, true
+}
+
+// recordResourceVersion adds the contents of result to the version history of uri when
+// they carry a version, and fills in result.AvailableVersions unless the handler did.
+// Template-expanded URIs are unbounded, so only the histories of the
+// ResourceVersionURIs most recently read resources are kept.
+func (s *Server) recordResourceVersion(uri string, result *protocol.ReadResourceResult) {
+	version := contentsVersion(result.Contents)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var h *versionHistory
+	if elem := s.resourceVersions[uri]; elem != nil {
+		s.resourceVersionLRU.MoveToFront(elem)
+		h = elem.Value.(*versionHistory)
+	}
+	if version != "" {
+		if h == nil {
+			h = newVersionHistory(uri, s.opts.ResourceVersionHistory)
+			s.resourceVersions[uri] = s.resourceVersionLRU.PushFront(h)
+			maxURIs := s.opts.ResourceVersionURIs
+			if maxURIs <= 0 {
+				maxURIs = defaultResourceVersionURIs
+			}
+			for s.resourceVersionLRU.Len() > maxURIs {
+				s.forgetResourceVersions(s.resourceVersionLRU.Back().Value.(*versionHistory).uri)
+			}
+		}
+		h.add(version, result.Contents)
+	}
+	if h != nil && result.AvailableVersions == nil {
+		result.AvailableVersions = h.versions()
+	}
+}
+
+// forgetResourceVersions drops the version history of uri. s.mu must be held.
+func (s *Server) forgetResourceVersions(uri string) {
+	if elem, ok := s.resourceVersions[uri]; ok {
+		s.resourceVersionLRU.Remove(elem)
+		delete(s.resourceVersions, uri)
+	}
+}
+
+func unknownVersionError(uri, version string, available []string) error {
+	return protocol.NewMCPError(protocol.InvalidParams, "Unknown resource version", map[string]any{
+		"uri":       uri,
+		"version":   version,
+		"available": available,
+	})
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// ResourceWriteHandler stores new contents for a writable resource
+type ResourceWriteHandler func(ctx context.Context, req *WriteResourceRequest) (*protocol.WriteResourceResult, error)
+
+// WriteResourceRequest is a resources/write request; Params.Contents.URI is always the target URI
+type WriteResourceRequest struct {
+	Session *ServerSession
+	Params  *protocol.WriteResourceParams
+}
+
+// AddWritableResource adds a resource that clients can also replace with resources/write,
+// an SDK extension advertised as the "writable" resources capability. After a successful
+// write the recorded ETag of the resource is cleared and its subscribers are notified.
+func (s *Server) AddWritableResource(r *protocol.Resource, readH ResourceHandler, writeH ResourceWriteHandler) {
+	s.mu.Lock()
+
+	s.resources[r.URI] = &serverResource{
+		resource: r,
+		handler:  readH,
+		writer:   writeH,
+	}
+
+	s.mu.Unlock()
+
+	s.syncResourceWatch(r.URI)
+	s.notifyResourceListChanged()
+}
+
+// writableLocked reports whether resources/write is available; s.mu must be held
+func (s *Server) writableLocked() bool {
+	for _, sr := range s.resources {
+		if sr.writer != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// handleWriteResource handles the resources/write request
+func (s *Server) handleWriteResource(ctx context.Context, ss *ServerSession, params json.RawMessage) (*protocol.WriteResourceResult, error) {
+	s.mu.Lock()
+	supported := s.writableLocked()
+	s.mu.Unlock()
+	if !supported {
+		return nil, protocol.NewMCPError(protocol.MethodNotFound, "Method not found", map[string]any{"method": protocol.MethodResourcesWrite})
+	}
+
+	var req protocol.WriteResourceParams
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid params", map[string]any{"method": protocol.MethodResourcesWrite})
+	}
+	if req.Contents.URI == "" {
+		req.Contents.URI = req.URI
+	} else if req.Contents.URI != req.URI {
+		return nil, protocol.NewMCPError(protocol.InvalidParams, "Contents URI does not match the resource URI", map[string]any{
+			"uri":         req.URI,
+			"contentsUri": req.Contents.URI,
+		})
+	}
+
+	s.mu.Lock()
+	var writer ResourceWriteHandler
+	sr, found := s.resources[req.URI]
+	found = found && !sr.disabled
+	if found {
+		writer = sr.writer
+	}
+	s.mu.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, req.URI)
+	}
+	if writer == nil {
+		return nil, protocol.NewMCPError(protocol.InvalidParams, "Resource is not writable", map[string]any{"uri": req.URI})
+	}
+
+	result, err := writer(ctx, &WriteResourceRequest{Session: ss, Params: &req})
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = &protocol.WriteResourceResult{}
+	}
+
+	s.UpdateResourceETag(req.URI, "")
+	s.NotifyResourceUpdated(req.URI)
+	return result, nil
+}
@@ -2,12 +2,14 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"sync"
 
 	invopop "github.com/invopop/jsonschema"
 	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/voocel/mcp-sdk-go/protocol"
 	"github.com/voocel/mcp-sdk-go/utils"
 )
 
@@ -28,6 +30,11 @@ func inferSchema[T any](customTypes ...map[reflect.Type]*invopop.Schema) (*invop
 
 // compileSchema compiles JSON Schema and caches the result
 func compileSchema(schema *invopop.Schema) (*jsonschema.Schema, error) {
+	return compileSchemaValue(schema)
+}
+
+// compileSchemaValue compiles any JSON-encodable schema value (e.g. protocol.JSONSchema) and caches the result
+func compileSchemaValue(schema any) (*jsonschema.Schema, error) {
 	schemaBytes, err := json.Marshal(schema)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal schema: %w", err)
@@ -68,6 +75,59 @@ func compileSchema(schema *invopop.Schema) (*jsonschema.Schema, error) {
 	return compiledSchema, nil
 }
 
+// validateAgainstSchema validates a JSON-encodable value against a protocol schema
+func validateAgainstSchema(schema protocol.JSONSchema, value any) error {
+	compiledSchema, err := compileSchemaValue(schema)
+	if err != nil {
+		return err
+	}
+
+	// Normalize the value to the generic JSON representation expected by the validator
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+	var instance any
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return fmt.Errorf("failed to unmarshal value: %w", err)
+	}
+
+	return compiledSchema.Validate(instance)
+}
+
+// validateStructuredContent validates a tool's structured content against its output schema.
+// Nothing is checked when either is nil; use schemaViolations to list the violations of the returned error.
+func validateStructuredContent(content interface{}, schema map[string]interface{}) error {
+	if content == nil || schema == nil {
+		return nil
+	}
+	return validateAgainstSchema(schema, content)
+}
+
+// schemaViolations flattens a validation error into one message per violated constraint
+func schemaViolations(err error) []string {
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return []string{err.Error()}
+	}
+
+	var violations []string
+	for _, unit := range verr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		violations = append(violations, fmt.Sprintf("%s: %s", location, unit.Error.String()))
+	}
+	if len(violations) == 0 {
+		violations = append(violations, verr.Error())
+	}
+	return violations
+}
+
 // applyDefaults applies default values to data
 func applyDefaults(data map[string]any, schema *invopop.Schema) {
 	if schema.Properties == nil {
@@ -1,17 +1,25 @@
 package server
 
 import (
+	"container/list"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/voocel/mcp-sdk-go/protocol"
 	"github.com/voocel/mcp-sdk-go/transport"
+	"golang.org/x/sync/errgroup"
 )
 
 // Server represents an MCP server instance that can serve one or more MCP sessions
@@ -26,8 +34,24 @@ type Server struct {
 	resourceTemplates     map[string]*serverResourceTemplate
 	prompts               map[string]*serverPrompt
 	sessions              []*ServerSession
+	transientSessions     map[*ServerSession]bool            // sessions of in-flight HandleMessage calls
+	shuttingDown          atomic.Bool                        // set by Shutdown, new requests are rejected
 	resourceSubscriptions map[string]map[*ServerSession]bool // uri -> session -> bool
+	resourceWatches       map[string]*resourceWatch          // uri -> active watch
+	watchMu               sync.Mutex                         // serializes starting and stopping watches
+	completions           map[completionKey]CompletionFn     // per-tool/prompt argument completions
 	tasks                 map[string]*serverTask             // taskId -> task (MCP 2025-11-25)
+	resourceReadObserver  func(uri string, err error)        // set by UsePrometheus
+	maskingRules          []MaskingRule                      // set by UseMasking, applied to audit events
+	errorCodes            sync.Map                           // code -> name, see RegisterErrorCode
+	idempotencyFlights    sync.Map                           // idempotencyStoreKey -> *toolFlight of the running call
+	toolSlots             chan struct{}                      // semaphore for MaxConcurrentToolCalls
+	resourceMiddlewares   []ResourceMiddleware
+	mounted               []*Server                // servers mounted with Mount
+	resourceVersions      map[string]*list.Element // uri -> element of resourceVersionLRU holding a *versionHistory
+	resourceVersionLRU    *list.List               // most recently read first, bounded by ResourceVersionURIs
+	admin                 *http.Server             // serves AdminAddr, closed by Shutdown
+	adminAddr             net.Addr
 }
 
 // serverTask represents a task stored in the server (MCP 2025-11-25)
@@ -45,9 +69,31 @@ type ServerOptions struct {
 	// Optional client instructions
 	Instructions string
 
+	// MinProtocolVersion rejects clients requesting an older protocol version with an
+	// InvalidParams error listing the supported versions. Empty accepts every client.
+	MinProtocolVersion string
+
 	// Initialized handler function
 	InitializedHandler func(context.Context, *ServerSession)
 
+	// OnConnect is called as soon as Connect creates a session, before the client initializes it.
+	// Closing the session here rejects the connection.
+	OnConnect func(context.Context, *ServerSession)
+
+	// OnDisconnect is called when a session ends, before it is removed from the server.
+	// err is the error that ended the session, or nil if the connection was closed cleanly.
+	OnDisconnect func(*ServerSession, error)
+
+	// OnSessionOpen is called synchronously in the goroutine calling Connect, right after the
+	// session is added to the server and before any message is read from it.
+	// OnSessionClose is called in the session's read loop goroutine once the loop has ended,
+	// with the error that ended it or nil for a clean close; no further messages are read from
+	// the session afterwards. For a given session OnSessionOpen returns before OnSessionClose
+	// is called, but hooks of different sessions may run concurrently.
+	// Connection handling waits for the hooks, so they must not block.
+	OnSessionOpen  func(ss *ServerSession)
+	OnSessionClose func(ss *ServerSession, reason error)
+
 	// Progress notification handler function
 	ProgressNotificationHandler func(context.Context, *ServerSession, *protocol.ProgressNotificationParams)
 
@@ -64,10 +110,112 @@ type ServerOptions struct {
 	SubscribeHandler   func(context.Context, *protocol.SubscribeParams) error
 	UnsubscribeHandler func(context.Context, *protocol.UnsubscribeParams) error
 
+	// SessionStore persists session state (initialization, log level, resource subscriptions)
+	// so that a client reconnecting with the same session ID resumes where it left off
+	SessionStore SessionStore
+
 	// KeepAlive defines the interval for periodic "ping" requests
 	// If the peer fails to respond to a keepalive ping, the session will be closed automatically
 	KeepAlive time.Duration
 
+	// ShutdownTimeout is how long in-flight requests may keep running once the server shuts down,
+	// either through Shutdown or by cancelling the context passed to Connect/Run.
+	// Handlers still running afterwards have their contexts cancelled.
+	// Zero cancels in-flight requests immediately when the Connect context is cancelled.
+	ShutdownTimeout time.Duration
+
+	// SkipOutputSchemaValidation stops validating a tool's StructuredContent against its OutputSchema
+	// after each call, for servers that trust their handlers. By default violating results are
+	// replaced with a tool error result.
+	SkipOutputSchemaValidation bool
+
+	// ValidateStructuredOutput fails requests whose StructuredContent violates the tool's OutputSchema
+	// with an InvalidTool JSON-RPC error listing every violation, instead of a tool error result.
+	// It takes precedence over SkipOutputSchemaValidation.
+	ValidateStructuredOutput bool
+
+	// StrictToolRegistration makes Server.AddTool fail with ErrToolExists instead of
+	// replacing a tool with the same name. Use Server.UpdateTool to replace tools.
+	StrictToolRegistration bool
+
+	// MaxConcurrentToolCalls limits how many tools/call requests run at once across all tools.
+	// Further calls wait for a free slot; a call whose context ends while waiting returns a
+	// "server is at capacity" tool error. Zero or negative means no limit.
+	MaxConcurrentToolCalls int
+
+	// ElicitationTimeout bounds how long ServerSession.Elicit waits for the user to answer;
+	// an unanswered request then yields an ElicitationActionTimeout result. Zero waits
+	// until the request context ends.
+	ElicitationTimeout time.Duration
+
+	// TokenRotationInterval makes each session send a new session token with
+	// notifications/auth/token_rotated at this interval, see ServerSession.RotateToken.
+	// Zero disables rotation.
+	TokenRotationInterval time.Duration
+
+	// TokenRotationGrace is how long a replaced session token stays valid. Zero means 30 seconds.
+	TokenRotationGrace time.Duration
+
+	// AdminAddr is the address, e.g. "localhost:9090", on which Server.StartAdmin serves
+	// the administrative API, see AdminHandler. Use a port not reachable by MCP clients.
+	AdminAddr string
+
+	// AdminAuthToken is the bearer token required by the administrative API. It may only
+	// be empty when AdminAddr is a loopback address.
+	AdminAuthToken string
+
+	// OpenAPICORS sets the cross-origin policy of the document served by ServeOpenAPI,
+	// e.g. for a Swagger UI on another origin. Nil sends no CORS headers.
+	OpenAPICORS *transport.CORSConfig
+
+	// ToolCallQuotaFn returns the quota of a session, checked before each tools/call.
+	// Calls beyond it return a "quota exceeded" tool error. A nil quota is unlimited.
+	ToolCallQuotaFn func(ss *ServerSession) *ToolCallQuota
+
+	// RBACPolicy restricts the tools, resources and prompts each session may use. Denied
+	// tool calls return an "access denied" tool error; denied reads and prompts fail with
+	// an InvalidRequest error. Nil allows everything.
+	RBACPolicy RBACPolicy
+
+	// IdempotencyStore keeps the results of tools/call requests carrying an idempotency key,
+	// so that retries of a successful call return its result without running the tool again.
+	// Nil ignores idempotency keys.
+	IdempotencyStore IdempotencyStore
+
+	// IdempotencyTTL is how long results are kept in the IdempotencyStore. Zero means 24 hours.
+	IdempotencyTTL time.Duration
+
+	// ResourceVersionHistory is how many versions of each resource are kept for reads of
+	// past versions, see protocol.ResourceContents.Version. Zero keeps 10.
+	ResourceVersionHistory int
+
+	// ResourceVersionURIs is how many resources have their version history kept. The
+	// history of the least recently read resource is dropped first. Zero keeps 1000.
+	ResourceVersionURIs int
+
+	// BatchConcurrency limits how many requests of a JSON-RPC batch are processed concurrently.
+	// Zero or negative processes batch members sequentially.
+	BatchConcurrency int
+
+	// Middlewares are applied to every tool handler, in the same order as Server.Use
+	Middlewares []Middleware
+
+	// AuditHandler receives an AuditEvent for every tools/call, including failed and cancelled ones.
+	// It runs in its own goroutine after the call returns and never delays the response.
+	AuditHandler func(context.Context, AuditEvent)
+
+	// ToolsPageSize limits the number of tools returned per tools/list page.
+	// Zero or negative disables pagination.
+	ToolsPageSize int
+
+	// ResourcesPageSize limits the number of resources returned per resources/list page.
+	// Zero or negative disables pagination.
+	ResourcesPageSize int
+
+	// PromptsPageSize limits the number of prompts returned per prompts/list page.
+	// Zero or negative disables pagination.
+	PromptsPageSize int
+
 	// Tasks capability options (MCP 2025-11-25)
 	TasksEnabled bool // Enable tasks support
 
@@ -88,16 +236,49 @@ type ServerOptions struct {
 type serverTool struct {
 	tool    *protocol.Tool
 	handler ToolHandler
+	opts    ToolOptions
+	slots   chan struct{} // semaphore for ToolOptions.MaxConcurrency
+
+	// Set by DisableTool, guarded by Server.mu
+	disabled       bool
+	disabledReason string
+}
+
+// ToolOptions configures per-tool behavior
+type ToolOptions struct {
+	// Timeout bounds each invocation of the tool, zero means no limit
+	Timeout time.Duration
+
+	// Validators holds domain-specific checks per argument name, see WithValidator
+	Validators map[string][]ParamValidator
+
+	// MaxConcurrency limits concurrent calls of this tool, in addition to
+	// ServerOptions.MaxConcurrentToolCalls. Zero or negative means no limit.
+	MaxConcurrency int
+
+	// DeduplicationWindow is how long after an execution starts that identical calls of a
+	// tool added with AddDeduplicatedTool reuse its result. Zero only shares executions
+	// still in flight.
+	DeduplicationWindow time.Duration
+
+	dedupWaiting *atomic.Int32 // calls waiting for a shared execution, set by AddDeduplicatedTool
 }
 
 type serverResource struct {
 	resource *protocol.Resource
 	handler  ResourceHandler
+	watcher  ResourceWatcher
+	disabled bool   // set by DisableResource, guarded by Server.mu
+	etag     string // set by UpdateResourceETag, guarded by Server.mu
+	writer   ResourceWriteHandler
+	// versioned resources serve ReadResourceParams.Version themselves, see AddVersionedResource
+	versioned bool
 }
 
 type serverResourceTemplate struct {
 	template *protocol.ResourceTemplate
 	handler  ResourceHandler
+	matcher  *uriTemplate // nil if the template cannot be matched (not Level 1)
 }
 
 type serverPrompt struct {
@@ -113,11 +294,27 @@ type ReadResourceRequest struct {
 	Params  *protocol.ReadResourceParams
 }
 
+// Meta returns the _meta object the client sent with the request
+func (r *ReadResourceRequest) Meta() map[string]any {
+	if r == nil || r.Params == nil {
+		return nil
+	}
+	return r.Params.Meta
+}
+
 type GetPromptRequest struct {
 	Session *ServerSession
 	Params  *protocol.GetPromptParams
 }
 
+// Meta returns the _meta object the client sent with the request
+func (r *GetPromptRequest) Meta() map[string]any {
+	if r == nil || r.Params == nil {
+		return nil
+	}
+	return r.Params.Meta
+}
+
 func NewServer(impl *protocol.ServerInfo, opts *ServerOptions) *Server {
 	s := &Server{
 		impl:                  impl,
@@ -126,21 +323,32 @@ func NewServer(impl *protocol.ServerInfo, opts *ServerOptions) *Server {
 		resourceTemplates:     make(map[string]*serverResourceTemplate),
 		prompts:               make(map[string]*serverPrompt),
 		sessions:              make([]*ServerSession, 0),
+		transientSessions:     make(map[*ServerSession]bool),
 		resourceSubscriptions: make(map[string]map[*ServerSession]bool),
+		resourceWatches:       make(map[string]*resourceWatch),
+		resourceVersions:      make(map[string]*list.Element),
+		resourceVersionLRU:    list.New(),
+		completions:           make(map[completionKey]CompletionFn),
 		tasks:                 make(map[string]*serverTask),
 	}
 	if opts != nil {
 		s.opts = *opts
+		s.middlewares = append(s.middlewares, opts.Middlewares...)
+		if opts.MaxConcurrentToolCalls > 0 {
+			s.toolSlots = make(chan struct{}, opts.MaxConcurrentToolCalls)
+		}
 	}
 	return s
 }
 
 // AddTool adds a tool to the server, or replaces a tool with the same name (low-level API).
+// With ServerOptions.StrictToolRegistration it returns ErrToolExists instead of replacing.
+// A replaced tool keeps its disabled state, see DisableTool.
 // The Tool parameter must not be modified after this call.
 //
-// The tool's input schema must be non-nil and have type "object". For tools that accept
-// no input or any input, set [Tool.InputSchema] to `{"type": "object"}` using your
-// preferred library or `json.RawMessage`.
+// The tool's input schema must be non-nil and have type "object"; a nil schema is an
+// error. For tools that accept no input or any input, set [Tool.InputSchema] to
+// `{"type": "object"}` using your preferred library or `json.RawMessage`.
 //
 // If [Tool.OutputSchema] exists, it must also have type "object".
 //
@@ -158,27 +366,62 @@ func NewServer(impl *protocol.ServerInfo, opts *ServerOptions) *Server {
 //
 // Most users should use the top-level function [AddTool], which handles all
 // these responsibilities.
-func (s *Server) AddTool(t *protocol.Tool, h ToolHandler) {
+//
+// An optional ToolOptions configures per-tool behavior such as a call timeout.
+func (s *Server) AddTool(t *protocol.Tool, h ToolHandler, opts ...*ToolOptions) error {
+	_, err := s.putTool(t, h, opts, !s.opts.StrictToolRegistration)
+	return err
+}
+
+// putTool adds t, replacing a tool with the same name only if replace is set, and
+// returns the tool it replaced. A replaced tool stays disabled if it was.
+func (s *Server) putTool(t *protocol.Tool, h ToolHandler, opts []*ToolOptions, replace bool) (*protocol.Tool, error) {
 	if t.InputSchema == nil {
-		panic(fmt.Errorf("AddTool %q: missing input schema", t.Name))
+		return nil, fmt.Errorf("tool %q: missing input schema", t.Name)
+	}
+
+	var toolOpts ToolOptions
+	if len(opts) > 0 && opts[0] != nil {
+		toolOpts = *opts[0]
+	}
+	if len(toolOpts.Validators) > 0 {
+		h = validateParams(t.Name, toolOpts.Validators, h)
 	}
 
 	s.mu.Lock()
 
+	var previous *protocol.Tool
+	var disabled bool
+	var disabledReason string
+	if st, exists := s.tools[t.Name]; exists {
+		if !replace {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrToolExists, t.Name)
+		}
+		previous = st.tool
+		disabled, disabledReason = st.disabled, st.disabledReason
+	}
+
 	// Apply middleware
 	wrappedHandler := applyMiddleware(h, s.middlewares)
 
-	s.tools[t.Name] = &serverTool{
-		tool:    t,
-		handler: wrappedHandler,
+	st := &serverTool{
+		tool:           t,
+		handler:        wrappedHandler,
+		opts:           toolOpts,
+		disabled:       disabled,
+		disabledReason: disabledReason,
+	}
+	if toolOpts.MaxConcurrency > 0 {
+		st.slots = make(chan struct{}, toolOpts.MaxConcurrency)
 	}
+	s.tools[t.Name] = st
 
-	sessions := make([]*ServerSession, len(s.sessions))
-	copy(sessions, s.sessions)
 	s.mu.Unlock()
 
 	// Notify all sessions that the tool list has changed
-	notifyToolListChanged(sessions)
+	s.notifyToolListChanged()
+	return previous, nil
 }
 
 func (s *Server) RemoveTool(name string) {
@@ -190,13 +433,46 @@ func (s *Server) RemoveTool(name string) {
 		changed = true
 	}
 
-	sessions := make([]*ServerSession, len(s.sessions))
-	copy(sessions, s.sessions)
 	s.mu.Unlock()
 
 	if changed {
-		notifyToolListChanged(sessions)
+		s.notifyToolListChanged()
+	}
+}
+
+// DisableTool temporarily withdraws a tool without removing it: it is omitted from
+// tools/list and calls fail with a tool error until EnableTool is called.
+// An optional reason is reported to callers.
+func (s *Server) DisableTool(name string, reason ...string) error {
+	var why string
+	if len(reason) > 0 {
+		why = reason[0]
 	}
+	return s.setToolDisabled(name, true, why)
+}
+
+// EnableTool makes a tool disabled by DisableTool available again
+func (s *Server) EnableTool(name string) error {
+	return s.setToolDisabled(name, false, "")
+}
+
+func (s *Server) setToolDisabled(name string, disabled bool, reason string) error {
+	s.mu.Lock()
+	st, exists := s.tools[name]
+	if !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	changed := st.disabled != disabled
+	st.disabled = disabled
+	st.disabledReason = reason
+
+	s.mu.Unlock()
+
+	if changed {
+		s.notifyToolListChanged()
+	}
+	return nil
 }
 
 func (s *Server) AddResource(r *protocol.Resource, h ResourceHandler) {
@@ -207,11 +483,10 @@ func (s *Server) AddResource(r *protocol.Resource, h ResourceHandler) {
 		handler:  h,
 	}
 
-	sessions := make([]*ServerSession, len(s.sessions))
-	copy(sessions, s.sessions)
 	s.mu.Unlock()
 
-	notifyResourceListChanged(sessions)
+	s.syncResourceWatch(r.URI)
+	s.notifyResourceListChanged()
 }
 
 func (s *Server) RemoveResource(uri string) {
@@ -220,31 +495,62 @@ func (s *Server) RemoveResource(uri string) {
 	var changed bool
 	if _, exists := s.resources[uri]; exists {
 		delete(s.resources, uri)
+		s.forgetResourceVersions(uri)
 		changed = true
 	}
 
-	sessions := make([]*ServerSession, len(s.sessions))
-	copy(sessions, s.sessions)
 	s.mu.Unlock()
 
 	if changed {
-		notifyResourceListChanged(sessions)
+		s.syncResourceWatch(uri)
+		s.notifyResourceListChanged()
 	}
 }
 
+// DisableResource temporarily withdraws a resource without removing it: it is omitted
+// from resources/list, reads fail as if it did not exist and its subscribers receive no
+// update notifications until EnableResource is called. A watcher keeps running meanwhile,
+// so it can observe the backing data becoming available again.
+func (s *Server) DisableResource(uri string) error {
+	return s.setResourceDisabled(uri, true)
+}
+
+// EnableResource makes a resource disabled by DisableResource available again
+func (s *Server) EnableResource(uri string) error {
+	return s.setResourceDisabled(uri, false)
+}
+
+func (s *Server) setResourceDisabled(uri string, disabled bool) error {
+	s.mu.Lock()
+	sr, exists := s.resources[uri]
+	if !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrResourceNotFound, uri)
+	}
+	changed := sr.disabled != disabled
+	sr.disabled = disabled
+
+	s.mu.Unlock()
+
+	if changed {
+		s.notifyResourceListChanged()
+	}
+	return nil
+}
+
 func (s *Server) AddResourceTemplate(t *protocol.ResourceTemplate, h ResourceHandler) {
 	s.mu.Lock()
 
+	matcher, _ := compileURITemplate(t.URITemplate)
 	s.resourceTemplates[t.URITemplate] = &serverResourceTemplate{
 		template: t,
 		handler:  h,
+		matcher:  matcher,
 	}
 
-	sessions := make([]*ServerSession, len(s.sessions))
-	copy(sessions, s.sessions)
 	s.mu.Unlock()
 
-	notifyResourceListChanged(sessions)
+	s.notifyResourceListChanged()
 }
 
 func (s *Server) RemoveResourceTemplate(uriTemplate string) {
@@ -256,12 +562,10 @@ func (s *Server) RemoveResourceTemplate(uriTemplate string) {
 		changed = true
 	}
 
-	sessions := make([]*ServerSession, len(s.sessions))
-	copy(sessions, s.sessions)
 	s.mu.Unlock()
 
 	if changed {
-		notifyResourceListChanged(sessions)
+		s.notifyResourceListChanged()
 	}
 }
 
@@ -273,11 +577,9 @@ func (s *Server) AddPrompt(p *protocol.Prompt, h PromptHandler) {
 		handler: h,
 	}
 
-	sessions := make([]*ServerSession, len(s.sessions))
-	copy(sessions, s.sessions)
 	s.mu.Unlock()
 
-	notifyPromptListChanged(sessions)
+	s.notifyPromptListChanged()
 }
 
 func (s *Server) RemovePrompt(name string) {
@@ -289,12 +591,10 @@ func (s *Server) RemovePrompt(name string) {
 		changed = true
 	}
 
-	sessions := make([]*ServerSession, len(s.sessions))
-	copy(sessions, s.sessions)
 	s.mu.Unlock()
 
 	if changed {
-		notifyPromptListChanged(sessions)
+		s.notifyPromptListChanged()
 	}
 }
 
@@ -316,14 +616,42 @@ func (s *Server) Run(ctx context.Context, t transport.Transport) error {
 
 	select {
 	case <-ctx.Done():
-		ss.Close()
-		<-ssClosed // Wait for goroutine to finish
+		if s.opts.ShutdownTimeout > 0 {
+			<-ssClosed // The session drains in-flight requests before it ends
+			ss.Close()
+		} else {
+			ss.Close()
+			<-ssClosed // Wait for goroutine to finish
+		}
 		return ctx.Err()
 	case err := <-ssClosed:
 		return err
 	}
 }
 
+// RunMulti runs the server on several transports at once, e.g. STDIO for a local IDE plugin
+// and a network transport for remote agents. Every transport gets its own sessions while
+// sharing the server's tools, resources and prompts.
+//
+// RunMulti blocks until all transports have terminated or the context is cancelled.
+// The first error other than a context error stops the remaining transports and is returned.
+func (s *Server) RunMulti(ctx context.Context, transports ...transport.Transport) error {
+	g, gctx := errgroup.WithContext(ctx)
+	for _, t := range transports {
+		g.Go(func() error {
+			err := s.Run(gctx, t)
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return nil
+			}
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
 // Connect connects the MCP server via the given transport and starts processing messages.
 //
 // It returns a connection object that can be used to terminate the connection (using Close)
@@ -337,12 +665,21 @@ func (s *Server) Connect(ctx context.Context, t transport.Transport, opts *Serve
 	ss := &ServerSession{
 		server:          s,
 		conn:            newConnAdapter(conn),
+		clientID:        transport.ClientIDFromContext(ctx),
+		clientIP:        transport.ClientIPFromContext(ctx),
+		jwtClaims:       transport.JWTClaimsFromContext(ctx),
 		waitErr:         make(chan error, 1),
 		pendingRequests: make(map[string]context.CancelFunc),
+		connectedAt:     time.Now(),
 	}
+	ss.lastActive = ss.connectedAt
 
+	restored := false
 	if opts != nil && opts.State != nil {
 		ss.state = *opts.State
+	} else if restored, err = s.restoreSession(ss); err != nil {
+		conn.Close()
+		return nil, err
 	}
 
 	if opts != nil && opts.onClose != nil {
@@ -353,6 +690,24 @@ func (s *Server) Connect(ctx context.Context, t transport.Transport, opts *Serve
 	s.sessions = append(s.sessions, ss)
 	s.mu.Unlock()
 
+	if s.opts.OnSessionOpen != nil {
+		s.opts.OnSessionOpen(ss)
+	}
+
+	if restored {
+		s.resubscribe(ss)
+		if s.opts.KeepAlive > 0 && ss.state.InitializedParams != nil {
+			ss.startKeepalive(s.opts.KeepAlive)
+		}
+		if s.opts.TokenRotationInterval > 0 && ss.state.InitializedParams != nil {
+			ss.startTokenRotation(s.opts.TokenRotationInterval)
+		}
+	}
+
+	if s.opts.OnConnect != nil {
+		s.opts.OnConnect(ctx, ss)
+	}
+
 	// Start message processing loop
 	go func() {
 		err := s.handleConnection(ctx, ss, ss.conn)
@@ -363,25 +718,37 @@ func (s *Server) Connect(ctx context.Context, t transport.Transport, opts *Serve
 	return ss, nil
 }
 
-func jsonRPCErrorFrom(err error) *protocol.JSONRPCError {
+func (s *Server) jsonRPCErrorFrom(err error) *protocol.JSONRPCError {
 	if err == nil {
 		return nil
 	}
 
 	// Preserve MCP error codes when available.
+	var rpcErr *protocol.JSONRPCError
 	var mcpErr *protocol.MCPError
 	if errors.As(err, &mcpErr) {
-		return &protocol.JSONRPCError{
+		rpcErr = &protocol.JSONRPCError{
 			Code:    mcpErr.Code,
 			Message: mcpErr.Message,
 			Data:    mcpErr.Data,
 		}
+	} else {
+		code := protocol.InternalError
+		var coder MCPErrorCoder
+		if errors.As(err, &coder) {
+			code = coder.MCPErrorCode()
+		}
+		rpcErr = &protocol.JSONRPCError{
+			Code:    code,
+			Message: err.Error(),
+		}
 	}
 
-	return &protocol.JSONRPCError{
-		Code:    protocol.InternalError,
-		Message: err.Error(),
+	// Name registered codes for clients that do not know them
+	if name := s.ErrorCodeName(rpcErr.Code); name != "" && rpcErr.Data == nil {
+		rpcErr.Data = map[string]any{"name": name}
 	}
+	return rpcErr
 }
 
 func relatedTaskMeta(taskID string) map[string]any {
@@ -426,9 +793,9 @@ func isTerminalTaskStatus(status protocol.TaskStatus) bool {
 }
 
 // handleConnection handles the message loop for a connection
-func (s *Server) handleConnection(ctx context.Context, ss *ServerSession, conn Connection) error {
+func (s *Server) handleConnection(ctx context.Context, ss *ServerSession, conn Connection) (err error) {
 	defer func() {
-		s.disconnect(ss)
+		s.disconnect(ss, err)
 		conn.Close()
 	}()
 
@@ -438,6 +805,21 @@ func (s *Server) handleConnection(ctx context.Context, ss *ServerSession, conn C
 		return fmt.Errorf("invalid connection type")
 	}
 
+	// With a shutdown timeout, requests outlive ctx so they can drain once it is cancelled
+	requestCtx := ctx
+	if s.opts.ShutdownTimeout > 0 {
+		var cancelRequests context.CancelFunc
+		requestCtx, cancelRequests = context.WithCancel(context.WithoutCancel(ctx))
+		defer cancelRequests()
+		stop := context.AfterFunc(ctx, func() {
+			drainCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+			defer cancel()
+			_ = ss.waitIdle(drainCtx)
+			cancelRequests()
+		})
+		defer stop()
+	}
+
 	for {
 		// Explicitly check context cancellation
 		select {
@@ -446,10 +828,11 @@ func (s *Server) handleConnection(ctx context.Context, ss *ServerSession, conn C
 		default:
 		}
 
-		msg, err := adapter.conn.Read(ctx)
+		msg, err := adapter.read(ctx)
 		if err != nil {
 			return err
 		}
+		ss.touch()
 
 		// If it's a response message, route to connAdapter
 		if msg.Method == "" && msg.ID != nil {
@@ -457,22 +840,41 @@ func (s *Server) handleConnection(ctx context.Context, ss *ServerSession, conn C
 			continue
 		}
 
-		response := s.handleMessage(ctx, ss, msg)
+		msgCtx := requestCtx
+		if values := msg.Context(); values != nil {
+			msgCtx = transport.MergeContextValues(requestCtx, values)
+		}
+		ss.beginMessage()
+		response := s.handleMessage(msgCtx, ss, msg)
 		if response != nil {
-			if err := adapter.conn.Write(ctx, response); err != nil {
+			if err := adapter.write(requestCtx, response); err != nil {
+				ss.endMessage()
 				return err
 			}
 		}
+		ss.endMessage()
 	}
 }
 
 // handleMessage handles a single JSON-RPC message
 func (s *Server) handleMessage(ctx context.Context, ss *ServerSession, msg *protocol.JSONRPCMessage) *protocol.JSONRPCMessage {
+	if msg.IsBatch() {
+		return s.handleBatch(ctx, ss, msg.Batch)
+	}
+
 	if msg.ID != nil {
 		// Request - needs response
+		if s.shuttingDown.Load() {
+			return &protocol.JSONRPCMessage{
+				JSONRPC: "2.0",
+				ID:      msg.ID,
+				Error:   &protocol.JSONRPCError{Code: protocol.InternalError, Message: "server is shutting down"},
+			}
+		}
+
 		// Create cancellable context and track request
 		requestID := protocol.IDToString(msg.ID)
-		requestCtx, cancel := context.WithCancel(ctx)
+		requestCtx, cancel := context.WithCancel(contextWithRequestID(ctx, requestID))
 
 		ss.mu.Lock()
 		ss.pendingRequests[requestID] = cancel
@@ -491,7 +893,7 @@ func (s *Server) handleMessage(ctx context.Context, ss *ServerSession, msg *prot
 			return &protocol.JSONRPCMessage{
 				JSONRPC: "2.0",
 				ID:      msg.ID,
-				Error:   jsonRPCErrorFrom(err),
+				Error:   s.jsonRPCErrorFrom(err),
 			}
 		}
 
@@ -520,10 +922,28 @@ func (s *Server) handleMessage(ctx context.Context, ss *ServerSession, msg *prot
 	}
 }
 
-func (s *Server) disconnect(ss *ServerSession) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *Server) disconnect(ss *ServerSession, err error) {
+	// Closed connections are a clean teardown, not a failure
+	if errors.Is(err, io.EOF) || errors.Is(err, transport.ErrConnectionClosed) {
+		err = nil
+	}
+	if s.opts.OnDisconnect != nil {
+		s.opts.OnDisconnect(ss, err)
+	}
+	if s.opts.OnSessionClose != nil {
+		s.opts.OnSessionClose(ss, err)
+	}
 
+	ss.mu.Lock()
+	hooks := ss.disconnectHooks
+	ss.disconnectHooks = nil
+	ss.disconnected = true
+	ss.mu.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+
+	s.mu.Lock()
 	for i, session := range s.sessions {
 		if session == ss {
 			s.sessions = append(s.sessions[:i], s.sessions[i+1:]...)
@@ -531,8 +951,17 @@ func (s *Server) disconnect(ss *ServerSession) {
 		}
 	}
 
-	for _, subscribedSessions := range s.resourceSubscriptions {
-		delete(subscribedSessions, ss)
+	var unsubscribed []string
+	for uri, subscribedSessions := range s.resourceSubscriptions {
+		if subscribedSessions[ss] {
+			delete(subscribedSessions, ss)
+			unsubscribed = append(unsubscribed, uri)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, uri := range unsubscribed {
+		s.syncResourceWatch(uri)
 	}
 }
 
@@ -541,28 +970,26 @@ type ServerSessionOptions struct {
 	onClose func()
 }
 
-func notifyToolListChanged(sessions []*ServerSession) {
-	for _, ss := range sessions {
-		_ = ss.conn.SendNotification(context.Background(), protocol.NotificationToolsListChanged, &protocol.ToolListChangedParams{})
-	}
+func (s *Server) notifyToolListChanged() {
+	_ = s.BroadcastNotification(protocol.NotificationToolsListChanged, &protocol.ToolListChangedParams{}, nil)
 }
 
-func notifyResourceListChanged(sessions []*ServerSession) {
-	for _, ss := range sessions {
-		_ = ss.conn.SendNotification(context.Background(), protocol.NotificationResourcesListChanged, &protocol.ResourceListChangedParams{})
-	}
+func (s *Server) notifyResourceListChanged() {
+	_ = s.BroadcastNotification(protocol.NotificationResourcesListChanged, &protocol.ResourceListChangedParams{}, nil)
 }
 
-func notifyPromptListChanged(sessions []*ServerSession) {
-	for _, ss := range sessions {
-		_ = ss.conn.SendNotification(context.Background(), protocol.NotificationPromptsListChanged, &protocol.PromptListChangedParams{})
-	}
+func (s *Server) notifyPromptListChanged() {
+	_ = s.BroadcastNotification(protocol.NotificationPromptsListChanged, &protocol.PromptListChangedParams{}, nil)
 }
 
 // NotifyResourceUpdated notifies all sessions subscribed to the specified resource that it has been updated.
 // Only clients that have previously called resources/subscribe to subscribe to this URI will receive the notification.
 func (s *Server) NotifyResourceUpdated(uri string) {
 	s.mu.Lock()
+	if sr, ok := s.resources[uri]; ok && sr.disabled {
+		s.mu.Unlock()
+		return
+	}
 	subscribedSessions, exists := s.resourceSubscriptions[uri]
 	if !exists || len(subscribedSessions) == 0 {
 		s.mu.Unlock()
@@ -595,6 +1022,13 @@ func (s *Server) handleRequest(ctx context.Context, ss *ServerSession, method st
 	case protocol.MethodToolsList:
 		return s.handleListTools(ctx, ss, params)
 	case protocol.MethodToolsCall:
+		if s.opts.AuditHandler != nil {
+			start := time.Now()
+			ctx := contextWithAuditRecord(ctx)
+			result, err := s.handleCallTool(ctx, ss, params)
+			s.audit(ctx, ss, params, start, result, err)
+			return result, err
+		}
 		return s.handleCallTool(ctx, ss, params)
 	case protocol.MethodResourcesList:
 		return s.handleListResources(ctx, ss, params)
@@ -606,6 +1040,8 @@ func (s *Server) handleRequest(ctx context.Context, ss *ServerSession, method st
 		return s.handleSubscribe(ctx, ss, params)
 	case protocol.MethodResourcesUnsubscribe:
 		return s.handleUnsubscribe(ctx, ss, params)
+	case protocol.MethodResourcesWrite:
+		return s.handleWriteResource(ctx, ss, params)
 	case protocol.MethodPromptsList:
 		return s.handleListPrompts(ctx, ss, params)
 	case protocol.MethodPromptsGet:
@@ -667,18 +1103,24 @@ func (s *Server) handleInitialize(ctx context.Context, ss *ServerSession, params
 		return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid params", map[string]any{"method": protocol.MethodInitialize})
 	}
 
-	// Determine the protocol version to use
-	// If client version is supported, use it; otherwise use server's latest version
-	negotiatedVersion := req.ProtocolVersion
-	if !protocol.IsVersionSupported(req.ProtocolVersion) {
-		// Log warning but don't reject - use server's latest version instead
-		log.Printf("[MCP] Warning: client requested unsupported protocol version: %s, using server version: %s",
-			req.ProtocolVersion, protocol.MCPVersion)
-		negotiatedVersion = protocol.MCPVersion
+	// Use the latest version supported by both sides
+	if floor := s.opts.MinProtocolVersion; floor != "" && !protocol.ProtocolVersionAtLeast(req.ProtocolVersion, floor) {
+		var supported []string
+		for _, v := range protocol.GetSupportedVersions() {
+			if protocol.ProtocolVersionAtLeast(v, floor) {
+				supported = append(supported, v)
+			}
+		}
+		return nil, protocol.NewMCPError(protocol.InvalidParams, "Unsupported protocol version", map[string]any{
+			"supported": supported,
+			"requested": req.ProtocolVersion,
+		})
 	}
+	negotiatedVersion := protocol.NegotiateProtocolVersion(req.ProtocolVersion)
 
 	ss.updateState(func(state *ServerSessionState) {
 		state.InitializeParams = &req
+		state.ProtocolVersion = negotiatedVersion
 	})
 
 	capabilities := protocol.ServerCapabilities{}
@@ -687,7 +1129,8 @@ func (s *Server) handleInitialize(ctx context.Context, ss *ServerSession, params
 	hasTools := len(s.tools) > 0
 	hasResources := len(s.resources) > 0 || len(s.resourceTemplates) > 0
 	hasPrompts := len(s.prompts) > 0
-	subscribeSupported := s.opts.SubscribeHandler != nil && s.opts.UnsubscribeHandler != nil
+	subscribeSupported := s.subscribeSupportedLocked()
+	writable := s.writableLocked()
 
 	if hasTools {
 		capabilities.Tools = &protocol.ToolsCapability{ListChanged: true}
@@ -696,16 +1139,18 @@ func (s *Server) handleInitialize(ctx context.Context, ss *ServerSession, params
 		capabilities.Resources = &protocol.ResourcesCapability{
 			ListChanged: true,
 			Subscribe:   subscribeSupported,
+			Writable:    writable,
 		}
 	}
 	if hasPrompts {
 		capabilities.Prompts = &protocol.PromptsCapability{ListChanged: true}
 	}
+	hasCompletions := len(s.completions) > 0
 	s.mu.Unlock()
 
 	capabilities.Logging = &protocol.LoggingCapability{}
 
-	if s.opts.CompletionHandler != nil {
+	if s.opts.CompletionHandler != nil || hasCompletions {
 		capabilities.Completion = &protocol.CompletionCapability{}
 	}
 
@@ -742,11 +1187,18 @@ func (s *Server) handleInitialized(ctx context.Context, ss *ServerSession, param
 	ss.updateState(func(state *ServerSessionState) {
 		state.InitializedParams = &req
 	})
+	if err := s.persistSession(ss); err != nil {
+		return err
+	}
 
 	// Start keepalive
 	if s.opts.KeepAlive > 0 {
 		ss.startKeepalive(s.opts.KeepAlive)
 	}
+	// Sessions of HandleMessage end with the message, leaving no connection to rotate on
+	if s.opts.TokenRotationInterval > 0 && !ss.transient {
+		ss.startTokenRotation(s.opts.TokenRotationInterval)
+	}
 
 	if s.opts.InitializedHandler != nil {
 		s.opts.InitializedHandler(ctx, ss)
@@ -757,19 +1209,51 @@ func (s *Server) handleInitialized(ctx context.Context, ss *ServerSession, param
 
 // handleListTools handles the tools/list request
 func (s *Server) handleListTools(ctx context.Context, ss *ServerSession, params json.RawMessage) (*protocol.ListToolsResult, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	var req protocol.ListToolsParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid params", map[string]any{"method": protocol.MethodToolsList})
+		}
+	}
 
+	s.mu.Lock()
 	tools := make([]protocol.Tool, 0, len(s.tools))
 	for _, st := range s.tools {
-		tools = append(tools, *st.tool)
+		if !st.disabled {
+			tools = append(tools, *st.tool)
+			continue
+		}
+		if !req.IncludeDisabled {
+			continue
+		}
+		tool := *st.tool
+		tool.Meta = mergeMap(map[string]any{}, tool.Meta)
+		tool.Meta["disabled"] = true
+		if st.disabledReason != "" {
+			tool.Meta["disabledReason"] = st.disabledReason
+		}
+		tools = append(tools, tool)
+	}
+	s.mu.Unlock()
+
+	page, next, ok := paginate(tools, toolListKey, req.Cursor, s.opts.ToolsPageSize)
+	if !ok {
+		return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid cursor", map[string]any{"cursor": req.Cursor})
 	}
 
 	return &protocol.ListToolsResult{
-		Tools: tools,
+		Tools:           page,
+		PaginatedResult: protocol.PaginatedResult{NextCursor: next},
 	}, nil
 }
 
+// toolListKey orders tools/list by descending priority, then by name.
+// Priorities are clamped to [0, 1] and formatted with a fixed width so the keys sort lexically.
+func toolListKey(t protocol.Tool) string {
+	priority := min(max(t.Priority, 0), 1)
+	return strconv.FormatFloat(1-priority, 'f', 6, 64) + "/" + t.Name
+}
+
 // handleCallTool handles the tools/call request
 func (s *Server) handleCallTool(ctx context.Context, ss *ServerSession, params json.RawMessage) (interface{}, error) {
 	var req protocol.CallToolParams
@@ -779,10 +1263,31 @@ func (s *Server) handleCallTool(ctx context.Context, ss *ServerSession, params j
 
 	s.mu.Lock()
 	st, exists := s.tools[req.Name]
+	var disabled bool
+	var disabledReason string
+	if exists {
+		disabled, disabledReason = st.disabled, st.disabledReason
+	}
 	s.mu.Unlock()
 
 	if !exists {
-		return nil, protocol.NewMCPError(protocol.InvalidParams, fmt.Sprintf("Unknown tool: %s", req.Name), nil)
+		return nil, fmt.Errorf("%w: %s", ErrToolNotFound, req.Name)
+	}
+	if policy := s.opts.RBACPolicy; policy != nil && !policy.AllowTool(rbacContext(ctx, ss), rbacSessionID(ss), req.Name) {
+		return accessDeniedResult(), nil
+	}
+	if disabled {
+		msg := "tool is temporarily disabled"
+		if disabledReason != "" {
+			msg += ": " + disabledReason
+		}
+		return protocol.NewToolResultError(msg), nil
+	}
+	if req.DryRun {
+		return dryRunTool(st, req.Arguments), nil
+	}
+	if result, err := s.checkToolCallQuota(ss, req.Name); result != nil || err != nil {
+		return result, err
 	}
 
 	var taskSupport protocol.TaskSupport
@@ -841,10 +1346,15 @@ func (s *Server) handleCallTool(ctx context.Context, ss *ServerSession, params j
 			Session: ss,
 			Params:  &req,
 		}
+		taskCtx = contextWithProgressReporter(taskCtx, toolReq.newProgressReporter(taskCtx))
+		taskCtx = contextWithLogger(taskCtx, toolReq.newLogger(taskCtx))
 
 		go func() {
 			defer cancel()
-			result, err := st.handler(taskCtx, toolReq)
+			result, err := s.callTool(taskCtx, st, toolReq)
+			if err == nil {
+				result, err = s.checkOutputSchema(st.tool, result)
+			}
 
 			s.mu.Lock()
 			stored := s.tasks[taskID]
@@ -865,7 +1375,7 @@ func (s *Server) handleCallTool(ctx context.Context, ss *ServerSession, params j
 			}
 
 			if err != nil {
-				stored.rpcError = jsonRPCErrorFrom(err)
+				stored.rpcError = s.jsonRPCErrorFrom(err)
 				stored.result = nil
 				stored.task.Status = protocol.TaskStatusFailed
 				stored.task.StatusMessage = err.Error()
@@ -920,22 +1430,132 @@ func (s *Server) handleCallTool(ctx context.Context, ss *ServerSession, params j
 		Session: ss,
 		Params:  &req,
 	}
+	ctx = contextWithProgressReporter(ctx, toolReq.newProgressReporter(ctx))
+	ctx = contextWithLogger(ctx, toolReq.newLogger(ctx))
+
+	return s.callToolIdempotent(ctx, ss, &req, func() (*protocol.CallToolResult, error) {
+		result, err := s.callTool(ctx, st, toolReq)
+		if err != nil {
+			return nil, err
+		}
+		return s.checkOutputSchema(st.tool, result)
+	})
+}
+
+// callTool invokes the tool handler, enforcing the per-tool timeout if configured
+func (s *Server) callTool(ctx context.Context, st *serverTool, req *CallToolRequest) (*protocol.CallToolResult, error) {
+	// Wait for the tool's own limit first so that queued calls do not hold server-wide slots
+	if !acquireSlot(ctx, st.slots) {
+		return protocol.NewToolResultError("server is at capacity, try again later"), nil
+	}
+	defer releaseSlot(st.slots)
+	if !acquireSlot(ctx, s.toolSlots) {
+		return protocol.NewToolResultError("server is at capacity, try again later"), nil
+	}
+	defer releaseSlot(s.toolSlots)
+
+	if st.opts.Timeout <= 0 {
+		return st.handler(ctx, req)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, st.opts.Timeout)
+	defer cancel()
 
-	return st.handler(ctx, toolReq)
+	type callResult struct {
+		result *protocol.CallToolResult
+		err    error
+	}
+	resultCh := make(chan callResult, 1)
+
+	go func() {
+		result, err := st.handler(timeoutCtx, req)
+		resultCh <- callResult{result, err}
+	}()
+
+	select {
+	case res := <-resultCh:
+		return res.result, res.err
+	case <-timeoutCtx.Done():
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return protocol.NewToolResultError(fmt.Sprintf("tool execution timed out after %v", st.opts.Timeout)), nil
+	}
+}
+
+// acquireSlot takes a slot of the semaphore sem, waiting until one is free or ctx ends.
+// A nil sem imposes no limit.
+func acquireSlot(ctx context.Context, sem chan struct{}) bool {
+	if sem == nil {
+		return true
+	}
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func releaseSlot(sem chan struct{}) {
+	if sem != nil {
+		<-sem
+	}
+}
+
+// checkOutputSchema checks the structured content of result against the tool's output schema.
+// A violation fails the request when ValidateStructuredOutput is set, and otherwise replaces result
+// with a tool error unless SkipOutputSchemaValidation is set.
+func (s *Server) checkOutputSchema(tool *protocol.Tool, result *protocol.CallToolResult) (*protocol.CallToolResult, error) {
+	if !s.opts.ValidateStructuredOutput && s.opts.SkipOutputSchemaValidation {
+		return result, nil
+	}
+	if result == nil || result.IsError {
+		return result, nil
+	}
+
+	err := validateStructuredContent(result.StructuredContent, tool.OutputSchema)
+	if err == nil {
+		return result, nil
+	}
+	violations := schemaViolations(err)
+	message := "tool returned structured content that violates its declared output schema: " + strings.Join(violations, "; ")
+
+	if s.opts.ValidateStructuredOutput {
+		return nil, protocol.NewMCPError(protocol.InvalidTool, message, map[string]any{
+			"tool":       tool.Name,
+			"violations": violations,
+		})
+	}
+	return protocol.NewToolResultError(message), nil
 }
 
 // handleListResources handles the resources/list request
 func (s *Server) handleListResources(ctx context.Context, ss *ServerSession, params json.RawMessage) (*protocol.ListResourcesResult, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	var req protocol.ListResourcesParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid params", map[string]any{"method": protocol.MethodResourcesList})
+		}
+	}
 
+	s.mu.Lock()
 	resources := make([]protocol.Resource, 0, len(s.resources))
 	for _, sr := range s.resources {
-		resources = append(resources, *sr.resource)
+		if !sr.disabled {
+			resources = append(resources, *sr.resource)
+		}
+	}
+	s.mu.Unlock()
+
+	page, next, ok := paginate(resources, func(r protocol.Resource) string { return r.URI }, req.Cursor, s.opts.ResourcesPageSize)
+	if !ok {
+		return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid cursor", map[string]any{"cursor": req.Cursor})
 	}
 
 	return &protocol.ListResourcesResult{
-		Resources: resources,
+		Resources:       page,
+		PaginatedResult: protocol.PaginatedResult{NextCursor: next},
 	}, nil
 }
 
@@ -962,11 +1582,39 @@ func (s *Server) handleReadResource(ctx context.Context, ss *ServerSession, para
 	}
 
 	s.mu.Lock()
-	sr, exists := s.resources[req.URI]
+	var handler ResourceHandler
+	var etag string
+	var versioned bool
+	registered := req.URI // registered URI or URI template, so metrics have bounded labels
+	if sr, exists := s.resources[req.URI]; exists {
+		if !sr.disabled {
+			handler, etag, versioned = sr.handler, sr.etag, sr.versioned
+		}
+	} else if srt, vars := s.matchResourceTemplate(req.URI); srt != nil {
+		handler = srt.handler
+		registered = srt.template.URITemplate
+		ctx = contextWithTemplateVars(ctx, vars)
+	}
+	observe := s.resourceReadObserver
+	middlewares := s.resourceMiddlewares
 	s.mu.Unlock()
 
-	if !exists {
-		return nil, protocol.NewMCPError(protocol.ResourceNotFound, "resource not found", map[string]any{"uri": req.URI})
+	if handler == nil {
+		return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, req.URI)
+	}
+	if policy := s.opts.RBACPolicy; policy != nil && !policy.AllowResource(rbacContext(ctx, ss), rbacSessionID(ss), req.URI) {
+		return nil, accessDeniedError("uri", req.URI)
+	}
+	handler = applyResourceMiddleware(handler, middlewares)
+	// A known ETag answers a conditional read without running the handler
+	if etag != "" && req.IfNoneMatch == etag && req.Version == "" {
+		return notModifiedResult(), nil
+	}
+	if req.Version != "" && !versioned {
+		if result, ok := s.historicResourceVersion(req.URI, req.Version); ok {
+			tagContents(result.Contents, "")
+			return result, nil
+		}
 	}
 
 	resourceReq := &ReadResourceRequest{
@@ -974,12 +1622,54 @@ func (s *Server) handleReadResource(ctx context.Context, ss *ServerSession, para
 		Params:  &req,
 	}
 
-	return sr.handler(ctx, resourceReq)
+	result, err := handler(ctx, resourceReq)
+	if observe != nil {
+		observe(registered, err)
+	}
+	if err != nil || result == nil {
+		return result, err
+	}
+	s.recordResourceVersion(req.URI, result)
+	if req.Version != "" && !versioned && contentsVersion(result.Contents) != req.Version {
+		// The handler only serves the current version
+		return nil, unknownVersionError(req.URI, req.Version, result.AvailableVersions)
+	}
+	if req.Version != "" {
+		etag = ""
+	}
+	if etag = tagContents(result.Contents, etag); req.IfNoneMatch != "" && req.IfNoneMatch == etag {
+		return notModifiedResult(), nil
+	}
+	return result, nil
+}
+
+// matchResourceTemplate finds the template matching uri, preferring the one with the most
+// literal characters when several match; s.mu must be held
+func (s *Server) matchResourceTemplate(uri string) (*serverResourceTemplate, map[string]string) {
+	var best *serverResourceTemplate
+	var bestVars map[string]string
+	for _, srt := range s.resourceTemplates {
+		if srt.matcher == nil {
+			continue
+		}
+		vars, ok := srt.matcher.match(uri)
+		if !ok {
+			continue
+		}
+		if best == nil || srt.matcher.literals > best.matcher.literals ||
+			(srt.matcher.literals == best.matcher.literals && srt.template.URITemplate < best.template.URITemplate) {
+			best, bestVars = srt, vars
+		}
+	}
+	return best, bestVars
 }
 
 // handleSubscribe handles the resources/subscribe request
 func (s *Server) handleSubscribe(ctx context.Context, ss *ServerSession, params json.RawMessage) (*protocol.EmptyResult, error) {
-	if s.opts.SubscribeHandler == nil || s.opts.UnsubscribeHandler == nil {
+	s.mu.Lock()
+	supported := s.subscribeSupportedLocked()
+	s.mu.Unlock()
+	if !supported {
 		return nil, protocol.NewMCPError(protocol.MethodNotFound, "Method not found", map[string]any{"method": protocol.MethodResourcesSubscribe})
 	}
 
@@ -988,8 +1678,10 @@ func (s *Server) handleSubscribe(ctx context.Context, ss *ServerSession, params
 		return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid params", map[string]any{"method": protocol.MethodResourcesSubscribe})
 	}
 
-	if err := s.opts.SubscribeHandler(ctx, &req); err != nil {
-		return nil, err
+	if s.opts.SubscribeHandler != nil {
+		if err := s.opts.SubscribeHandler(ctx, &req); err != nil {
+			return nil, err
+		}
 	}
 
 	s.mu.Lock()
@@ -999,12 +1691,31 @@ func (s *Server) handleSubscribe(ctx context.Context, ss *ServerSession, params
 	s.resourceSubscriptions[req.URI][ss] = true
 	s.mu.Unlock()
 
+	if err := s.syncResourceWatch(req.URI); err != nil {
+		s.mu.Lock()
+		delete(s.resourceSubscriptions[req.URI], ss)
+		s.mu.Unlock()
+		return nil, protocol.NewMCPError(protocol.InternalError, fmt.Sprintf("failed to watch resource: %v", err), map[string]any{"uri": req.URI})
+	}
+
+	ss.updateState(func(state *ServerSessionState) {
+		if !slices.Contains(state.Subscriptions, req.URI) {
+			state.Subscriptions = append(state.Subscriptions, req.URI)
+		}
+	})
+	if err := s.persistSession(ss); err != nil {
+		return nil, err
+	}
+
 	return &protocol.EmptyResult{}, nil
 }
 
 // handleUnsubscribe handles the resources/unsubscribe request
 func (s *Server) handleUnsubscribe(ctx context.Context, ss *ServerSession, params json.RawMessage) (*protocol.EmptyResult, error) {
-	if s.opts.SubscribeHandler == nil || s.opts.UnsubscribeHandler == nil {
+	s.mu.Lock()
+	supported := s.subscribeSupportedLocked()
+	s.mu.Unlock()
+	if !supported {
 		return nil, protocol.NewMCPError(protocol.MethodNotFound, "Method not found", map[string]any{"method": protocol.MethodResourcesUnsubscribe})
 	}
 
@@ -1013,8 +1724,10 @@ func (s *Server) handleUnsubscribe(ctx context.Context, ss *ServerSession, param
 		return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid params", map[string]any{"method": protocol.MethodResourcesUnsubscribe})
 	}
 
-	if err := s.opts.UnsubscribeHandler(ctx, &req); err != nil {
-		return nil, err
+	if s.opts.UnsubscribeHandler != nil {
+		if err := s.opts.UnsubscribeHandler(ctx, &req); err != nil {
+			return nil, err
+		}
 	}
 
 	s.mu.Lock()
@@ -1022,22 +1735,42 @@ func (s *Server) handleUnsubscribe(ctx context.Context, ss *ServerSession, param
 		delete(s.resourceSubscriptions[req.URI], ss)
 	}
 	s.mu.Unlock()
+	s.syncResourceWatch(req.URI)
+
+	ss.updateState(func(state *ServerSessionState) {
+		state.Subscriptions = slices.DeleteFunc(state.Subscriptions, func(uri string) bool { return uri == req.URI })
+	})
+	if err := s.persistSession(ss); err != nil {
+		return nil, err
+	}
 
 	return &protocol.EmptyResult{}, nil
 }
 
 // handleListPrompts handles the prompts/list request
 func (s *Server) handleListPrompts(ctx context.Context, ss *ServerSession, params json.RawMessage) (*protocol.ListPromptsResult, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	var req protocol.ListPromptsParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid params", map[string]any{"method": protocol.MethodPromptsList})
+		}
+	}
 
+	s.mu.Lock()
 	prompts := make([]protocol.Prompt, 0, len(s.prompts))
 	for _, sp := range s.prompts {
 		prompts = append(prompts, *sp.prompt)
 	}
+	s.mu.Unlock()
+
+	page, next, ok := paginate(prompts, func(p protocol.Prompt) string { return p.Name }, req.Cursor, s.opts.PromptsPageSize)
+	if !ok {
+		return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid cursor", map[string]any{"cursor": req.Cursor})
+	}
 
 	return &protocol.ListPromptsResult{
-		Prompts: prompts,
+		Prompts:         page,
+		PaginatedResult: protocol.PaginatedResult{NextCursor: next},
 	}, nil
 }
 
@@ -1053,8 +1786,13 @@ func (s *Server) handleGetPrompt(ctx context.Context, ss *ServerSession, params
 	s.mu.Unlock()
 
 	if !exists {
-		return nil, protocol.NewMCPError(protocol.PromptNotFound, "prompt not found", map[string]any{"name": req.Name})
+		return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, req.Name)
 	}
+	if policy := s.opts.RBACPolicy; policy != nil && !policy.AllowPrompt(rbacContext(ctx, ss), rbacSessionID(ss), req.Name) {
+		return nil, accessDeniedError("prompt", req.Name)
+	}
+
+	applyPromptDefaults(sp.prompt, &req)
 
 	promptReq := &GetPromptRequest{
 		Session: ss,
@@ -1064,9 +1802,29 @@ func (s *Server) handleGetPrompt(ctx context.Context, ss *ServerSession, params
 	return sp.handler(ctx, promptReq)
 }
 
+// applyPromptDefaults fills in omitted optional arguments that declare a default,
+// so handlers see the same values regardless of what the client sent
+func applyPromptDefaults(prompt *protocol.Prompt, req *protocol.GetPromptParams) {
+	for _, arg := range prompt.Arguments {
+		if arg.Required || arg.Default == "" {
+			continue
+		}
+		if _, ok := req.Arguments[arg.Name]; ok {
+			continue
+		}
+		if req.Arguments == nil {
+			req.Arguments = make(map[string]string)
+		}
+		req.Arguments[arg.Name] = arg.Default
+	}
+}
+
 // handleComplete handles the completion/complete request
 func (s *Server) handleComplete(ctx context.Context, ss *ServerSession, params json.RawMessage) (*protocol.CompleteResult, error) {
-	if s.opts.CompletionHandler == nil {
+	s.mu.Lock()
+	hasCompletions := len(s.completions) > 0
+	s.mu.Unlock()
+	if s.opts.CompletionHandler == nil && !hasCompletions {
 		return nil, protocol.NewMCPError(protocol.MethodNotFound, "Method not found", map[string]any{"method": protocol.MethodCompletionComplete})
 	}
 
@@ -1075,6 +1833,25 @@ func (s *Server) handleComplete(ctx context.Context, ss *ServerSession, params j
 		return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid params", map[string]any{"method": protocol.MethodCompletionComplete})
 	}
 
+	// Per-tool/prompt completions take precedence over the global handler
+	fn, err := s.lookupCompletion(&req)
+	if err != nil {
+		return nil, err
+	}
+	if fn != nil {
+		values, hasMore, err := fn(ctx, req.Argument.Value)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = []string{}
+		}
+		return &protocol.CompleteResult{Completion: protocol.NewCompletionResult(values, hasMore)}, nil
+	}
+
+	if s.opts.CompletionHandler == nil {
+		return &protocol.CompleteResult{Completion: protocol.NewCompletionResult([]string{}, false)}, nil
+	}
 	return s.opts.CompletionHandler(ctx, &req)
 }
 
@@ -1155,6 +1932,9 @@ func (s *Server) handleSetLoggingLevel(ctx context.Context, ss *ServerSession, p
 	ss.updateState(func(state *ServerSessionState) {
 		state.LogLevel = req.Level
 	})
+	if err := s.persistSession(ss); err != nil {
+		return nil, err
+	}
 
 	if s.opts.LoggingSetLevelHandler != nil {
 		if err := s.opts.LoggingSetLevelHandler(ctx, ss, req.Level); err != nil {
@@ -1171,8 +1951,26 @@ func (s *Server) HandleMessage(ctx context.Context, msg *protocol.JSONRPCMessage
 	ss := &ServerSession{
 		server:          s,
 		conn:            nil, // SSE does not use connection
+		transient:       true,
+		clientID:        transport.ClientIDFromContext(ctx),
+		clientIP:        transport.ClientIPFromContext(ctx),
+		jwtClaims:       transport.JWTClaimsFromContext(ctx),
 		pendingRequests: make(map[string]context.CancelFunc),
 	}
+	if send, ok := ctx.Value(ctxKeyNotificationSender{}).(NotificationSender); ok && send != nil {
+		ss.conn = senderConn{send: send}
+	}
+
+	ss.beginMessage()
+	defer ss.endMessage()
+	s.mu.Lock()
+	s.transientSessions[ss] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.transientSessions, ss)
+		s.mu.Unlock()
+	}()
 
 	// Handle message
 	response := s.handleMessage(ctx, ss, msg)
@@ -1454,14 +2252,5 @@ func (s *Server) NotifyTaskStatus(task *protocol.Task) {
 	params := &protocol.TaskStatusNotificationParams{
 		Task: *task,
 	}
-	s.mu.Lock()
-	sessions := make([]*ServerSession, len(s.sessions))
-	copy(sessions, s.sessions)
-	s.mu.Unlock()
-
-	for _, ss := range sessions {
-		if ss.conn != nil {
-			_ = ss.conn.SendNotification(context.Background(), protocol.NotificationTasksStatus, params)
-		}
-	}
+	_ = s.BroadcastNotification(protocol.NotificationTasksStatus, params, nil)
 }
@@ -9,6 +9,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/voocel/mcp-sdk-go/protocol"
 	"github.com/voocel/mcp-sdk-go/transport"
 )
@@ -18,16 +19,30 @@ type ServerSession struct {
 	calledOnClose atomic.Bool
 	onClose       func()
 
-	server *Server
-	conn   Connection // Underlying connection (from transport)
+	server    *Server
+	conn      Connection // Underlying connection (from transport)
+	transient bool       // Created by Server.HandleMessage for a single message
+	clientID  string     // Authenticated client identity from the transport, if any
+	clientIP  string     // Remote address of the client for HTTP transports, if known
+
+	jwtClaims jwt.MapClaims // Claims validated by transport.NewJWTMiddleware when the session was created
+
+	connectedAt time.Time
 
 	// keepalive
 	keepaliveCancel context.CancelFunc
 
-	mu              sync.Mutex
-	state           ServerSessionState
-	waitErr         chan error
-	pendingRequests map[string]context.CancelFunc // Track pending requests for cancellation
+	mu                  sync.Mutex
+	disconnectHooks     []func()           // run by Server.disconnect, see onDisconnect
+	disconnected        bool               // set by Server.disconnect
+	tokens              sessionTokens      // issued by RotateToken
+	tokenRotationCancel context.CancelFunc // stops the rotation started for TokenRotationInterval
+	state               ServerSessionState
+	waitErr             chan error
+	pendingRequests     map[string]context.CancelFunc // Track pending requests for cancellation
+	inFlight            int                           // messages being handled, see beginMessage
+	requestDone         chan struct{}                 // closed when an in-flight message ends, see waitIdle
+	lastActive          time.Time                     // time of the last message received
 }
 
 // ServerSessionState represents session state
@@ -35,11 +50,24 @@ type ServerSessionState struct {
 	// InitializeParams are the parameters from the initialize request
 	InitializeParams *protocol.InitializeParams
 
+	// ProtocolVersion is the protocol version negotiated during initialization
+	ProtocolVersion string
+
 	// InitializedParams are the parameters from notifications/initialized
 	InitializedParams *protocol.InitializedParams
 
 	// LogLevel is the logging level
 	LogLevel protocol.LoggingLevel
+
+	// Subscriptions are the URIs of the resources the client subscribed to
+	Subscriptions []string
+
+	// ToolCallUsage counts tool calls against ServerOptions.ToolCallQuotaFn
+	ToolCallUsage ToolCallUsage
+
+	// ClientID is the authenticated client that owns the session, see
+	// transport.ClientIDFromContext. Persisted state is only restored for the same client.
+	ClientID string
 }
 
 // Connection represents the underlying transport connection
@@ -55,6 +83,49 @@ type Connection interface {
 	SessionID() string
 }
 
+// ClientID returns the client identity established by transport authentication
+// (see transport.NewAPIKeyMiddleware), or "" if the session is unauthenticated
+func (ss *ServerSession) ClientID() string {
+	return ss.clientID
+}
+
+// JWTClaims returns the JWT claims validated by transport.NewJWTMiddleware.
+// Claims of the current HTTP request in ctx take precedence over those captured
+// when the session was established; nil means the session is not JWT-authenticated.
+func (ss *ServerSession) JWTClaims(ctx context.Context) jwt.MapClaims {
+	if claims := transport.JWTClaimsFromContext(ctx); claims != nil {
+		return claims
+	}
+	return ss.jwtClaims
+}
+
+// ClientIP returns the client's IP address as seen by an HTTP transport, or "" if unknown
+func (ss *ServerSession) ClientIP() string {
+	return ss.clientIP
+}
+
+// noMessages are the counters of sessions without a connection of their own
+var noMessages transport.MessageCounter
+
+func (ss *ServerSession) messageCounter() *transport.MessageCounter {
+	if adapter, ok := ss.conn.(*connAdapter); ok {
+		return &adapter.counters
+	}
+	return &noMessages
+}
+
+// BytesRead returns the size of the messages received on the session, encoded as JSON
+func (ss *ServerSession) BytesRead() int64 { return ss.messageCounter().BytesRead() }
+
+// BytesWritten returns the size of the messages sent on the session, encoded as JSON
+func (ss *ServerSession) BytesWritten() int64 { return ss.messageCounter().BytesWritten() }
+
+// MessagesRead returns the number of messages received on the session
+func (ss *ServerSession) MessagesRead() int64 { return ss.messageCounter().MessagesRead() }
+
+// MessagesWritten returns the number of messages sent on the session
+func (ss *ServerSession) MessagesWritten() int64 { return ss.messageCounter().MessagesWritten() }
+
 func (ss *ServerSession) ID() string {
 	if ss.conn != nil {
 		return ss.conn.SessionID()
@@ -76,16 +147,14 @@ func (ss *ServerSession) Close() error {
 	if ss.keepaliveCancel != nil {
 		ss.keepaliveCancel()
 	}
-
-	// Cancel all pending requests
 	ss.mu.Lock()
-	pendingRequests := ss.pendingRequests
-	ss.pendingRequests = make(map[string]context.CancelFunc)
+	if ss.tokenRotationCancel != nil {
+		ss.tokenRotationCancel()
+	}
 	ss.mu.Unlock()
 
-	for _, cancel := range pendingRequests {
-		cancel()
-	}
+	// Cancel all pending requests
+	ss.cancelPending()
 
 	if ss.calledOnClose.CompareAndSwap(false, true) {
 		if ss.onClose != nil {
@@ -98,6 +167,20 @@ func (ss *ServerSession) Close() error {
 	return nil
 }
 
+// onDisconnect registers fn to run once the session has disconnected, to release
+// per-session state held elsewhere. It returns false, without registering fn, if the
+// session has already disconnected.
+func (ss *ServerSession) onDisconnect(fn func()) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.disconnected {
+		return false
+	}
+	ss.disconnectHooks = append(ss.disconnectHooks, fn)
+	return true
+}
+
 // Wait waits for the session to end and returns the error that caused it to end
 func (ss *ServerSession) Wait() error {
 	if ss.waitErr == nil {
@@ -106,6 +189,60 @@ func (ss *ServerSession) Wait() error {
 	return <-ss.waitErr
 }
 
+// beginMessage marks a message as in flight until endMessage, which is called once its
+// response has been written, so that waitIdle never lets Shutdown close the connection
+// before the response is sent
+func (ss *ServerSession) beginMessage() {
+	ss.mu.Lock()
+	ss.inFlight++
+	ss.mu.Unlock()
+}
+
+// endMessage ends a message started with beginMessage
+func (ss *ServerSession) endMessage() {
+	ss.mu.Lock()
+	ss.inFlight--
+	if ss.requestDone != nil {
+		close(ss.requestDone)
+		ss.requestDone = nil
+	}
+	ss.mu.Unlock()
+}
+
+// waitIdle waits until the session has no messages in flight or ctx is done
+func (ss *ServerSession) waitIdle(ctx context.Context) error {
+	for {
+		ss.mu.Lock()
+		if ss.inFlight == 0 {
+			ss.mu.Unlock()
+			return nil
+		}
+		if ss.requestDone == nil {
+			ss.requestDone = make(chan struct{})
+		}
+		done := ss.requestDone
+		ss.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+		}
+	}
+}
+
+// cancelPending cancels the contexts of all pending requests
+func (ss *ServerSession) cancelPending() {
+	ss.mu.Lock()
+	pendingRequests := ss.pendingRequests
+	ss.pendingRequests = make(map[string]context.CancelFunc)
+	ss.mu.Unlock()
+
+	for _, cancel := range pendingRequests {
+		cancel()
+	}
+}
+
 // updateState updates the session state
 func (ss *ServerSession) updateState(mut func(*ServerSessionState)) {
 	ss.mu.Lock()
@@ -187,8 +324,16 @@ func (ss *ServerSession) CreateMessage(ctx context.Context, params *protocol.Cre
 	return &result, err
 }
 
-// Elicit sends an elicitation request to the client, requesting user input
+// Elicit sends an elicitation request to the client, requesting user input.
+// ServerOptions.ElicitationTimeout, if set, bounds the wait as in ElicitWithTimeout.
 func (ss *ServerSession) Elicit(ctx context.Context, params *protocol.ElicitationCreateParams) (*protocol.ElicitationResult, error) {
+	if ss.server != nil && ss.server.opts.ElicitationTimeout > 0 {
+		return ss.ElicitWithTimeout(ctx, params, ss.server.opts.ElicitationTimeout)
+	}
+	return ss.elicit(ctx, params)
+}
+
+func (ss *ServerSession) elicit(ctx context.Context, params *protocol.ElicitationCreateParams) (*protocol.ElicitationResult, error) {
 	var result protocol.ElicitationResult
 	sendParams := any(params)
 	if params != nil {
@@ -209,6 +354,14 @@ func (ss *ServerSession) Elicit(ctx context.Context, params *protocol.Elicitatio
 	return &result, err
 }
 
+// ProtocolVersion returns the protocol version negotiated with the client, or "" before initialization.
+// Use protocol.ProtocolVersionAtLeast to gate features introduced by later revisions.
+func (ss *ServerSession) ProtocolVersion() string {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.state.ProtocolVersion
+}
+
 // InitializeParams returns the initialization parameters
 func (ss *ServerSession) InitializeParams() *protocol.InitializeParams {
 	ss.mu.Lock()
@@ -225,6 +378,14 @@ type CallToolRequest struct {
 	Params *protocol.CallToolParams
 }
 
+// Meta returns the _meta object the client sent with the request, e.g. trace or correlation IDs
+func (r *CallToolRequest) Meta() map[string]any {
+	if r == nil || r.Params == nil {
+		return nil
+	}
+	return r.Params.Meta
+}
+
 // ToolHandler is a tool handler function.
 // It receives a CallToolRequest and can send notifications via req.Session.
 type ToolHandler func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error)
@@ -240,7 +401,8 @@ type pendingRequest struct {
 
 // connAdapter adapts transport.Connection to server.Connection
 type connAdapter struct {
-	conn transport.Connection
+	conn     transport.Connection
+	counters transport.MessageCounter
 
 	mu      sync.Mutex
 	pending map[string]*pendingRequest
@@ -266,7 +428,7 @@ func (a *connAdapter) SendNotification(ctx context.Context, method string, param
 		Params:  json.RawMessage(paramsBytes),
 	}
 
-	return a.conn.Write(ctx, msg)
+	return a.write(ctx, msg)
 }
 
 func (a *connAdapter) SendRequest(ctx context.Context, method string, params interface{}, result interface{}) error {
@@ -300,7 +462,7 @@ func (a *connAdapter) SendRequest(ctx context.Context, method string, params int
 	a.pending[id] = pending
 	a.mu.Unlock()
 
-	if err := a.conn.Write(ctx, msg); err != nil {
+	if err := a.write(ctx, msg); err != nil {
 		a.mu.Lock()
 		delete(a.pending, id)
 		a.mu.Unlock()
@@ -317,7 +479,7 @@ func (a *connAdapter) SendRequest(ctx context.Context, method string, params int
 		return err
 	case resp := <-pending.response:
 		if resp.Error != nil {
-			return fmt.Errorf("RPC error %d: %s", resp.Error.Code, resp.Error.Message)
+			return fmt.Errorf("RPC error %d: %w", resp.Error.Code, protocol.NewMCPError(resp.Error.Code, resp.Error.Message, resp.Error.Data))
 		}
 
 		if result != nil && resp.Result != nil {
@@ -330,6 +492,24 @@ func (a *connAdapter) SendRequest(ctx context.Context, method string, params int
 	}
 }
 
+// read reads the next message from the connection, counting it
+func (a *connAdapter) read(ctx context.Context) (*protocol.JSONRPCMessage, error) {
+	msg, err := a.conn.Read(ctx)
+	if err == nil {
+		a.counters.CountRead(msg)
+	}
+	return msg, err
+}
+
+// write writes msg to the connection, counting it
+func (a *connAdapter) write(ctx context.Context, msg *protocol.JSONRPCMessage) error {
+	if err := a.conn.Write(ctx, msg); err != nil {
+		return err
+	}
+	a.counters.CountWritten(msg)
+	return nil
+}
+
 func (a *connAdapter) Close() error {
 	// Clean up all pending requests
 	a.mu.Lock()
@@ -371,7 +551,7 @@ func (a *connAdapter) handleResponse(msg *protocol.JSONRPCMessage) {
 	}
 
 	if msg.Error != nil {
-		pending.err <- fmt.Errorf("RPC error %d: %s", msg.Error.Code, msg.Error.Message)
+		pending.err <- fmt.Errorf("RPC error %d: %w", msg.Error.Code, protocol.NewMCPError(msg.Error.Code, msg.Error.Message, msg.Error.Data))
 	} else {
 		pending.response <- msg
 	}
@@ -408,3 +588,36 @@ func (ss *ServerSession) startKeepalive(interval time.Duration) {
 		}
 	}()
 }
+
+// NotificationSender delivers notifications emitted while a single message is being handled
+type NotificationSender func(ctx context.Context, method string, params interface{}) error
+
+type ctxKeyNotificationSender struct{}
+
+// ContextWithNotificationSender attaches a sender used by Server.HandleMessage, letting
+// request-scoped transports (e.g. a Streamable HTTP response stream) carry progress,
+// logging and resource chunk notifications for the request being handled.
+func ContextWithNotificationSender(ctx context.Context, send NotificationSender) context.Context {
+	return context.WithValue(ctx, ctxKeyNotificationSender{}, send)
+}
+
+// senderConn is the Connection of a temporary session backed by a NotificationSender
+type senderConn struct {
+	send NotificationSender
+}
+
+func (c senderConn) SendNotification(ctx context.Context, method string, params interface{}) error {
+	return c.send(ctx, method, params)
+}
+
+func (c senderConn) SendRequest(ctx context.Context, method string, params interface{}, result interface{}) error {
+	return fmt.Errorf("%s: server requests are not supported on this connection", method)
+}
+
+func (c senderConn) Close() error {
+	return nil
+}
+
+func (c senderConn) SessionID() string {
+	return ""
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SessionStore persists session state so that a client reconnecting with the same
+// session ID (e.g. after a network blip) resumes its session instead of starting over.
+// Load returns nil and no error if nothing is stored for id.
+type SessionStore interface {
+	Save(id string, state ServerSessionState) error
+	Load(id string) (*ServerSessionState, error)
+	Delete(id string) error
+}
+
+// MemorySessionStore is an in-process SessionStore
+type MemorySessionStore struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]memorySessionEntry
+}
+
+type memorySessionEntry struct {
+	state   ServerSessionState
+	savedAt time.Time
+}
+
+// NewMemorySessionStore creates an in-memory store. Entries not saved for ttl are
+// discarded; zero keeps them until deleted.
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		ttl:     ttl,
+		entries: make(map[string]memorySessionEntry),
+	}
+}
+
+func (m *MemorySessionStore) Save(id string, state ServerSessionState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.ttl > 0 {
+		for key, entry := range m.entries {
+			if now.Sub(entry.savedAt) > m.ttl {
+				delete(m.entries, key)
+			}
+		}
+	}
+	state.Subscriptions = append([]string(nil), state.Subscriptions...)
+	state.ToolCallUsage = state.ToolCallUsage.clone()
+	m.entries[id] = memorySessionEntry{state: state, savedAt: now}
+	return nil
+}
+
+func (m *MemorySessionStore) Load(id string) (*ServerSessionState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[id]
+	if !ok {
+		return nil, nil
+	}
+	if m.ttl > 0 && time.Since(entry.savedAt) > m.ttl {
+		delete(m.entries, id)
+		return nil, nil
+	}
+	state := entry.state
+	state.Subscriptions = append([]string(nil), state.Subscriptions...)
+	state.ToolCallUsage = state.ToolCallUsage.clone()
+	return &state, nil
+}
+
+func (m *MemorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	delete(m.entries, id)
+	m.mu.Unlock()
+	return nil
+}
+
+// ForgetSession discards the persisted state of a session that will not reconnect.
+// Transports call it when a session expires or is terminated.
+func (s *Server) ForgetSession(id string) error {
+	if s.opts.SessionStore == nil || id == "" {
+		return nil
+	}
+	return s.opts.SessionStore.Delete(id)
+}
+
+// persistSession saves the session's state if a SessionStore is configured
+func (s *Server) persistSession(ss *ServerSession) error {
+	id := ss.ID()
+	if s.opts.SessionStore == nil || id == "" {
+		return nil
+	}
+
+	ss.mu.Lock()
+	state := ss.state
+	state.Subscriptions = append([]string(nil), ss.state.Subscriptions...)
+	state.ToolCallUsage = ss.state.ToolCallUsage.clone()
+	state.ClientID = ss.clientID
+	ss.mu.Unlock()
+
+	if err := s.opts.SessionStore.Save(id, state); err != nil {
+		return fmt.Errorf("save session %s: %w", id, err)
+	}
+	return nil
+}
+
+// restoreSession loads the persisted state of a reconnecting session, if any. State saved
+// for another client is not restored, so a guessed session ID cannot take over a session.
+func (s *Server) restoreSession(ss *ServerSession) (bool, error) {
+	id := ss.ID()
+	if s.opts.SessionStore == nil || id == "" {
+		return false, nil
+	}
+
+	state, err := s.opts.SessionStore.Load(id)
+	if err != nil {
+		return false, fmt.Errorf("load session %s: %w", id, err)
+	}
+	if state == nil || state.ClientID != ss.clientID {
+		return false, nil
+	}
+	ss.state = *state
+	return true, nil
+}
+
+// resubscribe re-registers the resource subscriptions recorded in a restored session's state
+func (s *Server) resubscribe(ss *ServerSession) {
+	ss.mu.Lock()
+	uris := append([]string(nil), ss.state.Subscriptions...)
+	ss.mu.Unlock()
+
+	s.mu.Lock()
+	for _, uri := range uris {
+		if s.resourceSubscriptions[uri] == nil {
+			s.resourceSubscriptions[uri] = make(map[*ServerSession]bool)
+		}
+		s.resourceSubscriptions[uri][ss] = true
+	}
+	s.mu.Unlock()
+
+	for _, uri := range uris {
+		_ = s.syncResourceWatch(uri)
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+)
+
+// Shutdown gracefully stops the server. New requests are rejected right away, while
+// in-flight requests may complete for up to ServerOptions.ShutdownTimeout, or until ctx
+// is done. Requests still running after that have their contexts cancelled, and all
+// sessions are closed.
+//
+// Shutdown returns the context error if it stopped waiting before every request completed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shuttingDown.Store(true)
+
+	if s.opts.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.ShutdownTimeout)
+		defer cancel()
+	}
+
+	s.mu.Lock()
+	sessions := make([]*ServerSession, len(s.sessions))
+	copy(sessions, s.sessions)
+	transient := make([]*ServerSession, 0, len(s.transientSessions))
+	for ss := range s.transientSessions {
+		transient = append(transient, ss)
+	}
+	s.mu.Unlock()
+
+	var err error
+	for _, ss := range append(sessions, transient...) {
+		if err = ss.waitIdle(ctx); err != nil {
+			break
+		}
+	}
+
+	for _, ss := range transient {
+		ss.cancelPending()
+	}
+	for _, ss := range sessions {
+		_ = ss.Close()
+	}
+	s.mu.Lock()
+	admin := s.admin
+	s.mu.Unlock()
+	if admin != nil {
+		_ = admin.Close()
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+package server
+
+import "context"
+
+type ctxKeyTenantID struct{}
+
+// ContextWithTenantID returns a context carrying the tenant of a request, e.g. from an
+// HTTP transport's ContextExtractor reading a tenant header
+func ContextWithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, ctxKeyTenantID{}, tenantID)
+}
+
+// TenantIDFromContext returns the tenant set with ContextWithTenantID, or ""
+func TenantIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenantID, _ := ctx.Value(ctxKeyTenantID{}).(string)
+	return tenantID
+}
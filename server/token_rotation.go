@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// defaultTokenRotationGrace is how long a replaced session token stays valid when
+// ServerOptions.TokenRotationGrace is zero
+const defaultTokenRotationGrace = 30 * time.Second
+
+// sessionTokens are the session tokens issued by RotateToken, guarded by ServerSession.mu
+type sessionTokens struct {
+	current         string
+	previous        string
+	previousExpires time.Time
+}
+
+// RotateToken issues a new session token and sends it to the client with
+// notifications/auth/token_rotated. The replaced token stays valid for
+// ServerOptions.TokenRotationGrace. Transports that support session tokens, such as
+// SSE, reject requests carrying neither token.
+func (ss *ServerSession) RotateToken() (string, error) {
+	if ss.conn == nil || ss.transient {
+		return "", ErrNoConnection
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate session token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	grace := ss.server.opts.TokenRotationGrace
+	if grace <= 0 {
+		grace = defaultTokenRotationGrace
+	}
+	ss.mu.Lock()
+	// Before the first rotation the previous "token" is none at all, so requests sent
+	// without one before the client learns the token are still accepted
+	ss.tokens.previous = ss.tokens.current
+	ss.tokens.previousExpires = time.Now().Add(grace)
+	ss.tokens.current = token
+	ss.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	params := &protocol.TokenRotatedNotificationParams{Token: token}
+	if err := ss.conn.SendNotification(ctx, protocol.NotificationAuthTokenRotated, params); err != nil {
+		return token, fmt.Errorf("send rotated token: %w", err)
+	}
+	return token, nil
+}
+
+// ValidateToken reports whether token authenticates a request of the session: it must
+// be the current token or the previous one within its grace period. Any token is
+// accepted until RotateToken is first called, and requests without a token during the
+// grace period of the first rotation.
+func (ss *ServerSession) ValidateToken(token string) bool {
+	ss.mu.Lock()
+	tokens := ss.tokens
+	ss.mu.Unlock()
+
+	if tokens.current == "" {
+		return true
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(tokens.current)) == 1 {
+		return true
+	}
+	return time.Now().Before(tokens.previousExpires) &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(tokens.previous)) == 1
+}
+
+// startTokenRotation rotates the session token every interval until the session closes
+func (ss *ServerSession) startTokenRotation(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ss.mu.Lock()
+	if ss.tokenRotationCancel != nil {
+		ss.tokenRotationCancel()
+	}
+	ss.tokenRotationCancel = cancel
+	ss.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// A failed send surfaces through the connection; keep rotating meanwhile
+				_, _ = ss.RotateToken()
+			}
+		}
+	}()
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/invopop/jsonschema"
 	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/utils"
 )
 
 // ToolHandlerFor is a type-safe handler function for tools/call requests.
@@ -41,13 +42,17 @@ type ToolHandlerFor[In, Out any] func(
 // https://go.googlesource.com/proposal/+/refs/heads/master/design/43651-type-parameters.md#no-parameterized-methods
 //
 // If the tool's input schema is nil, it is inferred from the In type parameter. Types are inferred from Go types,
-// and property descriptions are read from 'jsonschema' struct tags. Internally, the SDK uses the github.com/invopop/jsonschema
-// package for inference and validation. The In type parameter must be a map or struct so that its inferred JSON Schema has
-// the "object" type required by the specification. As a special case, if the In type is 'any', the tool's input schema
-// is set to an empty object schema value.
+// including nested structs, slices, pointers and embedded structs, and property descriptions are read from 'jsonschema'
+// struct tags. Internally, the SDK uses the github.com/invopop/jsonschema package for inference and validation.
+// The In type parameter must be a struct so that its inferred JSON Schema has the "object" type required by the
+// specification. As a special case, if the In type is 'any', map[string]any or json.RawMessage, the tool's input schema
+// is set to an empty object schema value and arguments are not validated.
 //
-// If the tool's output schema is nil, and the Out type is not 'any', the output schema is inferred from the Out type
-// parameter, which must also be a map or struct. If the Out type is 'any', the output schema is omitted.
+// If the tool's output schema is nil, the output schema is inferred from the Out type parameter, which must also be
+// a struct. If the Out type is 'any', map[string]any or json.RawMessage, the output schema is omitted.
+//
+// AddTool returns an error if a provided schema is invalid or a schema cannot be inferred, or if
+// [Server.AddTool] fails; the tool is not added.
 //
 // Unlike [Server.AddTool], AddTool automatically handles many things and enforces that tools conform to the MCP specification.
 // For detailed automatic behaviors, see the documentation for [ToolHandlerFor].
@@ -69,13 +74,73 @@ type ToolHandlerFor[In, Out any] func(
 //	) {
 //	    return nil, Output{Greeting: "Hello, " + input.Name}, nil
 //	})
-func AddTool[In, Out any](s *Server, tool *protocol.Tool, handler ToolHandlerFor[In, Out]) {
+func AddTool[In, Out any](s *Server, tool *protocol.Tool, handler ToolHandlerFor[In, Out], opts ...*ToolOptions) error {
 	wrappedTool, wrappedHandler, err := wrapToolHandler(tool, handler)
 	if err != nil {
-		panic(fmt.Sprintf("AddTool %q: %v", tool.Name, err))
+		return fmt.Errorf("AddTool %q: %w", tool.Name, err)
+	}
+
+	return s.AddTool(wrappedTool, wrappedHandler, opts...)
+}
+
+// AddListTool adds a tool whose handler returns a list of Elem values, like [AddTool].
+//
+// The result's StructuredContent is set to the list as a JSON array; a nil list is sent as
+// an empty array. If the tool's output schema is nil, it is set to
+// {"type": "array", "items": <schema of Elem>}, where Elem must be a struct. Pagination
+// parameters, if any, belong in the In type.
+//
+// The MCP specification requires object output schemas and structured content, so
+// clients that enforce it may reject such tools; use [AddTool] with an Out struct holding
+// the list for those.
+func AddListTool[In, Elem any](s *Server, tool *protocol.Tool, handler func(ctx context.Context, req *CallToolRequest, input In) (*protocol.CallToolResult, []Elem, error), opts ...*ToolOptions) error {
+	toolCopy := *tool
+
+	inputSchema, err := setupInputSchema[In](&toolCopy)
+	if err != nil {
+		return fmt.Errorf("AddListTool %q: input schema: %w", tool.Name, err)
+	}
+
+	if toolCopy.OutputSchema == nil {
+		schema, err := inferSchema[Elem]()
+		if err != nil {
+			return fmt.Errorf("AddListTool %q: output schema: infer from %v: %w", tool.Name, reflect.TypeFor[Elem](), err)
+		}
+		items, err := utils.SchemaToJSONMap(schema)
+		if err != nil {
+			return fmt.Errorf("AddListTool %q: output schema: %w", tool.Name, err)
+		}
+		delete(items, "$schema")
+		toolCopy.OutputSchema = protocol.JSONSchema{
+			"type":  "array",
+			"items": items,
+		}
+	}
+
+	structure := func(list []Elem) (any, error) {
+		if list == nil {
+			list = []Elem{}
+		}
+		outputData, err := json.Marshal(list)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal output: %w", err)
+		}
+
+		var outputList []interface{}
+		if err := json.Unmarshal(outputData, &outputList); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal output: %w", err)
+		}
+		return outputList, nil
 	}
 
-	s.AddTool(wrappedTool, wrappedHandler)
+	return s.AddTool(&toolCopy, wrapTypedHandler(&toolCopy, inputSchema, handler, structure), opts...)
+}
+
+// isUntypedSchemaType reports whether t carries arbitrary JSON, so no schema can be inferred from it
+func isUntypedSchemaType(t reflect.Type) bool {
+	return t == reflect.TypeFor[any]() ||
+		t == reflect.TypeFor[map[string]any]() ||
+		t == reflect.TypeFor[json.RawMessage]()
 }
 
 // wrapToolHandler wraps a type-safe handler into a low-level handler
@@ -92,14 +157,45 @@ func wrapToolHandler[In, Out any](tool *protocol.Tool, handler ToolHandlerFor[In
 		return nil, nil, fmt.Errorf("output schema: %w", err)
 	}
 
+	// Untyped JSON outputs still populate StructuredContent, just without a schema
+	outType := reflect.TypeFor[Out]()
+	structured := outputSchema != nil || (outType != reflect.TypeFor[any]() && isUntypedSchemaType(outType))
+
 	// Get zero value (for handling typed nil)
 	var outputZero interface{}
-	if outputSchema != nil {
+	if structured {
 		outputZero = getZeroValue[Out]()
 	}
 
-	// Create wrapped handler
-	wrappedHandler := func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+	var structure func(Out) (any, error)
+	if structured {
+		structure = func(output Out) (any, error) {
+			// Check for typed nil (use reflection because some types are not comparable)
+			if outputZero != nil && reflect.ValueOf(output).IsZero() {
+				// Use zero value instead of typed nil
+				output = outputZero.(Out)
+			}
+
+			outputData, err := json.Marshal(output)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal output: %w", err)
+			}
+
+			var outputMap map[string]interface{}
+			if err := json.Unmarshal(outputData, &outputMap); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal output: %w", err)
+			}
+			return outputMap, nil
+		}
+	}
+
+	return &toolCopy, wrapTypedHandler(&toolCopy, inputSchema, handler, structure), nil
+}
+
+// wrapTypedHandler validates the input of handler against inputSchema, and sets the
+// StructuredContent of its results to structure(output) unless structure is nil
+func wrapTypedHandler[In, Out any](tool *protocol.Tool, inputSchema *jsonschema.Schema, handler ToolHandlerFor[In, Out], structure func(Out) (any, error)) ToolHandler {
+	return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
 		inputData := req.Params.Arguments
 		if inputData == nil {
 			inputData = make(map[string]any)
@@ -109,7 +205,7 @@ func wrapToolHandler[In, Out any](tool *protocol.Tool, handler ToolHandlerFor[In
 		if err != nil {
 			return nil, protocol.NewMCPError(protocol.InvalidParams, "Invalid params", map[string]any{
 				"method": protocol.MethodToolsCall,
-				"tool":   toolCopy.Name,
+				"tool":   tool.Name,
 			})
 		}
 
@@ -131,30 +227,16 @@ func wrapToolHandler[In, Out any](tool *protocol.Tool, handler ToolHandlerFor[In
 		}
 
 		// Process output
-		if outputSchema != nil {
-			// Check for typed nil (use reflection because some types are not comparable)
-			if outputZero != nil && reflect.ValueOf(output).IsZero() {
-				// Use zero value instead of typed nil
-				output = outputZero.(Out)
-			}
-
-			outputData, err := json.Marshal(output)
+		if structure != nil {
+			structured, err := structure(output)
 			if err != nil {
-				return nil, fmt.Errorf("failed to marshal output: %w", err)
-			}
-
-			var outputMap map[string]interface{}
-			if err := json.Unmarshal(outputData, &outputMap); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal output: %w", err)
+				return nil, err
 			}
-
-			result.StructuredContent = outputMap
+			result.StructuredContent = structured
 		}
 
 		return result, nil
 	}
-
-	return &toolCopy, wrappedHandler, nil
 }
 
 // setupInputSchema sets up the input schema
@@ -178,25 +260,21 @@ func setupInputSchema[In any](tool *protocol.Tool) (*jsonschema.Schema, error) {
 		return &schema, nil
 	}
 
+	// Arbitrary JSON arguments: accept any object without validation
+	if isUntypedSchemaType(reflect.TypeFor[In]()) {
+		tool.InputSchema = map[string]interface{}{"type": "object"}
+		return &jsonschema.Schema{Type: "object"}, nil
+	}
+
 	// Auto-generate schema
 	schema, err := inferSchema[In]()
 	if err != nil {
-		// Auto-generation failed, use basic object schema
-		// Note: This means no parameter validation, but tool can still run
-		return &jsonschema.Schema{
-			Type: "object",
-		}, nil
+		return nil, fmt.Errorf("infer from %v: %w", reflect.TypeFor[In](), err)
 	}
 
-	// Convert to protocol.JSONSchema (map[string]interface{})
-	schemaBytes, err := json.Marshal(schema)
+	schemaMap, err := utils.SchemaToJSONMap(schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal schema: %w", err)
-	}
-
-	var schemaMap map[string]interface{}
-	if err := json.Unmarshal(schemaBytes, &schemaMap); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal schema: %w", err)
+		return nil, err
 	}
 
 	tool.InputSchema = schemaMap
@@ -205,11 +283,6 @@ func setupInputSchema[In any](tool *protocol.Tool) (*jsonschema.Schema, error) {
 
 // setupOutputSchema sets up the output schema
 func setupOutputSchema[Out any](tool *protocol.Tool) (*jsonschema.Schema, error) {
-	// If it's 'any' type, don't generate output schema
-	if reflect.TypeFor[Out]() == reflect.TypeFor[any]() {
-		return nil, nil
-	}
-
 	// If user has provided schema, use it directly
 	if tool.OutputSchema != nil {
 		schemaBytes, err := json.Marshal(tool.OutputSchema)
@@ -229,21 +302,20 @@ func setupOutputSchema[Out any](tool *protocol.Tool) (*jsonschema.Schema, error)
 		return &schema, nil
 	}
 
-	// Auto-generate schema
-	schema, err := inferSchema[Out]()
-	if err != nil {
-		// Auto-generation failed, return nil (don't use output schema)
+	// Arbitrary JSON output: no schema to advertise
+	if isUntypedSchemaType(reflect.TypeFor[Out]()) {
 		return nil, nil
 	}
 
-	schemaBytes, err := json.Marshal(schema)
+	// Auto-generate schema
+	schema, err := inferSchema[Out]()
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+		return nil, fmt.Errorf("infer from %v: %w", reflect.TypeFor[Out](), err)
 	}
 
-	var schemaMap map[string]interface{}
-	if err := json.Unmarshal(schemaBytes, &schemaMap); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal schema: %w", err)
+	schemaMap, err := utils.SchemaToJSONMap(schema)
+	if err != nil {
+		return nil, err
 	}
 
 	tool.OutputSchema = schemaMap
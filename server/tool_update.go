@@ -0,0 +1,38 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// SchemaDiff describes how the input schema of a tool changed, see protocol.CompareSchemas
+type SchemaDiff = protocol.SchemaChanges
+
+// UpdateTool replaces the tool with the same name as t, even with
+// ServerOptions.StrictToolRegistration, and returns how its input schema changed.
+// Breaking changes are logged as warnings.
+// It returns ErrToolNotFound if there is no such tool; use AddTool to add tools.
+func (s *Server) UpdateTool(t *protocol.Tool, h ToolHandler, opts ...*ToolOptions) (SchemaDiff, error) {
+	s.mu.Lock()
+	_, exists := s.tools[t.Name]
+	s.mu.Unlock()
+	if !exists {
+		return SchemaDiff{}, fmt.Errorf("%w: %s", ErrToolNotFound, t.Name)
+	}
+
+	previous, err := s.putTool(t, h, opts, true)
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+	if previous == nil {
+		// Removed concurrently, so t was added rather than updated
+		return protocol.CompareSchemas(nil, t.InputSchema), nil
+	}
+	changes := protocol.CompareSchemas(previous.InputSchema, t.InputSchema)
+	if changes.IsBreaking() {
+		slog.Warn("tool input schema has breaking changes", "tool", t.Name, "changes", changes.Descriptions())
+	}
+	return changes, nil
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceContextFromMeta extracts a W3C trace context (traceparent/tracestate) from request _meta
+// and returns a context carrying the remote span context as parent
+func TraceContextFromMeta(ctx context.Context, meta map[string]any) context.Context {
+	if len(meta) == 0 {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{}
+	for _, key := range []string{"traceparent", "tracestate"} {
+		if v, ok := meta[key].(string); ok && v != "" {
+			carrier[key] = v
+		}
+	}
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
+
+// NewTracingMiddleware starts an OpenTelemetry span for each tool call.
+// The span is parented to the trace context sent by the client in _meta, if any.
+func NewTracingMiddleware(tracer trace.Tracer) Middleware {
+	return func(next ToolHandler) ToolHandler {
+		return func(ctx context.Context, req *CallToolRequest) (*protocol.CallToolResult, error) {
+			ctx = TraceContextFromMeta(ctx, req.Params.Meta)
+
+			sessionID := ""
+			if req.Session != nil {
+				sessionID = req.Session.ID()
+			}
+
+			ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", protocol.MethodToolsCall, req.Params.Name),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("tool.name", req.Params.Name),
+					attribute.String("session.id", sessionID),
+				),
+			)
+			defer span.End()
+
+			result, err := next(ctx, req)
+
+			isError := err != nil || (result != nil && result.IsError)
+			span.SetAttributes(attribute.Bool("is_error", isError))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			} else if isError {
+				span.SetStatus(codes.Error, "tool returned an error result")
+			}
+
+			return result, err
+		}
+	}
+}
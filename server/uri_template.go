@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type ctxKeyTemplateVars struct{}
+
+// TemplateVarsFromContext returns the variables captured from the requested URI when
+// a resources/read request is dispatched to a resource template handler
+func TemplateVarsFromContext(ctx context.Context) map[string]string {
+	vars, _ := ctx.Value(ctxKeyTemplateVars{}).(map[string]string)
+	return vars
+}
+
+func contextWithTemplateVars(ctx context.Context, vars map[string]string) context.Context {
+	return context.WithValue(ctx, ctxKeyTemplateVars{}, vars)
+}
+
+// ExtractTemplateVars matches uri against an RFC 6570 Level 1 URI template such as
+// "file:///{dir}/{name}" and returns the captured variables, percent-decoded.
+// As in Level 1 expansion, a variable only spans unreserved and percent-encoded characters,
+// so it never matches across a "/". Templates using higher-level operators never match.
+func ExtractTemplateVars(uriTemplate, uri string) (map[string]string, bool) {
+	t, ok := compileURITemplate(uriTemplate)
+	if !ok {
+		return nil, false
+	}
+	return t.match(uri)
+}
+
+// uriTemplate is a compiled Level 1 URI template
+type uriTemplate struct {
+	re       *regexp.Regexp
+	names    []string
+	literals int // number of literal characters, used to prefer the most specific template
+}
+
+// levelOneVarName matches a Level 1 variable name (varchar per RFC 6570 section 2.3)
+var levelOneVarName = regexp.MustCompile(`^(?:[A-Za-z0-9_]|%[0-9A-Fa-f]{2})(?:\.?(?:[A-Za-z0-9_]|%[0-9A-Fa-f]{2}))*$`)
+
+func compileURITemplate(tmpl string) (*uriTemplate, bool) {
+	t := &uriTemplate{}
+	var pattern strings.Builder
+	pattern.WriteString("^")
+
+	rest := tmpl
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, false
+		}
+		name := rest[open+1 : open+end]
+		if !levelOneVarName.MatchString(name) {
+			return nil, false
+		}
+
+		pattern.WriteString(regexp.QuoteMeta(rest[:open]))
+		pattern.WriteString(`((?:[A-Za-z0-9\-._~]|%[0-9A-Fa-f]{2})*)`)
+		t.literals += open
+		t.names = append(t.names, name)
+		rest = rest[open+end+1:]
+	}
+	if strings.IndexByte(rest, '}') >= 0 {
+		return nil, false
+	}
+	pattern.WriteString(regexp.QuoteMeta(rest))
+	pattern.WriteString("$")
+	t.literals += len(rest)
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, false
+	}
+	t.re = re
+	return t, true
+}
+
+func (t *uriTemplate) match(uri string) (map[string]string, bool) {
+	m := t.re.FindStringSubmatch(uri)
+	if m == nil {
+		return nil, false
+	}
+
+	vars := make(map[string]string, len(t.names))
+	for i, name := range t.names {
+		value, err := url.PathUnescape(m[i+1])
+		if err != nil {
+			return nil, false
+		}
+		if prev, seen := vars[name]; seen && prev != value {
+			return nil, false
+		}
+		vars[name] = value
+	}
+	return vars, true
+}
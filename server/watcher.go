@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// ResourceWatcher detects changes to a resource's underlying data.
+//
+// Watch starts watching uri and must not block; each change is signalled by a
+// non-blocking send on changed until ctx is cancelled. Unwatch releases the
+// resources held for uri.
+type ResourceWatcher interface {
+	Watch(ctx context.Context, uri string, changed chan<- struct{}) error
+	Unwatch(uri string)
+}
+
+// resourceWatch is an active watch on a resource with at least one subscriber
+type resourceWatch struct {
+	watcher ResourceWatcher
+	cancel  context.CancelFunc
+}
+
+// AddWatchedResource adds a resource whose subscribers are notified automatically.
+// The watcher is started when the first client subscribes to the resource and stopped
+// when the last one unsubscribes or disconnects; every change it reports is sent to
+// the subscribers as notifications/resources/updated.
+func (s *Server) AddWatchedResource(r *protocol.Resource, h ResourceHandler, w ResourceWatcher) {
+	s.mu.Lock()
+
+	s.resources[r.URI] = &serverResource{
+		resource: r,
+		handler:  h,
+		watcher:  w,
+	}
+
+	s.mu.Unlock()
+
+	s.syncResourceWatch(r.URI)
+	s.notifyResourceListChanged()
+}
+
+// subscribeSupportedLocked reports whether resources/subscribe is available; s.mu must be held
+func (s *Server) subscribeSupportedLocked() bool {
+	if s.opts.SubscribeHandler != nil && s.opts.UnsubscribeHandler != nil {
+		return true
+	}
+	for _, sr := range s.resources {
+		if sr.watcher != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// syncResourceWatch starts or stops the watch on uri so that it runs exactly
+// while the resource is watched and has subscribers
+func (s *Server) syncResourceWatch(uri string) error {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	s.mu.Lock()
+	var watcher ResourceWatcher
+	if sr, ok := s.resources[uri]; ok && len(s.resourceSubscriptions[uri]) > 0 {
+		watcher = sr.watcher
+	}
+	current := s.resourceWatches[uri]
+	if current != nil && current.watcher == watcher {
+		s.mu.Unlock()
+		return nil
+	}
+	delete(s.resourceWatches, uri)
+	s.mu.Unlock()
+
+	if current != nil {
+		current.cancel()
+		current.watcher.Unwatch(uri)
+	}
+	if watcher == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan struct{}, 1)
+	if err := watcher.Watch(ctx, uri, changed); err != nil {
+		cancel()
+		return err
+	}
+
+	s.mu.Lock()
+	s.resourceWatches[uri] = &resourceWatch{watcher: watcher, cancel: cancel}
+	s.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+				s.NotifyResourceUpdated(uri)
+			}
+		}
+	}()
+	return nil
+}
+
+// FileResourceWatcher returns a ResourceWatcher reporting writes, creation, renames and
+// removal of the file at path. The parent directory is watched so that files replaced
+// by editors (write to temp file, then rename) keep being tracked.
+func FileResourceWatcher(path string) ResourceWatcher {
+	return &fileResourceWatcher{
+		path:     path,
+		watchers: make(map[string]*fsnotify.Watcher),
+	}
+}
+
+type fileResourceWatcher struct {
+	path string
+
+	mu       sync.Mutex
+	watchers map[string]*fsnotify.Watcher // uri -> watcher
+}
+
+func (fw *fileResourceWatcher) Watch(ctx context.Context, uri string, changed chan<- struct{}) error {
+	path, err := filepath.Abs(fw.path)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", fw.path, err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create file watcher: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("watch %s: %w", fw.path, err)
+	}
+
+	fw.mu.Lock()
+	if prev := fw.watchers[uri]; prev != nil {
+		prev.Close()
+	}
+	fw.watchers[uri] = watcher
+	fw.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != path || event.Op == fsnotify.Chmod {
+					continue
+				}
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (fw *fileResourceWatcher) Unwatch(uri string) {
+	fw.mu.Lock()
+	watcher := fw.watchers[uri]
+	delete(fw.watchers, uri)
+	fw.mu.Unlock()
+
+	if watcher != nil {
+		watcher.Close()
+	}
+}
@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DefaultAPIKeyHeader is the header read by NewAPIKeyMiddleware when none is configured.
+// Its value is expected in the form "Bearer <key>".
+const DefaultAPIKeyHeader = "Authorization"
+
+type ctxKeyClientID struct{}
+
+// ContextWithClientID returns a context carrying the authenticated client identifier
+func ContextWithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, ctxKeyClientID{}, clientID)
+}
+
+// ClientIDFromContext returns the authenticated client identifier, or "" if the request was not authenticated
+func ClientIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	clientID, _ := ctx.Value(ctxKeyClientID{}).(string)
+	return clientID
+}
+
+// valuesContext has the deadline and cancellation of its embedded context and the values
+// of both contexts
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c valuesContext) Value(key any) any {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.values.Value(key)
+}
+
+// MergeContextValues returns a context with the deadline and cancellation of ctx and the
+// values of both ctx and values, preferring those of ctx
+func MergeContextValues(ctx, values context.Context) context.Context {
+	if values == nil {
+		return ctx
+	}
+	return valuesContext{Context: ctx, values: values}
+}
+
+type ctxKeyClientIP struct{}
+
+// ContextWithClientIP returns a context carrying the remote IP address of the client
+func ContextWithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ctxKeyClientIP{}, ip)
+}
+
+// ClientIPFromContext returns the client IP set by the HTTP transports, or "" if unknown
+func ClientIPFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ip, _ := ctx.Value(ctxKeyClientIP{}).(string)
+	return ip
+}
+
+// RemoteIP returns the IP address of the HTTP client, without the port
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// NewAPIKeyMiddleware rejects HTTP requests whose API key is not in keys with 401 Unauthorized.
+// An empty header defaults to "Authorization: Bearer <key>"; any other header carries the raw key.
+// Authenticated requests get a stable client identifier derived from the key (never the key itself),
+// available to downstream handlers via ClientIDFromContext.
+func NewAPIKeyMiddleware(keys []string, header string) func(http.Handler) http.Handler {
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+	bearer := strings.EqualFold(header, DefaultAPIKeyHeader)
+
+	allowed := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		allowed[key] = apiKeyClientID(key)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(header)
+			if bearer {
+				key = bearerToken(key)
+			}
+
+			clientID, ok := lookupAPIKey(allowed, key)
+			if !ok {
+				if bearer {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="mcp"`)
+				}
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithClientID(r.Context(), clientID)))
+		})
+	}
+}
+
+// bearerToken extracts the credential from an "Authorization: Bearer <token>" header value
+func bearerToken(value string) string {
+	const prefix = "Bearer "
+	if len(value) < len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(value[len(prefix):])
+}
+
+// lookupAPIKey compares against every allowed key in constant time
+func lookupAPIKey(allowed map[string]string, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	var clientID string
+	found := false
+	for candidate, id := range allowed {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			clientID = id
+			found = true
+		}
+	}
+	return clientID, found
+}
+
+func apiKeyClientID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,80 @@
+package transport
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures cross-origin access to the HTTP transports.
+// Requests without an Origin header (non-browser clients) are never affected.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the server, e.g. "https://app.example.com".
+	// "*" allows any origin, and a single "*" inside an entry matches any substring
+	// (e.g. "https://*.example.com"). Requests from other origins receive 403 Forbidden.
+	AllowedOrigins []string
+
+	// AllowedHeaders are the request headers allowed in preflighted requests
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers readable by browser scripts
+	ExposedHeaders []string
+
+	// MaxAge is how long browsers may cache a preflight response. Zero omits the header.
+	MaxAge time.Duration
+}
+
+// AllowsOrigin reports whether origin matches AllowedOrigins
+func (c *CORSConfig) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok {
+			if len(origin) >= len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServeCORS applies the policy to a request. It sets the CORS response headers for
+// allowed origins, rejects other origins with 403 and answers preflight OPTIONS
+// requests for the given methods. It returns true if the response has been written
+// and the request must not be processed further.
+func (c *CORSConfig) ServeCORS(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	if !c.AllowsOrigin(origin) {
+		http.Error(w, "Forbidden: invalid origin", http.StatusForbidden)
+		return true
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if len(c.AllowedHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+		}
+		if c.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	if len(c.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package transport
+
+import (
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// MessageCounter counts the messages read from and written to a connection, and their
+// size in bytes as JSON. The zero value is ready to use.
+type MessageCounter struct {
+	bytesRead       atomic.Int64
+	bytesWritten    atomic.Int64
+	messagesRead    atomic.Int64
+	messagesWritten atomic.Int64
+}
+
+// CountRead records msg as read
+func (c *MessageCounter) CountRead(msg *protocol.JSONRPCMessage) {
+	c.messagesRead.Add(1)
+	c.bytesRead.Add(encodedSize(msg))
+}
+
+// CountWritten records msg as written
+func (c *MessageCounter) CountWritten(msg *protocol.JSONRPCMessage) {
+	c.messagesWritten.Add(1)
+	c.bytesWritten.Add(encodedSize(msg))
+}
+
+func (c *MessageCounter) BytesRead() int64       { return c.bytesRead.Load() }
+func (c *MessageCounter) BytesWritten() int64    { return c.bytesWritten.Load() }
+func (c *MessageCounter) MessagesRead() int64    { return c.messagesRead.Load() }
+func (c *MessageCounter) MessagesWritten() int64 { return c.messagesWritten.Load() }
+
+func encodedSize(msg *protocol.JSONRPCMessage) int64 {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
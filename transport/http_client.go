@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// ClientHTTPConfig tunes the connection pool of the HTTP client used by the HTTP transports.
+// Zero fields keep the values of http.DefaultTransport.
+type ClientHTTPConfig struct {
+	// MaxIdleConns limits idle connections across all hosts
+	MaxIdleConns int
+
+	// MaxConnsPerHost limits connections per host, including those in use. Zero means no limit.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection stays in the pool
+	IdleConnTimeout time.Duration
+
+	// DisableKeepAlives uses each connection for a single request
+	DisableKeepAlives bool
+
+	// DialTimeout limits the time taken to establish a TCP connection
+	DialTimeout time.Duration
+
+	// TLSHandshakeTimeout limits the time taken by the TLS handshake
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout limits the wait for response headers after the request is written.
+	// Zero means no limit.
+	ResponseHeaderTimeout time.Duration
+}
+
+// NewHTTPTransport returns a clone of http.DefaultTransport with cfg applied
+func NewHTTPTransport(cfg ClientHTTPConfig) *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.MaxIdleConns > 0 {
+		tr.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		tr.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		tr.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.DisableKeepAlives {
+		tr.DisableKeepAlives = true
+	}
+	if cfg.DialTimeout > 0 {
+		// Same keep-alive as the dialer of http.DefaultTransport
+		dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}
+		tr.DialContext = dialer.DialContext
+	}
+	if cfg.TLSHandshakeTimeout > 0 {
+		tr.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		tr.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+	return tr
+}
+
+// NewHTTPClient returns an HTTP client whose transport is configured by cfg
+func NewHTTPClient(cfg ClientHTTPConfig) *http.Client {
+	return &http.Client{Transport: NewHTTPTransport(cfg)}
+}
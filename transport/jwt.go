@@ -0,0 +1,309 @@
+package transport
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// DefaultJWKSRefreshInterval is how long a fetched JWKS key set is used before it is refreshed
+	DefaultJWKSRefreshInterval = time.Hour
+
+	// minJWKSRefreshInterval rate-limits refreshes triggered by unknown key IDs
+	minJWKSRefreshInterval = 30 * time.Second
+)
+
+// JWTClaimsKey is the context key under which NewJWTMiddleware stores the validated jwt.MapClaims
+type JWTClaimsKey struct{}
+
+// JWTConfig configures NewJWTMiddleware
+type JWTConfig struct {
+	// JWKSURL is the URL of the JSON Web Key Set used to verify token signatures
+	JWKSURL string
+
+	// Audience, if set, must be present in the token's "aud" claim
+	Audience string
+
+	// Issuer, if set, must match the token's "iss" claim
+	Issuer string
+
+	// RefreshInterval controls how long the key set is cached. Defaults to DefaultJWKSRefreshInterval.
+	RefreshInterval time.Duration
+
+	// HTTPClient is used to fetch the key set. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// JWTClaimsFromContext returns the claims stored by NewJWTMiddleware, or nil if there are none
+func JWTClaimsFromContext(ctx context.Context) jwt.MapClaims {
+	if ctx == nil {
+		return nil
+	}
+	claims, _ := ctx.Value(JWTClaimsKey{}).(jwt.MapClaims)
+	return claims
+}
+
+// NewJWTMiddleware rejects HTTP requests without a valid "Authorization: Bearer <jwt>" header
+// with 401 Unauthorized. Signatures are verified against the keys published at cfg.JWKSURL.
+//
+// The key set is fetched on first use and cached; once stale, or when a token references an
+// unknown key ID, it is refreshed in the background while requests keep using the cached keys.
+// Validated claims are stored under JWTClaimsKey{}, and the "sub" claim becomes the client
+// identifier returned by ClientIDFromContext.
+func NewJWTMiddleware(cfg JWTConfig) func(http.Handler) http.Handler {
+	keys := newJWKSCache(cfg)
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{
+			"RS256", "RS384", "RS512",
+			"PS256", "PS384", "PS512",
+			"ES256", "ES384", "ES512",
+			"EdDSA",
+		}),
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	parser := jwt.NewParser(opts...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := bearerToken(r.Header.Get("Authorization"))
+			if raw == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="mcp"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			claims := jwt.MapClaims{}
+			_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+				kid, _ := token.Header["kid"].(string)
+				return keys.key(r.Context(), kid)
+			})
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="mcp", error="invalid_token"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), JWTClaimsKey{}, claims)
+			if sub, err := claims.GetSubject(); err == nil && sub != "" {
+				ctx = ContextWithClientID(ctx, sub)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// jwkSet is an immutable snapshot of a fetched key set
+type jwkSet struct {
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+// jwksCache serves keys from the latest snapshot and refreshes it without blocking readers
+type jwksCache struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+
+	set        atomic.Pointer[jwkSet]
+	refreshing atomic.Bool
+	lastFetch  atomic.Int64
+	initMu     sync.Mutex // serializes the initial, blocking fetch
+}
+
+func newJWKSCache(cfg JWTConfig) *jwksCache {
+	c := &jwksCache{
+		url:      cfg.JWKSURL,
+		client:   cfg.HTTPClient,
+		interval: cfg.RefreshInterval,
+	}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+	if c.interval <= 0 {
+		c.interval = DefaultJWKSRefreshInterval
+	}
+	return c
+}
+
+// key returns the verification key for kid. Only the very first lookup waits for the network.
+func (c *jwksCache) key(ctx context.Context, kid string) (interface{}, error) {
+	set := c.set.Load()
+	if set == nil {
+		var err error
+		if set, err = c.initialFetch(ctx); err != nil {
+			return nil, err
+		}
+	} else if time.Since(set.fetched) > c.interval {
+		c.refreshAsync()
+	}
+
+	if kid == "" && len(set.keys) == 1 {
+		for _, k := range set.keys {
+			return k, nil
+		}
+	}
+	if k, ok := set.keys[kid]; ok {
+		return k, nil
+	}
+
+	// The issuer may have rotated keys; pick them up for subsequent requests
+	if time.Since(time.Unix(0, c.lastFetch.Load())) > minJWKSRefreshInterval {
+		c.refreshAsync()
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (c *jwksCache) initialFetch(ctx context.Context) (*jwkSet, error) {
+	c.initMu.Lock()
+	defer c.initMu.Unlock()
+	if set := c.set.Load(); set != nil {
+		return set, nil
+	}
+	set, err := c.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.set.Store(set)
+	return set, nil
+}
+
+func (c *jwksCache) refreshAsync() {
+	if !c.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer c.refreshing.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if set, err := c.fetch(ctx); err == nil {
+			c.set.Store(set)
+		}
+	}()
+}
+
+func (c *jwksCache) fetch(ctx context.Context) (*jwkSet, error) {
+	c.lastFetch.Store(time.Now().UnixNano())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create JWKS request failed: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS failed: HTTP %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode JWKS failed: %w", err)
+	}
+
+	set := &jwkSet{keys: make(map[string]interface{}, len(doc.Keys)), fetched: time.Now()}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue // skip unsupported or malformed keys
+		}
+		set.keys[k.Kid] = pub
+	}
+	return set, nil
+}
+
+// jwk is a single JSON Web Key (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
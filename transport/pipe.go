@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"context"
+	"sync"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+)
+
+// Pipe returns two transports connected to each other in memory, for running a client
+// and a server in the same process. Each transport can be connected once.
+func Pipe() (clientT, serverT Transport) {
+	c := newPipeConn("client")
+	s := newPipeConn("server")
+	c.peer, s.peer = s, c
+	return &pipeTransport{conn: c}, &pipeTransport{conn: s}
+}
+
+type pipeTransport struct {
+	conn *pipeConn
+}
+
+func (t *pipeTransport) Connect(ctx context.Context) (Connection, error) {
+	return t.conn, nil
+}
+
+type pipeConn struct {
+	incoming  chan *protocol.JSONRPCMessage
+	done      chan struct{}
+	closeOnce sync.Once
+	peer      *pipeConn
+	session   string
+}
+
+func newPipeConn(session string) *pipeConn {
+	return &pipeConn{
+		incoming: make(chan *protocol.JSONRPCMessage, 64),
+		done:     make(chan struct{}),
+		session:  session,
+	}
+}
+
+func (c *pipeConn) Read(ctx context.Context) (*protocol.JSONRPCMessage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, ErrConnectionClosed
+	case msg := <-c.incoming:
+		return msg, nil
+	}
+}
+
+func (c *pipeConn) Write(ctx context.Context, msg *protocol.JSONRPCMessage) error {
+	select {
+	case <-c.done:
+		return ErrConnectionClosed
+	default:
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return ErrConnectionClosed
+	case <-c.peer.done:
+		return ErrConnectionClosed
+	case c.peer.incoming <- msg:
+		return nil
+	}
+}
+
+// Close closes both ends of the pipe
+func (c *pipeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	c.peer.closeOnce.Do(func() { close(c.peer.done) })
+	return nil
+}
+
+func (c *pipeConn) SessionID() string {
+	return c.session
+}
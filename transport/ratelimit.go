@@ -0,0 +1,129 @@
+package transport
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultIPRateLimitClients is the number of client IPs tracked when
+// IPRateLimitConfig.MaxClients is zero
+const DefaultIPRateLimitClients = 10000
+
+// IPRateLimitConfig configures per-IP rate limiting of the HTTP transports
+type IPRateLimitConfig struct {
+	// Limit is the sustained number of requests per second allowed from one IP
+	Limit rate.Limit
+
+	// Burst is the number of requests an IP may make at once
+	Burst int
+
+	// MaxClients caps the number of IPs tracked; the least recently seen are evicted first.
+	// Defaults to DefaultIPRateLimitClients.
+	MaxClients int
+
+	// TrustForwardedFor takes the client IP from the first X-Forwarded-For entry. Enable it
+	// only behind a proxy that sets the header, as clients can forge it otherwise.
+	TrustForwardedFor bool
+}
+
+// IPRateLimiter limits requests per client IP, see NewIPRateLimiter
+type IPRateLimiter struct {
+	cfg IPRateLimitConfig
+
+	mu       sync.Mutex
+	limiters map[string]*list.Element // IP -> element holding an *ipLimiter
+	lru      *list.List               // most recently seen first
+}
+
+type ipLimiter struct {
+	ip      string
+	limiter *rate.Limiter
+}
+
+// NewIPRateLimiter creates a limiter with a token bucket per client IP
+func NewIPRateLimiter(cfg IPRateLimitConfig) *IPRateLimiter {
+	if cfg.MaxClients <= 0 {
+		cfg.MaxClients = DefaultIPRateLimitClients
+	}
+	return &IPRateLimiter{
+		cfg:      cfg,
+		limiters: make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Allow reports whether r is within the limit of its client IP. Otherwise it answers
+// 429 Too Many Requests with a Retry-After header and returns false.
+func (l *IPRateLimiter) Allow(w http.ResponseWriter, r *http.Request) bool {
+	now := time.Now()
+	reservation := l.limiter(l.clientIP(r)).ReserveN(now, 1)
+
+	delay := time.Duration(math.MaxInt64)
+	if reservation.OK() {
+		delay = reservation.DelayFrom(now)
+	}
+	if delay == 0 {
+		return true
+	}
+	reservation.CancelAt(now)
+
+	retryAfter := 1
+	if delay < time.Duration(math.MaxInt64) {
+		retryAfter = max(int(math.Ceil(delay.Seconds())), 1)
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+	return false
+}
+
+func (l *IPRateLimiter) limiter(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.limiters[ip]; ok {
+		l.lru.MoveToFront(elem)
+		return elem.Value.(*ipLimiter).limiter
+	}
+
+	entry := &ipLimiter{ip: ip, limiter: rate.NewLimiter(l.cfg.Limit, l.cfg.Burst)}
+	l.limiters[ip] = l.lru.PushFront(entry)
+	for l.lru.Len() > l.cfg.MaxClients {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.limiters, oldest.Value.(*ipLimiter).ip)
+	}
+	return entry.limiter
+}
+
+func (l *IPRateLimiter) clientIP(r *http.Request) string {
+	if l.cfg.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	return RemoteIP(r)
+}
+
+// NewIPRateLimitMiddleware answers requests exceeding the per-IP limit of cfg with
+// 429 Too Many Requests
+func NewIPRateLimitMiddleware(cfg IPRateLimitConfig) func(http.Handler) http.Handler {
+	limiter := NewIPRateLimiter(cfg)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limiter.Allow(w, r) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+// Package record captures the messages exchanged over a transport and replays them,
+// so that integration tests can pin protocol behavior to a saved recording.
+package record
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/voocel/mcp-sdk-go/protocol"
+	"github.com/voocel/mcp-sdk-go/transport"
+)
+
+// Direction tells whether a recorded message was written or read by the recording side
+type Direction string
+
+const (
+	DirectionSend    Direction = "send"    // written to the peer
+	DirectionReceive Direction = "receive" // read from the peer
+)
+
+// TransportEvent is one recorded message
+type TransportEvent struct {
+	Direction Direction       `json:"direction"`
+	Time      time.Time       `json:"time"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// RecordingTransport wraps a transport and records every message read from or written
+// to its connection
+type RecordingTransport struct {
+	inner transport.Transport
+
+	mu     sync.Mutex
+	events []TransportEvent
+}
+
+// NewRecordingTransport records the traffic of inner
+func NewRecordingTransport(inner transport.Transport) *RecordingTransport {
+	return &RecordingTransport{inner: inner}
+}
+
+func (t *RecordingTransport) Connect(ctx context.Context) (transport.Connection, error) {
+	conn, err := t.inner.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{Connection: conn, t: t}, nil
+}
+
+// Events returns the messages recorded so far, in order
+func (t *RecordingTransport) Events() []TransportEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TransportEvent(nil), t.events...)
+}
+
+// Save writes the recording to w as newline-delimited JSON, one event per line
+func (t *RecordingTransport) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, e := range t.Events() {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("save recording: %w", err)
+		}
+	}
+	return nil
+}
+
+func (t *RecordingTransport) record(dir Direction, msg *protocol.JSONRPCMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	t.events = append(t.events, TransportEvent{Direction: dir, Time: time.Now(), Message: data})
+	t.mu.Unlock()
+}
+
+type recordingConn struct {
+	transport.Connection
+	t *RecordingTransport
+}
+
+func (c *recordingConn) Read(ctx context.Context) (*protocol.JSONRPCMessage, error) {
+	msg, err := c.Connection.Read(ctx)
+	if err == nil {
+		c.t.record(DirectionReceive, msg)
+	}
+	return msg, err
+}
+
+func (c *recordingConn) Write(ctx context.Context, msg *protocol.JSONRPCMessage) error {
+	err := c.Connection.Write(ctx, msg)
+	if err == nil {
+		c.t.record(DirectionSend, msg)
+	}
+	return err
+}
+
+// ErrReplayMismatch is returned by a replay connection when a written message does not
+// match the recording
+var ErrReplayMismatch = errors.New("message does not match the recording")
+
+// ReplayTransport plays back a recording made on the client side: the received messages
+// are returned by Read in recorded order, each once the messages sent before it in the
+// recording have been written. Written messages are only checked against the recording,
+// by method and ID, and fail with ErrReplayMismatch if they differ. After the last
+// recorded message Read blocks until the connection is closed.
+type ReplayTransport struct {
+	events []TransportEvent
+}
+
+// NewReplayTransport loads a recording written by RecordingTransport.Save
+func NewReplayTransport(r io.Reader) (*ReplayTransport, error) {
+	var events []TransportEvent
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var e TransportEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("load recording: %w", err)
+		}
+		if e.Direction != DirectionSend && e.Direction != DirectionReceive {
+			return nil, fmt.Errorf("load recording: invalid direction %q", e.Direction)
+		}
+		events = append(events, e)
+	}
+	return &ReplayTransport{events: events}, nil
+}
+
+func (t *ReplayTransport) Connect(ctx context.Context) (transport.Connection, error) {
+	return &replayConn{
+		events:  t.events,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+type replayConn struct {
+	events []TransportEvent
+
+	mu        sync.Mutex
+	next      int           // index of the next event to replay
+	changed   chan struct{} // closed when next advances
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *replayConn) Read(ctx context.Context) (*protocol.JSONRPCMessage, error) {
+	for {
+		c.mu.Lock()
+		var event *TransportEvent
+		if c.next < len(c.events) && c.events[c.next].Direction == DirectionReceive {
+			event = &c.events[c.next]
+			c.advanceLocked()
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		if event != nil {
+			var msg protocol.JSONRPCMessage
+			if err := json.Unmarshal(event.Message, &msg); err != nil {
+				return nil, fmt.Errorf("replay message: %w", err)
+			}
+			return &msg, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.done:
+			return nil, transport.ErrConnectionClosed
+		case <-changed:
+		}
+	}
+}
+
+func (c *replayConn) Write(ctx context.Context, msg *protocol.JSONRPCMessage) error {
+	for {
+		c.mu.Lock()
+		if c.next >= len(c.events) {
+			c.mu.Unlock()
+			return fmt.Errorf("%w: unexpected %s", ErrReplayMismatch, describe(msg))
+		}
+		if c.events[c.next].Direction == DirectionSend {
+			var want protocol.JSONRPCMessage
+			if err := json.Unmarshal(c.events[c.next].Message, &want); err != nil {
+				c.mu.Unlock()
+				return fmt.Errorf("replay message: %w", err)
+			}
+			if !sameMessage(msg, &want) {
+				c.mu.Unlock()
+				return fmt.Errorf("%w: got %s, want %s", ErrReplayMismatch, describe(msg), describe(&want))
+			}
+			c.advanceLocked()
+			c.mu.Unlock()
+			return nil
+		}
+		// A received message is due first
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.done:
+			return transport.ErrConnectionClosed
+		case <-changed:
+		}
+	}
+}
+
+func (c *replayConn) advanceLocked() {
+	c.next++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *replayConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return nil
+}
+
+func (c *replayConn) SessionID() string {
+	return ""
+}
+
+// sameMessage compares the kind, method and ID of two messages, including batch members
+func sameMessage(got, want *protocol.JSONRPCMessage) bool {
+	if got.IsBatch() != want.IsBatch() {
+		return false
+	}
+	if got.IsBatch() {
+		if len(got.Batch) != len(want.Batch) {
+			return false
+		}
+		for i := range got.Batch {
+			if !sameMessage(got.Batch[i], want.Batch[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return got.Method == want.Method &&
+		protocol.IDToString(got.ID) == protocol.IDToString(want.ID) &&
+		(got.Error == nil) == (want.Error == nil)
+}
+
+func describe(msg *protocol.JSONRPCMessage) string {
+	switch {
+	case msg.IsBatch():
+		return fmt.Sprintf("batch of %d", len(msg.Batch))
+	case msg.Method != "" && msg.IsNotification():
+		return fmt.Sprintf("notification %s", msg.Method)
+	case msg.Method != "":
+		return fmt.Sprintf("request %s (id %s)", msg.Method, protocol.IDToString(msg.ID))
+	default:
+		return fmt.Sprintf("response (id %s)", protocol.IDToString(msg.ID))
+	}
+}
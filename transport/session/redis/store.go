@@ -0,0 +1,107 @@
+// Package redis provides a server.SessionStore backed by Redis, allowing sessions to
+// survive reconnects to a different server instance behind a load balancer.
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/voocel/mcp-sdk-go/server"
+)
+
+const (
+	// DefaultKeyPrefix is prepended to session IDs to form Redis keys
+	DefaultKeyPrefix = "mcp:session:"
+
+	// DefaultTTL is how long a session's state is kept after it was last saved
+	DefaultTTL = 24 * time.Hour
+
+	defaultTimeout = 5 * time.Second
+)
+
+// Store is a server.SessionStore that keeps session state as JSON in Redis
+type Store struct {
+	client    goredis.UniversalClient
+	keyPrefix string
+	ttl       time.Duration
+	timeout   time.Duration
+}
+
+var _ server.SessionStore = (*Store)(nil)
+
+type Option func(*Store)
+
+// WithKeyPrefix sets the prefix of the Redis keys, e.g. to share a database between servers
+func WithKeyPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.keyPrefix = prefix
+	}
+}
+
+// WithTTL sets how long session state is kept after it was last saved; zero keeps it forever
+func WithTTL(ttl time.Duration) Option {
+	return func(s *Store) {
+		s.ttl = ttl
+	}
+}
+
+// WithTimeout bounds each Redis operation
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Store) {
+		s.timeout = timeout
+	}
+}
+
+// NewStore creates a session store using client
+func NewStore(client goredis.UniversalClient, options ...Option) *Store {
+	s := &Store{
+		client:    client,
+		keyPrefix: DefaultKeyPrefix,
+		ttl:       DefaultTTL,
+		timeout:   defaultTimeout,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+func (s *Store) Save(id string, state server.ServerSessionState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal session state: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	return s.client.Set(ctx, s.keyPrefix+id, data, s.ttl).Err()
+}
+
+func (s *Store) Load(id string) (*server.ServerSessionState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, s.keyPrefix+id).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state server.ServerSessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("unmarshal session state: %w", err)
+	}
+	return &state, nil
+}
+
+func (s *Store) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	return s.client.Del(ctx, s.keyPrefix+id).Err()
+}
@@ -0,0 +1,54 @@
+package sse
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewMetricsHandler registers SSE transport metrics with reg and returns a wrapper recording them:
+// mcp_sse_active_sessions (open event streams) and mcp_sse_bytes_sent_total.
+func NewMetricsHandler(reg prometheus.Registerer) func(http.Handler) http.Handler {
+	activeSessions := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mcp_sse_active_sessions",
+		Help: "Number of currently open MCP SSE event streams.",
+	})
+	bytesSent := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mcp_sse_bytes_sent_total",
+		Help: "Total number of bytes sent by the MCP SSE transport.",
+	})
+	reg.MustRegister(activeSessions, bytesSent)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Every GET opens an event stream for the lifetime of the request
+			if r.Method == http.MethodGet {
+				activeSessions.Inc()
+				defer activeSessions.Dec()
+			}
+			next.ServeHTTP(&countingResponseWriter{ResponseWriter: w, counter: bytesSent}, r)
+		})
+	}
+}
+
+// countingResponseWriter counts bytes written while still supporting streaming flushes
+type countingResponseWriter struct {
+	http.ResponseWriter
+	counter prometheus.Counter
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.counter.Add(float64(n))
+	return n, err
+}
+
+func (w *countingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
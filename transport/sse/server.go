@@ -3,8 +3,11 @@ package sse
 import (
 	"context"
 	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,10 +20,26 @@ import (
 	"github.com/voocel/mcp-sdk-go/transport"
 )
 
+// ContextExtractor derives the context of the messages of an HTTP request, typically by
+// adding values from its headers to r.Context(). Its values reach tool, resource and
+// prompt handlers; its cancellation does not.
+type ContextExtractor func(r *http.Request) context.Context
+
 type HTTPHandler struct {
 	serverFactory func(*http.Request) *server.Server
 	sessions      map[string]*serverSession
 	mu            sync.RWMutex
+	handler       http.Handler
+	cors          *transport.CORSConfig
+	maxBodyBytes  int64
+	ipRateLimiter *transport.IPRateLimiter
+	extractor     ContextExtractor
+
+	clientCAs         *x509.CertPool
+	requireClientCert bool
+
+	idleTimeout     time.Duration
+	cleanupInterval time.Duration
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -32,6 +51,10 @@ type serverSession struct {
 	Transport  *serverTransport
 	LastActive time.Time
 	mu         sync.RWMutex
+
+	server  *server.Server        // set once the MCP session is connected
+	session *server.ServerSession // set once the MCP session is connected
+	ended   bool                  // the MCP session ended; a reconnect with the same ID starts a new one
 }
 
 type serverTransport struct {
@@ -42,14 +65,86 @@ type serverTransport struct {
 	mu        sync.Mutex
 }
 
-func NewHTTPHandler(serverFactory func(*http.Request) *server.Server) *HTTPHandler {
+// HTTPHandlerOptions configures an HTTPHandler
+type HTTPHandlerOptions struct {
+	// AuthMiddleware wraps every request, e.g. NewAPIKeyMiddleware
+	AuthMiddleware func(http.Handler) http.Handler
+
+	// CORS enables Origin validation and CORS headers, including preflight OPTIONS handling.
+	// Nil accepts requests from any origin.
+	CORS *transport.CORSConfig
+
+	// MaxRequestBodyBytes limits the size of POSTed messages. Defaults to DefaultMaxRequestBodyBytes.
+	MaxRequestBodyBytes int64
+
+	// SessionIdleTimeout evicts sessions without any activity for this long.
+	// Defaults to DefaultSessionIdleTimeout.
+	SessionIdleTimeout time.Duration
+
+	// CleanupInterval is how often idle sessions are evicted. Defaults to DefaultCleanupInterval.
+	CleanupInterval time.Duration
+
+	// ClientCAs verifies client certificates for mutual TLS, see HTTPHandler.TLSConfig.
+	// The common name of a verified certificate becomes the client identifier returned by
+	// transport.ClientIDFromContext.
+	ClientCAs *x509.CertPool
+
+	// RequireClientCert rejects connections without a client certificate verified by
+	// ClientCAs, and requests that did not present one with 401 Unauthorized
+	RequireClientCert bool
+
+	// IPRateLimit limits requests per client IP before any other processing, including
+	// CORS and authentication. Excess requests receive 429 Too Many Requests.
+	IPRateLimit *transport.IPRateLimitConfig
+
+	// ContextExtractor adds values of each HTTP request, such as a tenant ID from a header,
+	// to the context of the handlers processing its messages
+	ContextExtractor ContextExtractor
+}
+
+func NewHTTPHandler(serverFactory func(*http.Request) *server.Server, opts ...*HTTPHandlerOptions) *HTTPHandler {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	h := &HTTPHandler{
 		serverFactory: serverFactory,
 		sessions:      make(map[string]*serverSession),
+		maxBodyBytes:  DefaultMaxRequestBodyBytes,
 		ctx:           ctx,
 		cancel:        cancel,
+
+		idleTimeout:     DefaultSessionIdleTimeout,
+		cleanupInterval: DefaultCleanupInterval,
+	}
+	h.handler = http.HandlerFunc(h.serveHTTP)
+	if len(opts) > 0 && opts[0] != nil {
+		if opts[0].AuthMiddleware != nil {
+			h.handler = opts[0].AuthMiddleware(h.handler)
+		}
+		if opts[0].MaxRequestBodyBytes > 0 {
+			h.maxBodyBytes = opts[0].MaxRequestBodyBytes
+		}
+		if opts[0].SessionIdleTimeout > 0 {
+			h.idleTimeout = opts[0].SessionIdleTimeout
+		}
+		if opts[0].CleanupInterval > 0 {
+			h.cleanupInterval = opts[0].CleanupInterval
+		}
+		h.clientCAs = opts[0].ClientCAs
+		h.requireClientCert = opts[0].RequireClientCert
+		if opts[0].IPRateLimit != nil {
+			h.ipRateLimiter = transport.NewIPRateLimiter(*opts[0].IPRateLimit)
+		}
+		h.extractor = opts[0].ContextExtractor
+		if opts[0].CORS != nil {
+			cors := *opts[0].CORS
+			if cors.AllowedHeaders == nil {
+				cors.AllowedHeaders = []string{"Content-Type", "Accept", "Authorization", MCPSessionIDHeader, MCPSessionTokenHeader, MCPProtocolVersionHeader}
+			}
+			if cors.ExposedHeaders == nil {
+				cors.ExposedHeaders = []string{MCPSessionIDHeader, MCPProtocolVersionHeader}
+			}
+			h.cors = &cors
+		}
 	}
 
 	h.wg.Add(1)
@@ -62,6 +157,47 @@ func NewHTTPHandler(serverFactory func(*http.Request) *server.Server) *HTTPHandl
 }
 
 func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.ipRateLimiter != nil && !h.ipRateLimiter.Allow(w, r) {
+		return
+	}
+	// CORS runs before authentication since browsers send preflight requests without credentials
+	if h.cors != nil && h.cors.ServeCORS(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	ctx := transport.ContextWithClientIP(r.Context(), transport.RemoteIP(r))
+	if cn, ok := verifiedClientCN(r); ok {
+		ctx = transport.ContextWithClientID(ctx, cn)
+	} else if h.requireClientCert {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// TLSConfig returns the TLS configuration verifying client certificates as configured by
+// HTTPHandlerOptions.ClientCAs and RequireClientCert. Add the server certificate and use
+// it as the http.Server's TLSConfig.
+func (h *HTTPHandler) TLSConfig() *tls.Config {
+	cfg := &tls.Config{ClientCAs: h.clientCAs}
+	switch {
+	case h.requireClientCert:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	case h.clientCAs != nil:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg
+}
+
+// verifiedClientCN returns the common name of the client certificate verified during the
+// TLS handshake
+func verifiedClientCN(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+}
+
+func (h *HTTPHandler) serveHTTP(w http.ResponseWriter, r *http.Request) {
 	h.checkProtocolVersion(r)
 
 	switch r.Method {
@@ -116,7 +252,9 @@ func (h *HTTPHandler) handleSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if h.cors == nil {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	}
 	w.Header().Set(MCPSessionIDHeader, session.ID)
 	w.Header().Set(MCPProtocolVersionHeader, DefaultProtocolVersion)
 
@@ -146,9 +284,7 @@ func (h *HTTPHandler) handleSSE(w http.ResponseWriter, r *http.Request) {
 			fmt.Fprintf(w, "event: message\ndata: %s\n\n", event)
 			flusher.Flush()
 
-			session.mu.Lock()
-			session.LastActive = time.Now()
-			session.mu.Unlock()
+			session.touch()
 		}
 	}
 }
@@ -164,14 +300,30 @@ func (h *HTTPHandler) handleMessage(w http.ResponseWriter, r *http.Request) {
 	h.mu.RLock()
 	session, exists := h.sessions[sessionID]
 	h.mu.RUnlock()
+	var mcpSession *server.ServerSession
+	if exists {
+		session.mu.RLock()
+		exists = !session.ended
+		mcpSession = session.session
+		session.mu.RUnlock()
+	}
 
 	if !exists {
 		h.sendJSONRPCError(w, "", protocol.InvalidParams, "Invalid session ID", nil)
 		return
 	}
+	if mcpSession != nil && !mcpSession.ValidateToken(r.Header.Get(MCPSessionTokenHeader)) {
+		writeJSONRPCError(w, http.StatusUnauthorized, "", protocol.InvalidRequest, "Invalid session token", nil)
+		return
+	}
 
-	body, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
 	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeJSONRPCError(w, http.StatusRequestEntityTooLarge, "", protocol.ParseError, "Request body too large", nil)
+			return
+		}
 		h.sendJSONRPCError(w, "", protocol.ParseError, "Failed to read request body", nil)
 		return
 	}
@@ -181,22 +333,25 @@ func (h *HTTPHandler) handleMessage(w http.ResponseWriter, r *http.Request) {
 		h.sendJSONRPCError(w, "", protocol.ParseError, "Invalid JSON-RPC format", nil)
 		return
 	}
+	msg := &message
+	if h.extractor != nil {
+		// The message is handled after this request completes, keep only the values
+		msg = message.WithContext(context.WithoutCancel(h.extractor(r)))
+	}
 
 	// Return 202 Accepted immediately, response will be sent via SSE
 	w.Header().Set(MCPSessionIDHeader, session.ID)
 	w.WriteHeader(http.StatusAccepted)
 
 	select {
-	case session.Transport.incoming <- &message:
+	case session.Transport.incoming <- msg:
 		// Message sent
 	default:
 		// Buffer full
 		fmt.Printf("Session %s buffer full, dropping message\n", sessionID)
 	}
 
-	session.mu.Lock()
-	session.LastActive = time.Now()
-	session.mu.Unlock()
+	session.touch()
 }
 
 func (h *HTTPHandler) getOrCreateSession(sessionID string, r *http.Request) *serverSession {
@@ -204,6 +359,12 @@ func (h *HTTPHandler) getOrCreateSession(sessionID string, r *http.Request) *ser
 	defer h.mu.Unlock()
 
 	session, exists := h.sessions[sessionID]
+	if exists {
+		session.mu.RLock()
+		ended := session.ended
+		session.mu.RUnlock()
+		exists = !ended
+	}
 	if !exists {
 		transport := &serverTransport{
 			sessionID: sessionID,
@@ -221,17 +382,31 @@ func (h *HTTPHandler) getOrCreateSession(sessionID string, r *http.Request) *ser
 
 		go h.handleServerSession(r.Context(), session, r)
 	} else {
-		session.mu.Lock()
-		session.LastActive = time.Now()
-		session.mu.Unlock()
+		session.touch()
 	}
 
 	return session
 }
 
+// touch records activity on the session, postponing its eviction
+func (s *serverSession) touch() {
+	s.mu.Lock()
+	s.LastActive = time.Now()
+	s.mu.Unlock()
+}
+
 // handleServerSession handles the server session
 func (h *HTTPHandler) handleServerSession(ctx context.Context, session *serverSession, r *http.Request) {
 	mcpServer := h.serverFactory(r)
+	session.mu.Lock()
+	session.server = mcpServer
+	session.mu.Unlock()
+	defer func() {
+		session.mu.Lock()
+		session.ended = true
+		session.mu.Unlock()
+		session.touch()
+	}()
 
 	serverSession, err := mcpServer.Connect(ctx, session.Transport, nil)
 	if err != nil {
@@ -239,6 +414,9 @@ func (h *HTTPHandler) handleServerSession(ctx context.Context, session *serverSe
 		return
 	}
 	defer serverSession.Close()
+	session.mu.Lock()
+	session.session = serverSession
+	session.mu.Unlock()
 
 	if err := serverSession.Wait(); err != nil {
 		fmt.Printf("Server session error: %v\n", err)
@@ -247,7 +425,7 @@ func (h *HTTPHandler) handleServerSession(ctx context.Context, session *serverSe
 
 // cleanupSessions cleans up expired sessions
 func (h *HTTPHandler) cleanupSessions() {
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(h.cleanupInterval)
 	defer ticker.Stop()
 
 	for {
@@ -259,9 +437,13 @@ func (h *HTTPHandler) cleanupSessions() {
 			now := time.Now()
 			for id, session := range h.sessions {
 				session.mu.RLock()
-				if now.Sub(session.LastActive) > 10*time.Minute {
+				if now.Sub(session.LastActive) > h.idleTimeout {
 					session.Transport.Close()
 					delete(h.sessions, id)
+					// The client is gone for good, drop any persisted session state
+					if session.server != nil {
+						_ = session.server.ForgetSession(id)
+					}
 				}
 				session.mu.RUnlock()
 			}
@@ -272,6 +454,10 @@ func (h *HTTPHandler) cleanupSessions() {
 
 // sendJSONRPCError sends a JSON-RPC error response
 func (h *HTTPHandler) sendJSONRPCError(w http.ResponseWriter, id string, code int, message string, data interface{}) {
+	writeJSONRPCError(w, http.StatusBadRequest, id, code, message, data)
+}
+
+func writeJSONRPCError(w http.ResponseWriter, status int, id string, code int, message string, data interface{}) {
 	errorResp := protocol.JSONRPCMessage{
 		JSONRPC: "2.0",
 		ID:      protocol.StringToID(id),
@@ -283,7 +469,7 @@ func (h *HTTPHandler) sendJSONRPCError(w http.ResponseWriter, id string, code in
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(errorResp)
 }
 
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -22,6 +24,18 @@ const (
 	MCPProtocolVersionHeader = "MCP-Protocol-Version"
 	MCPSessionIDHeader       = "MCP-Session-Id"
 	DefaultProtocolVersion   = "2025-11-25"
+
+	// MCPSessionTokenHeader carries the session token rotated by the server, see
+	// server.ServerSession.RotateToken
+	MCPSessionTokenHeader = "MCP-Session-Token"
+
+	DefaultMaxRequestBodyBytes = 4 << 20 // 4 MiB
+
+	// DefaultSessionIdleTimeout is how long a session may stay inactive before it is evicted
+	DefaultSessionIdleTimeout = 10 * time.Minute
+
+	// DefaultCleanupInterval is how often idle sessions are looked for
+	DefaultCleanupInterval = 5 * time.Minute
 )
 
 type SSETransport struct {
@@ -29,6 +43,9 @@ type SSETransport struct {
 	client          *http.Client
 	protocolVersion string
 	sessionID       string
+	tlsConfig       *tls.Config
+	clientCerts     []tls.Certificate
+	rootCAs         *x509.CertPool
 }
 
 type Option func(*SSETransport)
@@ -51,6 +68,34 @@ func WithHTTPClient(client *http.Client) Option {
 	}
 }
 
+// WithHTTPClientConfig uses an HTTP client with the given connection pool settings
+func WithHTTPClientConfig(cfg transport.ClientHTTPConfig) Option {
+	return func(t *SSETransport) {
+		t.client = transport.NewHTTPClient(cfg)
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for HTTPS connections
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(t *SSETransport) {
+		t.tlsConfig = cfg
+	}
+}
+
+// WithClientCertificate presents cert to servers requiring mutual TLS
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(t *SSETransport) {
+		t.clientCerts = append(t.clientCerts, cert)
+	}
+}
+
+// WithRootCAs verifies the server certificate against pool instead of the system roots
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(t *SSETransport) {
+		t.rootCAs = pool
+	}
+}
+
 func NewSSETransport(urlStr string, options ...Option) (*SSETransport, error) {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
@@ -68,14 +113,46 @@ func NewSSETransport(urlStr string, options ...Option) (*SSETransport, error) {
 		option(t)
 	}
 
+	if t.clientCerts != nil || t.rootCAs != nil {
+		// Leave a config passed to WithTLSConfig untouched
+		cfg := &tls.Config{}
+		if t.tlsConfig != nil {
+			cfg = t.tlsConfig.Clone()
+		}
+		cfg.Certificates = append(cfg.Certificates, t.clientCerts...)
+		if t.rootCAs != nil {
+			cfg.RootCAs = t.rootCAs
+		}
+		t.tlsConfig = cfg
+	}
+	if t.tlsConfig != nil {
+		t.client = withTLSConfig(t.client, t.tlsConfig)
+	}
+
 	return t, nil
 }
 
+// withTLSConfig returns a copy of client whose transport uses cfg, leaving the original untouched
+func withTLSConfig(client *http.Client, cfg *tls.Config) *http.Client {
+	var base *http.Transport
+	if tr, ok := client.Transport.(*http.Transport); ok {
+		base = tr.Clone()
+	} else {
+		base = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	base.TLSClientConfig = cfg
+
+	copied := *client
+	copied.Transport = base
+	return &copied
+}
+
 func (t *SSETransport) Connect(ctx context.Context) (transport.Connection, error) {
 	conn := &sseConnection{
 		transport:     t,
 		sessionID:     t.sessionID,
 		incoming:      make(chan *protocol.JSONRPCMessage, 10),
+		done:          make(chan struct{}),
 		endpointReady: make(chan struct{}),
 	}
 
@@ -108,11 +185,20 @@ type sseConnection struct {
 	endpointOnce  sync.Once
 
 	incoming  chan *protocol.JSONRPCMessage
+	done      chan struct{} // closed with the connection; incoming is never closed
 	closed    atomic.Bool
 	closeOnce sync.Once
 	closeFunc func() error
 
-	mu sync.RWMutex
+	mu    sync.RWMutex
+	token string // latest session token, see SetSessionToken
+}
+
+// SetSessionToken sends token with every subsequent message
+func (c *sseConnection) SetSessionToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
 }
 
 func (c *sseConnection) Read(ctx context.Context) (*protocol.JSONRPCMessage, error) {
@@ -123,11 +209,10 @@ func (c *sseConnection) Read(ctx context.Context) (*protocol.JSONRPCMessage, err
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
-	case msg, ok := <-c.incoming:
-		if !ok {
-			return nil, transport.ErrConnectionClosed
-		}
+	case msg := <-c.incoming:
 		return msg, nil
+	case <-c.done:
+		return nil, transport.ErrConnectionClosed
 	}
 }
 
@@ -138,6 +223,7 @@ func (c *sseConnection) Write(ctx context.Context, msg *protocol.JSONRPCMessage)
 
 	c.mu.RLock()
 	endpoint := c.endpoint
+	token := c.token
 	c.mu.RUnlock()
 
 	if endpoint == nil {
@@ -158,6 +244,9 @@ func (c *sseConnection) Write(ctx context.Context, msg *protocol.JSONRPCMessage)
 	req.Header.Set("Accept", "application/json, text/event-stream")
 	req.Header.Set(MCPProtocolVersionHeader, c.transport.protocolVersion)
 	req.Header.Set(MCPSessionIDHeader, c.sessionID)
+	if token != "" {
+		req.Header.Set(MCPSessionTokenHeader, token)
+	}
 
 	resp, err := c.transport.client.Do(req)
 	if err != nil {
@@ -183,6 +272,8 @@ func (c *sseConnection) Write(ctx context.Context, msg *protocol.JSONRPCMessage)
 	select {
 	case c.incoming <- &response:
 		return nil
+	case <-c.done:
+		return transport.ErrConnectionClosed
 	case <-ctx.Done():
 		return ctx.Err()
 	}
@@ -195,7 +286,7 @@ func (c *sseConnection) Close() error {
 			if c.closeFunc != nil {
 				err = c.closeFunc()
 			}
-			close(c.incoming)
+			close(c.done)
 		}
 	})
 	return err
@@ -246,7 +337,7 @@ func (c *sseConnection) processEvents(ctx context.Context, body io.ReadCloser) {
 	defer body.Close()
 	defer func() {
 		if c.closed.CompareAndSwap(false, true) {
-			close(c.incoming)
+			close(c.done)
 		}
 	}()
 
@@ -60,6 +60,12 @@ func (c *stdioConn) Read(ctx context.Context) (*protocol.JSONRPCMessage, error)
 		return nil, err
 	case msg, ok := <-c.incoming:
 		if !ok {
+			// The read loop reports why it stopped before closing incoming
+			select {
+			case err := <-c.errs:
+				return nil, err
+			default:
+			}
 			return nil, transport.ErrConnectionClosed
 		}
 		return msg, nil
@@ -0,0 +1,27 @@
+package streamable
+
+import (
+	"net/http"
+
+	"github.com/voocel/mcp-sdk-go/transport"
+	"golang.org/x/time/rate"
+)
+
+// NewAPIKeyMiddleware returns an API key authentication middleware for the Streamable HTTP handler.
+// See transport.NewAPIKeyMiddleware for details.
+func NewAPIKeyMiddleware(keys []string, header string) func(http.Handler) http.Handler {
+	return transport.NewAPIKeyMiddleware(keys, header)
+}
+
+// NewJWTMiddleware returns a JWT authentication middleware for the Streamable HTTP handler.
+// See transport.NewJWTMiddleware for details.
+func NewJWTMiddleware(cfg transport.JWTConfig) func(http.Handler) http.Handler {
+	return transport.NewJWTMiddleware(cfg)
+}
+
+// NewIPRateLimitMiddleware returns a per-IP rate limiting middleware for the handler,
+// tracking up to transport.DefaultIPRateLimitClients IPs. Use HTTPHandlerOptions.IPRateLimit
+// to apply the limit before any other processing or to trust X-Forwarded-For.
+func NewIPRateLimitMiddleware(limit rate.Limit, burst int) func(http.Handler) http.Handler {
+	return transport.NewIPRateLimitMiddleware(transport.IPRateLimitConfig{Limit: limit, Burst: burst})
+}
@@ -39,6 +39,13 @@ func WithHTTPClient(client *http.Client) ClientOption {
 	}
 }
 
+// WithHTTPClientConfig uses an HTTP client with the given connection pool settings.
+func WithHTTPClientConfig(cfg transport.ClientHTTPConfig) ClientOption {
+	return func(t *StreamableClientTransport) {
+		t.HTTPClient = transport.NewHTTPClient(cfg)
+	}
+}
+
 // WithMaxRetries sets the maximum number of SSE reconnect attempts.
 func WithMaxRetries(n int) ClientOption {
 	return func(t *StreamableClientTransport) {
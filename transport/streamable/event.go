@@ -194,12 +194,14 @@ func (dl *dataList) removeFirst() int {
 	return n
 }
 
-// MemoryEventStore is an in-memory EventStore with a global size limit.
+// MemoryEventStore is an in-memory EventStore with a global size limit
+// and an optional limit on the number of events kept per stream.
 type MemoryEventStore struct {
-	mu       sync.Mutex
-	maxBytes int
-	nBytes   int
-	store    map[string]map[string]*dataList
+	mu        sync.Mutex
+	maxBytes  int
+	maxEvents int
+	nBytes    int
+	store     map[string]map[string]*dataList
 }
 
 const defaultMaxEventBytes = 20 << 20 // 20 MiB
@@ -231,6 +233,34 @@ func (s *MemoryEventStore) SetMaxBytes(n int) {
 	s.purgeLocked()
 }
 
+// MaxEvents returns the maximum number of events retained per stream, 0 meaning no limit.
+func (s *MemoryEventStore) MaxEvents() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxEvents
+}
+
+// SetMaxEvents bounds each stream to its n most recent events, making it a ring buffer.
+// Older events are dropped and can no longer be replayed. n <= 0 removes the limit.
+func (s *MemoryEventStore) SetMaxEvents(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	s.maxEvents = n
+	if n == 0 {
+		return
+	}
+	for _, streams := range s.store {
+		for _, dl := range streams {
+			for len(dl.data) > n {
+				s.nBytes -= dl.removeFirst()
+			}
+		}
+	}
+}
+
 func (s *MemoryEventStore) Open(_ context.Context, sessionID, streamID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -244,6 +274,9 @@ func (s *MemoryEventStore) Append(_ context.Context, sessionID, streamID string,
 	dl := s.initLocked(sessionID, streamID)
 	idx := dl.appendData(data)
 	s.nBytes += len(data)
+	if s.maxEvents > 0 && len(dl.data) > s.maxEvents {
+		s.nBytes -= dl.removeFirst()
+	}
 	s.purgeLocked()
 	return idx, nil
 }
@@ -10,10 +10,12 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/voocel/mcp-sdk-go/protocol"
 	"github.com/voocel/mcp-sdk-go/server"
+	"github.com/voocel/mcp-sdk-go/transport"
 )
 
 const (
@@ -21,53 +23,196 @@ const (
 	MCPSessionIDHeader       = "Mcp-Session-Id"
 	LastEventIDHeader        = "Last-Event-ID"
 	DefaultProtocolVersion   = "2025-11-25"
-	DefaultMaxBodyBytes      = 10 << 20 // 10 MiB
+	DefaultMaxBodyBytes      = 4 << 20 // 4 MiB
+
+	// DefaultSessionIdleTimeout is how long a session may stay inactive before it is evicted
+	DefaultSessionIdleTimeout = 30 * time.Minute
+
+	// DefaultCleanupInterval is how often idle sessions are looked for
+	DefaultCleanupInterval = 5 * time.Minute
+
+	// DefaultEventBufferSize is how many events are kept per stream for replay after Last-Event-ID
+	DefaultEventBufferSize = 100
 )
 
+// ContextExtractor derives the context of the messages of an HTTP request, typically by
+// adding values from its headers to r.Context(). Its values reach tool, resource and
+// prompt handlers; its cancellation does not.
+type ContextExtractor func(r *http.Request) context.Context
+
 // HTTPHandler handles Streamable HTTP MCP requests.
 type HTTPHandler struct {
 	serverFactory   func(*http.Request) *server.Server
 	writerFactory   StreamWriterFactory
 	protocolVersion string
 	maxBodyBytes    int64
+	idleTimeout     time.Duration
+	cleanupInterval time.Duration
+	http2Push       bool
 
-	// Origin validation for DNS rebinding protection
-	allowedOrigins map[string]bool
-	validateOrigin bool
+	// Origin validation for DNS rebinding protection and CORS headers
+	cors *transport.CORSConfig
+
+	ipRateLimiter *transport.IPRateLimiter
+	extractor     ContextExtractor
 
 	mu       sync.RWMutex
 	sessions map[string]*sessionState
+
+	handler http.Handler
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 type sessionState struct {
 	server     *server.Server
-	lastActive time.Time
+	lastActive atomic.Int64 // unix nanoseconds
+	pushed     atomic.Bool  // standalone SSE stream push attempted
+}
+
+// touch records activity on the session, postponing its eviction
+func (s *sessionState) touch() {
+	s.lastActive.Store(time.Now().UnixNano())
+}
+
+// idleFor reports how long the session has been inactive
+func (s *sessionState) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, s.lastActive.Load()))
+}
+
+// HTTPHandlerOptions configures an HTTPHandler.
+type HTTPHandlerOptions struct {
+	// AuthMiddleware wraps every request, e.g. NewAPIKeyMiddleware.
+	AuthMiddleware func(http.Handler) http.Handler
+
+	// CORS enables Origin validation and CORS headers, including preflight OPTIONS handling.
+	// Nil accepts requests from any origin without CORS headers.
+	CORS *transport.CORSConfig
+
+	// MaxRequestBodyBytes limits the size of POSTed messages. Defaults to DefaultMaxBodyBytes.
+	MaxRequestBodyBytes int64
+
+	// SessionIdleTimeout evicts sessions without any activity for this long.
+	// Defaults to DefaultSessionIdleTimeout.
+	SessionIdleTimeout time.Duration
+
+	// CleanupInterval is how often idle sessions are evicted. Defaults to DefaultCleanupInterval.
+	CleanupInterval time.Duration
+
+	// EventBufferSize is how many recent events of each stream are kept so that a client
+	// reconnecting with Last-Event-ID receives the events it missed. Defaults to DefaultEventBufferSize.
+	// It applies to the default writer factory only, not to one set with SetWriterFactory.
+	EventBufferSize int
+
+	// EnableHTTP2Push pushes the session's standalone SSE stream (the GET stream carrying
+	// server-initiated messages) to HTTP/2 clients along with their first POST after
+	// initialization, so they need not open it themselves. Clients that refuse pushes
+	// are unaffected and keep using GET.
+	EnableHTTP2Push bool
+
+	// IPRateLimit limits requests per client IP before any other processing, including
+	// CORS and authentication. Excess requests receive 429 Too Many Requests.
+	IPRateLimit *transport.IPRateLimitConfig
+
+	// ContextExtractor adds values of each HTTP request, such as a tenant ID from a header,
+	// to the context of the handlers processing its messages
+	ContextExtractor ContextExtractor
 }
 
 // NewHTTPHandler creates a new handler with the given server factory.
-func NewHTTPHandler(serverFactory func(*http.Request) *server.Server) *HTTPHandler {
+func NewHTTPHandler(serverFactory func(*http.Request) *server.Server, opts ...*HTTPHandlerOptions) *HTTPHandler {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := NewMemoryEventStore()
+	store.SetMaxEvents(DefaultEventBufferSize)
 	h := &HTTPHandler{
 		serverFactory:   serverFactory,
-		writerFactory:   NewResumableWriterFactory(NewMemoryEventStore()),
+		writerFactory:   NewResumableWriterFactory(store),
 		protocolVersion: DefaultProtocolVersion,
 		maxBodyBytes:    DefaultMaxBodyBytes,
-		allowedOrigins:  make(map[string]bool),
-		validateOrigin:  false,
+		idleTimeout:     DefaultSessionIdleTimeout,
+		cleanupInterval: DefaultCleanupInterval,
 		sessions:        make(map[string]*sessionState),
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+	h.handler = http.HandlerFunc(h.serveHTTP)
+	if len(opts) > 0 && opts[0] != nil {
+		if opts[0].AuthMiddleware != nil {
+			h.handler = opts[0].AuthMiddleware(h.handler)
+		}
+		if opts[0].CORS != nil {
+			h.SetCORS(*opts[0].CORS)
+		}
+		if opts[0].MaxRequestBodyBytes > 0 {
+			h.maxBodyBytes = opts[0].MaxRequestBodyBytes
+		}
+		if opts[0].SessionIdleTimeout > 0 {
+			h.idleTimeout = opts[0].SessionIdleTimeout
+		}
+		if opts[0].CleanupInterval > 0 {
+			h.cleanupInterval = opts[0].CleanupInterval
+		}
+		if opts[0].EventBufferSize > 0 {
+			store.SetMaxEvents(opts[0].EventBufferSize)
+		}
+		h.http2Push = opts[0].EnableHTTP2Push
+		if opts[0].IPRateLimit != nil {
+			h.ipRateLimiter = transport.NewIPRateLimiter(*opts[0].IPRateLimit)
+		}
+		h.extractor = opts[0].ContextExtractor
 	}
-	go h.cleanupLoop()
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.cleanupLoop()
+	}()
 	return h
 }
 
+// Shutdown stops evicting idle sessions and closes all sessions.
+func (h *HTTPHandler) Shutdown(ctx context.Context) error {
+	h.cancel()
+	h.wg.Wait()
+
+	h.mu.Lock()
+	ids := make([]string, 0, len(h.sessions))
+	for id := range h.sessions {
+		ids = append(ids, id)
+	}
+	h.sessions = make(map[string]*sessionState)
+	h.mu.Unlock()
+
+	for _, id := range ids {
+		h.writerFactory.OnSessionClose(ctx, id)
+	}
+	return ctx.Err()
+}
+
 // SetAllowedOrigins enables Origin validation and sets the allowed origins.
 // This is required to prevent DNS rebinding attacks per the MCP specification.
 // Pass nil or empty slice to disable validation.
+// It is shorthand for SetCORS with only AllowedOrigins set.
 func (h *HTTPHandler) SetAllowedOrigins(origins []string) {
-	h.allowedOrigins = make(map[string]bool)
-	for _, o := range origins {
-		h.allowedOrigins[o] = true
+	if len(origins) == 0 {
+		h.cors = nil
+		return
 	}
-	h.validateOrigin = len(origins) > 0
+	h.SetCORS(transport.CORSConfig{AllowedOrigins: origins})
+}
+
+// SetCORS sets the CORS policy. Requests from origins outside cfg.AllowedOrigins are rejected.
+// Empty AllowedHeaders and ExposedHeaders default to the headers used by the MCP protocol.
+func (h *HTTPHandler) SetCORS(cfg transport.CORSConfig) {
+	if cfg.AllowedHeaders == nil {
+		cfg.AllowedHeaders = []string{"Content-Type", "Accept", "Authorization", MCPSessionIDHeader, MCPProtocolVersionHeader, LastEventIDHeader}
+	}
+	if cfg.ExposedHeaders == nil {
+		cfg.ExposedHeaders = []string{MCPSessionIDHeader, MCPProtocolVersionHeader}
+	}
+	h.cors = &cfg
 }
 
 // SetWriterFactory sets the factory used to create stream writers.
@@ -82,12 +227,19 @@ func (h *HTTPHandler) SetMaxBodyBytes(n int64) {
 }
 
 func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Origin validation to prevent DNS rebinding attacks (MCP spec requirement)
-	if h.validateOrigin && !h.checkOrigin(r) {
-		http.Error(w, "Forbidden: invalid origin", http.StatusForbidden)
+	if h.ipRateLimiter != nil && !h.ipRateLimiter.Allow(w, r) {
 		return
 	}
+	// Origin validation to prevent DNS rebinding attacks (MCP spec requirement).
+	// Runs before authentication since browsers send preflight requests without credentials.
+	if h.cors != nil && h.cors.ServeCORS(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	r = r.WithContext(transport.ContextWithClientIP(r.Context(), transport.RemoteIP(r)))
+	h.handler.ServeHTTP(w, r)
+}
 
+func (h *HTTPHandler) serveHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set(MCPProtocolVersionHeader, h.protocolVersion)
 
 	switch r.Method {
@@ -102,17 +254,6 @@ func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// checkOrigin validates the Origin header against allowed origins.
-// Returns true if the request is allowed.
-func (h *HTTPHandler) checkOrigin(r *http.Request) bool {
-	origin := r.Header.Get("Origin")
-	if origin == "" {
-		// No Origin header (non-browser request) - allow
-		return true
-	}
-	return h.allowedOrigins[origin]
-}
-
 func (h *HTTPHandler) handlePost(w http.ResponseWriter, r *http.Request) {
 	// Validate request
 	if r.Header.Get(LastEventIDHeader) != "" {
@@ -157,47 +298,71 @@ func (h *HTTPHandler) handlePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	if h.http2Push && !isInitialize {
+		h.pushEventStream(w, r, session, sessionID)
+	}
+
+	ctx := r.Context()
+	if h.extractor != nil {
+		ctx = transport.MergeContextValues(ctx, h.extractor(r))
+	}
 
 	// Handle notification (no response needed)
 	if msg.ID == nil && msg.Method != "" {
-		_, _ = session.server.HandleMessage(r.Context(), &msg)
+		_, _ = session.server.HandleMessage(ctx, &msg)
 		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 
 	// Handle request
-	h.handleRequest(w, r, session, sessionID, &msg, isInitialize)
+	h.handleRequest(ctx, w, r, session, sessionID, &msg, isInitialize)
 }
 
-func (h *HTTPHandler) handleRequest(w http.ResponseWriter, r *http.Request, session *sessionState, sessionID string, msg *protocol.JSONRPCMessage, isInitialize bool) {
+func (h *HTTPHandler) handleRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, session *sessionState, sessionID string, msg *protocol.JSONRPCMessage, isInitialize bool) {
 	wantsStream := acceptsEventStream(r)
 
+	// Set session ID header if initialize
+	if isInitialize {
+		w.Header().Set(MCPSessionIDHeader, sessionID)
+	}
+
+	// Notifications emitted while handling the request are streamed ahead of the response
+	var stream *responseStream
+	if wantsStream {
+		stream = &responseStream{h: h, w: w, r: r, sessionID: sessionID}
+		defer stream.close()
+		ctx = server.ContextWithNotificationSender(ctx, stream.notify)
+	}
+
 	// Process message
-	response, err := session.server.HandleMessage(r.Context(), msg)
+	response, err := session.server.HandleMessage(ctx, msg)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if stream == nil || !stream.started() {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
 	if response == nil {
-		w.WriteHeader(http.StatusAccepted)
+		if stream == nil || !stream.started() {
+			w.WriteHeader(http.StatusAccepted)
+		}
 		return
 	}
 
 	data, err := json.Marshal(response)
 	if err != nil {
-		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		if stream == nil || !stream.started() {
+			http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	// Set session ID header if initialize
-	if isInitialize {
-		w.Header().Set(MCPSessionIDHeader, sessionID)
-	}
-
 	// Respond based on client preference
 	if wantsStream {
-		h.respondSSE(w, r, sessionID, data)
+		if err := stream.write(data, true); err != nil && !stream.started() {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 	} else {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -205,17 +370,64 @@ func (h *HTTPHandler) handleRequest(w http.ResponseWriter, r *http.Request, sess
 	}
 }
 
-func (h *HTTPHandler) respondSSE(w http.ResponseWriter, r *http.Request, sessionID string, data []byte) {
-	writer := h.writerFactory.Create(sessionID)
-	defer writer.Close()
+// responseStream is the SSE stream of a single POST response.
+// It is opened lazily so that requests without notifications keep their plain status codes.
+type responseStream struct {
+	h         *HTTPHandler
+	w         http.ResponseWriter
+	r         *http.Request
+	sessionID string
+
+	mu     sync.Mutex
+	writer StreamWriter
+	ready  bool
+}
 
-	streamID := newStreamID()
-	if _, err := writer.Init(r.Context(), w, streamID, ""); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+func (s *responseStream) started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ready
+}
+
+func (s *responseStream) write(data []byte, final bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writer == nil {
+		s.writer = s.h.writerFactory.Create(s.sessionID)
+		if _, err := s.writer.Init(s.r.Context(), s.w, newStreamID(), ""); err != nil {
+			return err
+		}
+		s.ready = true
 	}
+	if !s.ready {
+		return ErrStreamingUnsupported
+	}
+	return s.writer.Write(s.r.Context(), data, final)
+}
 
-	_ = writer.Write(r.Context(), data, true)
+func (s *responseStream) notify(ctx context.Context, method string, params interface{}) error {
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&protocol.JSONRPCMessage{
+		JSONRPC: protocol.JSONRPCVersion,
+		Method:  method,
+		Params:  paramsBytes,
+	})
+	if err != nil {
+		return err
+	}
+	return s.write(data, false)
+}
+
+func (s *responseStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writer != nil {
+		_ = s.writer.Close()
+	}
 }
 
 func (h *HTTPHandler) handleGet(w http.ResponseWriter, r *http.Request) {
@@ -232,13 +444,14 @@ func (h *HTTPHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.mu.RLock()
-	_, ok := h.sessions[sessionID]
+	session, ok := h.sessions[sessionID]
 	h.mu.RUnlock()
 
 	if !ok {
 		http.Error(w, "Session not found", http.StatusNotFound)
 		return
 	}
+	session.touch()
 
 	lastEventID := r.Header.Get(LastEventIDHeader)
 	writer := h.writerFactory.Create(sessionID)
@@ -254,13 +467,16 @@ func (h *HTTPHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 		if errors.Is(err, ErrReplayUnsupported) {
 			// Per MCP spec: return 405 if SSE/resumption not supported
 			http.Error(w, "Method not allowed: SSE not supported", http.StatusMethodNotAllowed)
+		} else if errors.Is(err, ErrInvalidEventID) || errors.Is(err, ErrEventsPurged) {
+			// The missed events cannot be replayed
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		} else {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
 
-	// Replay stored events
+	// Replay the events missed since Last-Event-ID
 	for _, data := range replay {
 		if err := writer.Write(r.Context(), data, false); err != nil {
 			return
@@ -271,6 +487,24 @@ func (h *HTTPHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 	<-r.Context().Done()
 }
 
+// pushEventStream promises the client a GET of the session's SSE stream, which is then
+// served by handleGet as if the client had requested it. It is attempted once per session.
+func (h *HTTPHandler) pushEventStream(w http.ResponseWriter, r *http.Request, session *sessionState, sessionID string) {
+	pusher, ok := w.(http.Pusher)
+	if !ok || session.pushed.Swap(true) {
+		return
+	}
+
+	header := http.Header{}
+	header.Set("Accept", "text/event-stream")
+	header.Set(MCPSessionIDHeader, sessionID)
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		header.Set("Authorization", auth)
+	}
+	// Fails with http.ErrNotSupported when the client disabled push; it then opens the stream itself
+	_ = pusher.Push(r.URL.Path, &http.PushOptions{Method: http.MethodGet, Header: header})
+}
+
 func (h *HTTPHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.Header.Get(MCPSessionIDHeader)
 	if sessionID == "" {
@@ -298,44 +532,67 @@ func (h *HTTPHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
 
 func (h *HTTPHandler) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
 	if r.ContentLength > h.maxBodyBytes {
-		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		writeBodyTooLarge(w)
 		return nil, errors.New("body too large")
 	}
-	body, err := io.ReadAll(io.LimitReader(r.Body, h.maxBodyBytes))
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
 	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeBodyTooLarge(w)
+			return nil, err
+		}
 		http.Error(w, "Failed to read request body", http.StatusBadRequest)
 		return nil, err
 	}
 	return body, nil
 }
 
+// writeBodyTooLarge responds with 413 and a JSON-RPC parse error
+func writeBodyTooLarge(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	_ = json.NewEncoder(w).Encode(&protocol.JSONRPCMessage{
+		JSONRPC: protocol.JSONRPCVersion,
+		Error: &protocol.JSONRPCError{
+			Code:    protocol.ParseError,
+			Message: "Request body too large",
+		},
+	})
+}
+
 func (h *HTTPHandler) getOrCreateSession(r *http.Request, sessionID string, isInitialize bool) (*sessionState, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	if isInitialize {
 		srv := h.serverFactory(r)
-		h.sessions[sessionID] = &sessionState{
-			server:     srv,
-			lastActive: time.Now(),
-		}
-		return h.sessions[sessionID], nil
+		session := &sessionState{server: srv}
+		session.touch()
+		h.sessions[sessionID] = session
+		return session, nil
 	}
 
 	session, ok := h.sessions[sessionID]
 	if !ok {
 		return nil, errors.New("session not found")
 	}
-	session.lastActive = time.Now()
+	session.touch()
 	return session, nil
 }
 
+// cleanupLoop evicts idle sessions until the handler is shut down
 func (h *HTTPHandler) cleanupLoop() {
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(h.cleanupInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		h.cleanupSessions(30 * time.Minute)
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+			h.cleanupSessions(h.idleTimeout)
+		}
 	}
 }
 
@@ -345,7 +602,7 @@ func (h *HTTPHandler) cleanupSessions(maxAge time.Duration) {
 
 	now := time.Now()
 	for id, session := range h.sessions {
-		if now.Sub(session.lastActive) > maxAge {
+		if session.idleFor(now) > maxAge {
 			delete(h.sessions, id)
 			go h.writerFactory.OnSessionClose(context.Background(), id)
 		}
@@ -18,6 +18,11 @@ type ResumableWriter struct {
 	flusher     http.Flusher
 	lastEventID int
 	initDone    bool
+
+	// Events returned by Init for replay are written with their original IDs
+	// rather than stored again, starting after replayFrom.
+	pendingReplay int
+	replayFrom    int
 }
 
 // ResumableWriterFactory creates ResumableWriter instances.
@@ -94,6 +99,8 @@ func (rw *ResumableWriter) Init(ctx context.Context, w http.ResponseWriter, stre
 			return nil, err
 		}
 		replay = events
+		rw.pendingReplay = len(events)
+		rw.replayFrom = idx
 		rw.lastEventID = idx + len(events)
 	}
 
@@ -125,6 +132,16 @@ func (rw *ResumableWriter) Write(ctx context.Context, data []byte, final bool) e
 	rw.mu.Lock()
 	defer rw.mu.Unlock()
 
+	if rw.pendingReplay > 0 {
+		rw.pendingReplay--
+		rw.replayFrom++
+		return writeEvent(rw.w, Event{
+			Name: "message",
+			Data: data,
+			ID:   formatEventID(rw.streamID, rw.replayFrom),
+		})
+	}
+
 	// Store event and get ID
 	eventID, err := rw.store.Append(ctx, rw.sessionID, rw.streamID, data)
 	if err != nil {
@@ -39,3 +39,10 @@ type Connection interface {
 	// Returns empty string if there is no session ID.
 	SessionID() string
 }
+
+// SessionTokenSetter is implemented by client connections that authenticate their requests
+// with a session token. The client passes it each token received with
+// notifications/auth/token_rotated.
+type SessionTokenSetter interface {
+	SetSessionToken(token string)
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 
 	invopop "github.com/invopop/jsonschema"
 	"github.com/voocel/mcp-sdk-go/protocol"
@@ -35,9 +38,33 @@ func InferSchemaFromType(rt reflect.Type, customTypes map[reflect.Type]*invopop.
 	if schema.Type != "object" {
 		return nil, fmt.Errorf("schema must have type 'object', got %q", schema.Type)
 	}
+	optionalizeDefaults(schema)
 	return schema, nil
 }
 
+// optionalizeDefaults removes properties that declare a default (jsonschema:"default=...")
+// from "required", since a missing value is filled in from the default.
+func optionalizeDefaults(schema *invopop.Schema) {
+	if schema == nil {
+		return
+	}
+	if schema.Properties != nil {
+		for pair := schema.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			optionalizeDefaults(pair.Value)
+			if pair.Value == nil || pair.Value.Default == nil {
+				continue
+			}
+			for i, name := range schema.Required {
+				if name == pair.Key {
+					schema.Required = append(schema.Required[:i], schema.Required[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	optionalizeDefaults(schema.Items)
+}
+
 // SchemaToJSONMap 将 invopop.Schema 转为协议使用的 JSON Schema 格式。
 func SchemaToJSONMap(schema *invopop.Schema) (protocol.JSONSchema, error) {
 	if schema == nil {
@@ -53,3 +80,103 @@ func SchemaToJSONMap(schema *invopop.Schema) (protocol.JSONSchema, error) {
 	}
 	return schemaMap, nil
 }
+
+// applyFieldTags adjusts a schema inferred from rt for the `yaml` name fallback, optional
+// pointer fields and boolean examples of StructToJSONSchema
+func applyFieldTags(rt reflect.Type, schema *invopop.Schema) {
+	if schema == nil {
+		return
+	}
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	switch rt.Kind() {
+	case reflect.Struct:
+		if schema.Properties != nil {
+			applyStructFieldTags(rt, schema)
+		}
+	case reflect.Slice, reflect.Array:
+		applyFieldTags(rt.Elem(), schema.Items)
+	case reflect.Map:
+		applyFieldTags(rt.Elem(), schema.AdditionalProperties)
+	}
+}
+
+func applyStructFieldTags(rt reflect.Type, schema *invopop.Schema) {
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		jsonTag := f.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		jsonName, _, _ := strings.Cut(jsonTag, ",")
+
+		if f.Anonymous && jsonName == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				applyStructFieldTags(embedded, schema)
+			}
+			continue
+		}
+
+		name := f.Name
+		if jsonName != "" {
+			name = jsonName
+		}
+		prop, ok := schema.Properties.Get(name)
+		if !ok {
+			continue
+		}
+
+		if jsonName == "" {
+			yamlName, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if yamlName != "" && yamlName != "-" && yamlName != name {
+				if _, taken := schema.Properties.Get(yamlName); !taken {
+					renameProperty(schema, name, yamlName)
+					name = yamlName
+				}
+			}
+		}
+
+		if f.Type.Kind() == reflect.Pointer && !slices.Contains(strings.Split(f.Tag.Get("jsonschema"), ","), "required") {
+			schema.Required = slices.DeleteFunc(schema.Required, func(r string) bool { return r == name })
+		}
+
+		applyBoolExamples(f, prop)
+		applyFieldTags(f.Type, prop)
+	}
+}
+
+// applyBoolExamples adds the jsonschema:"example=..." values of a boolean field, which
+// the reflector only reads for strings and numbers
+func applyBoolExamples(f reflect.StructField, prop *invopop.Schema) {
+	if prop == nil || prop.Type != "boolean" {
+		return
+	}
+	for _, tag := range strings.Split(f.Tag.Get("jsonschema"), ",") {
+		if value, ok := strings.CutPrefix(tag, "example="); ok {
+			if example, err := strconv.ParseBool(value); err == nil {
+				prop.Examples = append(prop.Examples, example)
+			}
+		}
+	}
+}
+
+// renameProperty renames a property of schema in place, keeping the property order
+func renameProperty(schema *invopop.Schema, from, to string) {
+	prop, _ := schema.Properties.Get(from)
+	schema.Properties.Set(to, prop)
+	_ = schema.Properties.MoveBefore(to, from)
+	schema.Properties.Delete(from)
+	for i, r := range schema.Required {
+		if r == from {
+			schema.Required[i] = to
+		}
+	}
+}
@@ -76,6 +76,12 @@ func NewJSONRPCNotification(method string, params any) (*protocol.JSONRPCMessage
 	}, nil
 }
 
+// StructToJSONSchema generates the JSON Schema of a struct. Properties are named after the
+// `json` tag, falling back to the `yaml` tag and then the field name; fields tagged
+// `json:"-"` are skipped. Fields are required unless tagged omitempty or of pointer type,
+// whose schema is that of the pointed-to type. Embedded structs are inlined, and nested
+// structs and slices are described recursively. Each jsonschema:"example=<value>" tag
+// adds a value to the property's "examples".
 func StructToJSONSchema(v any) (protocol.JSONSchema, error) {
 	t := reflect.TypeOf(v)
 	if t == nil {
@@ -93,6 +99,7 @@ func StructToJSONSchema(v any) (protocol.JSONSchema, error) {
 	if err != nil {
 		return nil, err
 	}
+	applyFieldTags(t, schema)
 	return SchemaToJSONMap(schema)
 }
 